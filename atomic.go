@@ -0,0 +1,217 @@
+package graphqlfixture
+
+import (
+	"fmt"
+	"github.com/Jeffail/gabs/v2"
+	gqlast "github.com/graphql-go/graphql/language/ast"
+	gqlprinter "github.com/graphql-go/graphql/language/printer"
+	"strings"
+	"time"
+)
+
+// setupGroup is the fixtures setup by one graphql request: a single fixture, or (with Fixtures.AtomicSetup)
+// consecutive independent mutations merged into one, which Hasura runs in one transaction
+type setupGroup struct {
+	fixtureIdxs []int
+	setup       string   // the graphql of the request
+	variables   []string // of the request
+	operation   string
+	steps       []operationStep // the operations of the request, sent in sequence
+	timeout     time.Duration
+	// merged only: by member, the response key in the merged response (the alias) to that in the fixture's response
+	responseKeys []map[string]string
+}
+
+// mergedSetup is a setup group being merged
+type mergedSetup struct {
+	setupGroup
+	variableDefs  map[string]*gqlast.VariableDefinition
+	fragments     map[string]*gqlast.FragmentDefinition
+	fragmentNames []string // in the order of appearance
+	selections    []gqlast.Selection
+	captors       map[string]bool // declared by the members
+}
+
+// setupGroups groups the fixtures into the requests of Setup, in sequence. With fs.AtomicSetup, a mutation joins
+// the previous one's group unless it uses the captors of the group (it needs their response first), or it can't be
+// merged: anything but root fields in a single mutation operation without directives, or a fragment name or
+// variable (captor) type declared differently by the group, or a fixture with its own setup hooks (they'd no longer
+// run right around its setup). The root fields are aliased with the fixture index (e.g., `f1_insert_abc: insert_abc`)
+// to avoid collisions, and the response is split back per fixture without the aliases, so the captor paths are not
+// rewritten. The variables are captors, hence the same value in each fixture, so they are declared once, not renamed.
+func (fs *Fixtures) setupGroups() []setupGroup {
+	var groups []setupGroup
+	var merging *mergedSetup
+	flush := func() {
+		if merging != nil {
+			groups = append(groups, merging.group(fs))
+			merging = nil
+		}
+	}
+	for fIdx, f := range fs.Fixtures {
+		var operation *gqlast.OperationDefinition
+		var fragments []*gqlast.FragmentDefinition
+		if fs.AtomicSetup && f.setupOperation == "mutation" && !f.Hooks.aroundSetup() {
+			doc, _ := parseGraphql(f.Setup) // no error, since the fixtures have passed parsing
+			operation, fragments = mergeableSetup(doc)
+		}
+		if operation == nil {
+			flush()
+			groups = append(groups, setupGroup{
+				fixtureIdxs: []int{fIdx},
+				setup:       f.Setup,
+				variables:   f.setupVariables,
+				operation:   f.setupOperation,
+				steps:       f.setupOperationSteps(),
+				timeout:     f.SetupTimeout,
+			})
+			continue
+		}
+		if merging != nil && !merging.accepts(f, operation, fragments) {
+			flush()
+		}
+		if merging == nil {
+			merging = &mergedSetup{
+				variableDefs: map[string]*gqlast.VariableDefinition{},
+				fragments:    map[string]*gqlast.FragmentDefinition{},
+				captors:      map[string]bool{},
+			}
+		}
+		merging.add(fIdx, f, operation, fragments)
+	}
+	flush()
+	return groups
+}
+
+// mergeableSetup returns the operation and fragments of the setup doc, if it can be merged with others:
+// a single mutation operation without directives, selecting root fields only
+func mergeableSetup(doc *gqlast.Document) (*gqlast.OperationDefinition, []*gqlast.FragmentDefinition) {
+	var operation *gqlast.OperationDefinition
+	var fragments []*gqlast.FragmentDefinition
+	for _, def := range doc.Definitions {
+		switch node := def.(type) {
+		case *gqlast.OperationDefinition:
+			if operation != nil {
+				return nil, nil
+			}
+			operation = node
+		case *gqlast.FragmentDefinition:
+			fragments = append(fragments, node)
+		}
+	}
+	if operation == nil || operation.Operation != "mutation" || len(operation.Directives) > 0 {
+		return nil, nil
+	}
+	for _, selection := range operation.SelectionSet.Selections {
+		if _, ok := selection.(*gqlast.Field); !ok {
+			return nil, nil
+		}
+	}
+	return operation, fragments
+}
+
+// accepts returns whether the fixture can join the group
+func (m *mergedSetup) accepts(f Fixture, operation *gqlast.OperationDefinition, fragments []*gqlast.FragmentDefinition) bool {
+	for _, varName := range f.setupVariables {
+		if m.captors[varName] {
+			return false
+		}
+	}
+	for _, varDef := range operation.VariableDefinitions {
+		if existing, found := m.variableDefs[varDef.Variable.Name.Value]; found && gqlprinter.Print(existing) != gqlprinter.Print(varDef) {
+			return false
+		}
+	}
+	for _, fragment := range fragments {
+		if existing, found := m.fragments[fragment.Name.Value]; found && gqlprinter.Print(existing) != gqlprinter.Print(fragment) {
+			return false
+		}
+	}
+	return true
+}
+
+// add merges the fixture's setup into the group, with its root fields aliased
+func (m *mergedSetup) add(fIdx int, f Fixture, operation *gqlast.OperationDefinition, fragments []*gqlast.FragmentDefinition) {
+	m.fixtureIdxs = append(m.fixtureIdxs, fIdx)
+	for _, varDef := range operation.VariableDefinitions {
+		if _, found := m.variableDefs[varDef.Variable.Name.Value]; !found {
+			m.variableDefs[varDef.Variable.Name.Value] = varDef
+			m.variables = append(m.variables, varDef.Variable.Name.Value)
+		}
+	}
+	for _, fragment := range fragments {
+		if _, found := m.fragments[fragment.Name.Value]; !found {
+			m.fragments[fragment.Name.Value] = fragment
+			m.fragmentNames = append(m.fragmentNames, fragment.Name.Value)
+		}
+	}
+	responseKeys := map[string]string{}
+	for _, selection := range operation.SelectionSet.Selections {
+		field := *selection.(*gqlast.Field)
+		alias := fmt.Sprintf("f%d_%s", fIdx, responseKey(&field))
+		responseKeys[alias] = responseKey(&field)
+		field.Alias = gqlast.NewName(&gqlast.Name{Value: alias})
+		m.selections = append(m.selections, &field)
+	}
+	m.responseKeys = append(m.responseKeys, responseKeys)
+	for captorName := range f.Captors {
+		m.captors[captorName] = true
+	}
+}
+
+// group returns the setup group of the merged fixtures; a single fixture is setup as is
+func (m *mergedSetup) group(fs *Fixtures) setupGroup {
+	if len(m.fixtureIdxs) == 1 {
+		f := fs.Fixtures[m.fixtureIdxs[0]]
+		return setupGroup{fixtureIdxs: m.fixtureIdxs, setup: f.Setup, variables: f.setupVariables, operation: f.setupOperation, steps: f.setupOperationSteps(), timeout: f.SetupTimeout}
+	}
+	operation := &gqlast.OperationDefinition{
+		Kind:         "OperationDefinition",
+		Operation:    "mutation",
+		SelectionSet: &gqlast.SelectionSet{Kind: "SelectionSet", Selections: m.selections},
+	}
+	for _, varName := range m.variables {
+		operation.VariableDefinitions = append(operation.VariableDefinitions, m.variableDefs[varName])
+	}
+	doc := &gqlast.Document{Kind: "Document", Definitions: []gqlast.Node{operation}}
+	for _, fragmentName := range m.fragmentNames {
+		doc.Definitions = append(doc.Definitions, m.fragments[fragmentName])
+	}
+	printed, _ := gqlprinter.Print(doc).(string)
+	m.setup = strings.TrimSpace(printed)
+	m.operation = "mutation"
+	m.steps = []operationStep{{variables: m.variables, operation: m.operation}}
+
+	// the longest of the members' timeouts, or no limit if any has none
+	for i, fIdx := range m.fixtureIdxs {
+		timeout := fs.Fixtures[fIdx].SetupTimeout
+		if i == 0 || m.timeout > 0 && (timeout <= 0 || timeout > m.timeout) {
+			m.timeout = timeout
+		}
+	}
+	return m.setupGroup
+}
+
+// response returns the response of the i-th fixture of the group, from the response of the group's request
+func (g setupGroup) response(i int, jsonParsedResp *gabs.Container) *gabs.Container {
+	if g.responseKeys == nil {
+		return jsonParsedResp
+	}
+	data := map[string]interface{}{}
+	for alias, key := range g.responseKeys[i] {
+		data[key] = jsonParsedResp.Search("data", alias).Data()
+	}
+	return gabs.Wrap(map[string]interface{}{"data": data})
+}
+
+// merged returns the names of the fixtures if merged, e.g., "fixture[0], fixture[1]"; empty if a single fixture
+func (g setupGroup) merged() string {
+	if len(g.fixtureIdxs) == 1 {
+		return ""
+	}
+	names := make([]string, 0, len(g.fixtureIdxs))
+	for _, fIdx := range g.fixtureIdxs {
+		names = append(names, fmt.Sprintf("fixture[%d]", fIdx))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,140 @@
+package graphqlfixture
+
+import (
+	"context"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAtomicSetup(t *testing.T) {
+	givenFixtures := func() []Fixture {
+		return []Fixture{
+			{
+				Setup:   `mutation { insert_abc(objects: { name: "abc1" }) { returning { id } } }`,
+				Captors: map[string]string{"abc_id": "/data/insert_abc/returning/0/id"},
+			},
+			{
+				Setup:   `mutation { xyz: insert_xyz_one(object: { name: "xyz1" }) { ...xyz } } fragment xyz on xyz { id }`,
+				Captors: map[string]string{"xyz_id": "/data/xyz/id"},
+			},
+			{
+				Setup: `query { abc { id } }`, // a query: not merged
+			},
+			{
+				Setup: `mutation ($abc_id: Int!) { insert_def(objects: { abc_id: $abc_id }) { affected_rows } }`,
+			},
+			{
+				Setup:   `mutation ($abc_id: Int!) { insert_ghi_one(object: { abc_id: $abc_id }) { id } }`,
+				Captors: map[string]string{"ghi_id": "/data/insert_ghi_one/id"},
+			},
+			{
+				Setup: `mutation ($ghi_id: Int!) { insert_jkl(objects: { ghi_id: $ghi_id }) { affected_rows } }`, // needs fixture[4]'s response
+			},
+		}
+	}
+
+	t.Run("independent mutations merged", func(t *testing.T) {
+		responses := [][]byte{
+			[]byte(`{ "data": { "f0_insert_abc": { "returning": [ { "id": 13 } ] }, "f1_xyz": { "id": 21 } } }`),
+			[]byte(`{ "data": { "abc": [ { "id": 13 } ] } }`),
+			[]byte(`{ "data": { "f3_insert_def": { "affected_rows": 1 }, "f4_insert_ghi_one": { "id": 34 } } }`),
+			[]byte(`{ "data": { "insert_jkl": { "affected_rows": 1 } } }`),
+		}
+		var requests []graphqlclient.Request
+		executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+			*resp.(*[]byte) = responses[len(requests)]
+			requests = append(requests, req)
+			return nil
+		})
+		fixtures := Fixtures{Fixtures: givenFixtures(), AtomicSetup: true}
+
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+		expectedRequests := []graphqlclient.Request{
+			{Query: `mutation {
+  f0_insert_abc: insert_abc(objects: {name: "abc1"}) {
+    returning {
+      id
+    }
+  }
+  f1_xyz: insert_xyz_one(object: {name: "xyz1"}) {
+    ...xyz
+  }
+}
+
+fragment xyz on xyz {
+  id
+}`},
+			{Query: `query { abc { id } }`},
+			{Query: `mutation ($abc_id: Int!) {
+  f3_insert_def: insert_def(objects: {abc_id: $abc_id}) {
+    affected_rows
+  }
+  f4_insert_ghi_one: insert_ghi_one(object: {abc_id: $abc_id}) {
+    id
+  }
+}`, Variables: map[string]interface{}{"abc_id": 13.0}},
+			{Query: `mutation ($ghi_id: Int!) { insert_jkl(objects: { ghi_id: $ghi_id }) { affected_rows } }`, Variables: map[string]interface{}{"ghi_id": 34.0}},
+		}
+		assert.Equal(t, expectedRequests, requests)
+		assert.Equal(t, map[string]interface{}{"abc_id": 13.0, "xyz_id": 21.0, "ghi_id": 34.0}, fixtures.captured)
+		assert.Equal(t, 5, *fixtures.SetupUntil())
+		assert.Equal(t, []string{
+			"fixture[0].setup: completed",
+			"fixture[1].setup: completed",
+			"fixture[0].captors: completed with 1 capture(s)",
+			"fixture[1].captors: completed with 1 capture(s)",
+			"fixture[2].setup: completed",
+			"fixture[2].captors: not exist",
+			"fixture[3].setup: completed",
+			"fixture[4].setup: completed",
+			"fixture[3].captors: not exist",
+			"fixture[4].captors: completed with 1 capture(s)",
+			"fixture[5].setup: completed",
+			"fixture[5].captors: not exist",
+		}, fixtures.Logs())
+	})
+
+	t.Run("fixture with setup hooks not merged", func(t *testing.T) {
+		var requests []graphqlclient.Request
+		var hookRequests []int // the number of requests sent when the hook of fixture[1] runs
+		executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+			*resp.(*[]byte) = []byte(`{ "data": {} }`)
+			requests = append(requests, req)
+			return nil
+		})
+		fixtures := Fixtures{Fixtures: []Fixture{
+			{Setup: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+			{
+				Setup: `mutation { insert_xyz(objects: {}) { affected_rows } }`,
+				Hooks: Hooks{BeforeSetup: func(ctx context.Context, info HookInfo) error {
+					hookRequests = append(hookRequests, len(requests))
+					return nil
+				}},
+			},
+			{Setup: `mutation { insert_def(objects: {}) { affected_rows } }`},
+		}, AtomicSetup: true}
+
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+		assert.Equal(t, []graphqlclient.Request{
+			{Query: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+			{Query: `mutation { insert_xyz(objects: {}) { affected_rows } }`},
+			{Query: `mutation { insert_def(objects: {}) { affected_rows } }`},
+		}, requests)
+		assert.Equal(t, []int{1}, hookRequests)
+	})
+
+	t.Run("merged setup rolled back together", func(t *testing.T) {
+		executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+			*resp.(*[]byte) = []byte(`{ "errors": [ { "message": "Uniqueness violation" } ] }`)
+			return nil
+		})
+		fixtures := Fixtures{Fixtures: givenFixtures(), AtomicSetup: true}
+
+		err := fixtures.Setup(context.Background(), executor)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "fixture[0].setup failed: merged setup of fixture[0], fixture[1]: ")
+		}
+		assert.Nil(t, fixtures.SetupUntil())
+	})
+}
@@ -0,0 +1,232 @@
+package graphqlfixture
+
+import (
+	"fmt"
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	gqlast "github.com/graphql-go/graphql/language/ast"
+	"regexp"
+	"strings"
+)
+
+// AutoTeardown configures generating the teardown of the fixtures that don't have one (see Fixtures.AutoTeardown)
+// from the Hasura inserts of their setup: the inserted rows, including those of the nested inserts through
+// relationships, are deleted by their primary keys, captured from the setup response. So the setup has to select
+// the primary key of every insert, e.g.,
+//
+//	insert_instructors(objects: [{ name: "Murphy", teaches: { data: [{ subject_id: 1 }] } }]) {
+//	  returning { id teaches { id } }
+//	}
+//
+// The generated teardown and its captors are set into the fixture by Parse(), and validated like the others.
+type AutoTeardown struct {
+	PrimaryKey     string // optional: the primary key field of the tables. Default "id".
+	PrimaryKeyType string // optional: the graphql type of the primary key. Default "Int".
+	// optional: the delete root field of the table (by the graphql name). Default "delete_<table>".
+	DeleteField func(table string) string
+	// optional: the table (by the graphql name) the relationship of the table refers to, and whether it's an array
+	// relationship; found false if it's not a relationship. Default: by Fixtures.Hasura; without either, a setup with
+	// nested inserts can't have its teardown generated.
+	Relationship func(table string, relName string) (relTable string, isArray bool, found bool)
+	// optional: the prefix of the generated captor names, followed by the fixture index and the insert path.
+	// Default "teardown_", e.g., "teardown_0_insert_instructors_teaches".
+	CaptorPrefix string
+}
+
+// teardownRows are the rows inserted by a setup (a root insert or a nested insert), to be deleted by the generated
+// teardown
+type teardownRows struct {
+	table      string
+	path       string // the insert, e.g., "insert_instructors.teaches"
+	captorPath string // of the primary keys, e.g., "/data/insert_instructors/returning/*/teaches/*/id"
+}
+
+var nonNameChars = regexp.MustCompile(`[^_0-9A-Za-z]+`)
+
+// teardownGenerator generates the teardown of a setup per the AutoTeardown config (with the defaults filled)
+type teardownGenerator struct {
+	AutoTeardown
+	hasura    *hasurameta.Schema
+	fragments map[string]*gqlast.FragmentDefinition // of the setup, for flattening its selection sets
+}
+
+func newTeardownGenerator(conf AutoTeardown, hasura *hasurameta.Schema) *teardownGenerator {
+	if conf.PrimaryKey == "" {
+		conf.PrimaryKey = "id"
+	}
+	if conf.PrimaryKeyType == "" {
+		conf.PrimaryKeyType = "Int"
+	}
+	if conf.DeleteField == nil {
+		conf.DeleteField = func(table string) string { return "delete_" + table }
+	}
+	if conf.CaptorPrefix == "" {
+		conf.CaptorPrefix = "teardown_"
+	}
+	return &teardownGenerator{AutoTeardown: conf, hasura: hasura}
+}
+
+// generate returns the teardown of the setup doc of fixture[fIdx], with the captors it needs; empty if the setup
+// inserts nothing. The rows are deleted in one mutation: the children (of array relationships) before their parents.
+func (g *teardownGenerator) generate(fIdx int, setupDoc *gqlast.Document) (string, map[string]string, error) {
+	g.fragments = fragmentsOf(setupDoc)
+
+	var rows []teardownRows
+	for _, field := range rootFields(setupDoc, "mutation") {
+		name := field.Name.Value
+		if !strings.HasPrefix(name, "insert_") {
+			continue
+		}
+		table, argName := strings.TrimPrefix(name, "insert_"), "objects"
+		path, pointer, selectionSet := responseKey(field), "/data/"+responseKey(field), field.SelectionSet
+		if strings.HasSuffix(name, "_one") {
+			table, argName = strings.TrimSuffix(table, "_one"), "object"
+		} else {
+			returning := g.selected(selectionSet, "returning")
+			if returning == nil {
+				return "", nil, fmt.Errorf("%s: select `returning { %s }` for the generated teardown", path, g.PrimaryKey)
+			}
+			pointer, selectionSet = pointer+"/"+responseKey(returning)+"/*", returning.SelectionSet
+		}
+		var objects []*gqlast.ObjectValue
+		for _, arg := range field.Arguments {
+			if arg.Name.Value == argName {
+				objects = objectValues(arg.Value)
+			}
+		}
+		insertRows, err := g.rows(table, path, pointer, selectionSet, objects)
+		if err != nil {
+			return "", nil, err
+		}
+		rows = append(rows, insertRows...)
+	}
+	if len(rows) == 0 {
+		return "", nil, nil
+	}
+
+	captors := map[string]string{}
+	var varDefs, deletes []string
+	deleteCounts := map[string]int{}
+	for _, r := range rows {
+		captorName := fmt.Sprintf("%s%d_%s", g.CaptorPrefix, fIdx, nonNameChars.ReplaceAllString(r.path, "_"))
+		captors[captorName] = r.captorPath
+		deleteField := g.DeleteField(r.table)
+		if deleteCounts[deleteField]++; deleteCounts[deleteField] > 1 { // deleting from the table again: aliased
+			deleteField = fmt.Sprintf("%s_%d: %s", deleteField, deleteCounts[deleteField], deleteField)
+		}
+		if strings.Contains(r.captorPath+"/", "/*/") {
+			varDefs = append(varDefs, fmt.Sprintf("$%s: [%s!]!", captorName, g.PrimaryKeyType))
+			deletes = append(deletes, fmt.Sprintf("%s(where: { %s: { _in: $%s } }) { affected_rows }", deleteField, g.PrimaryKey, captorName))
+		} else {
+			varDefs = append(varDefs, fmt.Sprintf("$%s: %s!", captorName, g.PrimaryKeyType))
+			deletes = append(deletes, fmt.Sprintf("%s(where: { %s: { _eq: $%s } }) { affected_rows }", deleteField, g.PrimaryKey, captorName))
+		}
+	}
+	teardown := fmt.Sprintf("mutation (%s) {\n  %s\n}", strings.Join(varDefs, ", "), strings.Join(deletes, "\n  "))
+	return teardown, captors, nil
+}
+
+// rows returns the rows of the insert into the table, and those of its nested inserts: the children (of array
+// relationships) first, then the rows themselves, then the parents (of object relationships).
+// pointer is where the inserted rows are in the response; selectionSet is what's selected of them.
+func (g *teardownGenerator) rows(table string, path string, pointer string, selectionSet *gqlast.SelectionSet, objects []*gqlast.ObjectValue) ([]teardownRows, error) {
+	pk := g.selected(selectionSet, g.PrimaryKey)
+	if pk == nil {
+		return nil, fmt.Errorf("%s: select `%s` for the generated teardown", path, g.PrimaryKey)
+	}
+	self := teardownRows{table: table, path: path, captorPath: pointer + "/" + responseKey(pk)}
+
+	// the nested inserts, by relationship in the order of appearance
+	var relNames []string
+	nestedData := map[string][]gqlast.Value{}
+	for _, object := range objects {
+		for _, field := range object.Fields {
+			nestedInsert, ok := field.Value.(*gqlast.ObjectValue)
+			if !ok {
+				continue
+			}
+			for _, nestedField := range nestedInsert.Fields {
+				if nestedField.Name.Value != "data" {
+					continue
+				}
+				if _, found := nestedData[field.Name.Value]; !found {
+					relNames = append(relNames, field.Name.Value)
+				}
+				nestedData[field.Name.Value] = append(nestedData[field.Name.Value], nestedField.Value)
+			}
+		}
+	}
+
+	var children, parents []teardownRows
+	for _, relName := range relNames {
+		relPath := path + "." + relName
+		if g.Relationship == nil && g.hasura == nil {
+			return nil, fmt.Errorf("%s: set Fixtures.Hasura or AutoTeardown.Relationship to resolve the table of the nested insert", relPath)
+		}
+		relTable, isArray, found := g.relationship(table, relName)
+		if !found {
+			continue // not a relationship, e.g., a json column with `data`
+		}
+		relField := g.selected(selectionSet, relName)
+		if relField == nil {
+			return nil, fmt.Errorf("%s: select `%s { %s }` for the generated teardown", relPath, relName, g.PrimaryKey)
+		}
+		if !isArray && len(nestedData[relName]) < len(objects) {
+			// the other objects refer to existing rows, which aren't to be deleted
+			return nil, fmt.Errorf("%s: inserted by some of the objects only, write the teardown instead", relPath)
+		}
+		relPointer := pointer + "/" + responseKey(relField)
+		if isArray {
+			relPointer += "/*"
+		}
+		var relObjects []*gqlast.ObjectValue
+		for _, data := range nestedData[relName] {
+			relObjects = append(relObjects, objectValues(data)...)
+		}
+		relRows, err := g.rows(relTable, relPath, relPointer, relField.SelectionSet, relObjects)
+		if err != nil {
+			return nil, err
+		}
+		if isArray {
+			children = append(children, relRows...)
+		} else {
+			parents = append(parents, relRows...)
+		}
+	}
+	return append(append(children, self), parents...), nil
+}
+
+// relationship resolves the relationship of the table per the config, or else Fixtures.Hasura (not nil)
+func (g *teardownGenerator) relationship(table string, relName string) (string, bool, bool) {
+	if g.Relationship != nil {
+		return g.Relationship(table, relName)
+	}
+	return hasuraRelationship(g.hasura, table, relName)
+}
+
+// selected returns the (first) field of the name in the selection set; nil if not selected
+func (g *teardownGenerator) selected(selectionSet *gqlast.SelectionSet, name string) *gqlast.Field {
+	for _, field := range flattenFields(selectionSet, g.fragments) {
+		if field.Name.Value == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// objectValues returns the objects of the insert argument: a list of objects or a single one. Objects given as a
+// variable can't be looked into.
+func objectValues(value gqlast.Value) []*gqlast.ObjectValue {
+	switch v := value.(type) {
+	case *gqlast.ObjectValue:
+		return []*gqlast.ObjectValue{v}
+	case *gqlast.ListValue:
+		var objects []*gqlast.ObjectValue
+		for _, elem := range v.Values {
+			if object, ok := elem.(*gqlast.ObjectValue); ok {
+				objects = append(objects, object)
+			}
+		}
+		return objects
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package graphqlfixture
+
+import (
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAutoTeardown(t *testing.T) {
+	meta, err := hasurameta.Load("example/hasura")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testCases := []struct {
+		name             string
+		givenFixture     Fixture
+		givenConf        AutoTeardown
+		givenHasura      *hasurameta.Schema
+		expectedTeardown *string
+		expectedCaptured map[string]interface{} // by DryRun
+		expectedErr      string
+	}{
+		{
+			name: "nested inserts, with the hasura metadata",
+			givenFixture: Fixture{
+				Setup: `mutation {
+					insert_instructors(objects: [
+						{ name: "Murphy", teaches: { data: [{ subject: { data: { name: "CS101" } } }, { subject: { data: { name: "CS102" } } }] } },
+						{ name: "Gauss" }
+					]) { returning { id teaches { id subject { id } } } }
+				}`,
+			},
+			givenHasura: meta,
+			expectedTeardown: gopointer.OfString(`mutation ($teardown_0_insert_instructors_teaches: [Int!]!, $teardown_0_insert_instructors_teaches_subject: [Int!]!, $teardown_0_insert_instructors: [Int!]!) {
+  delete_teaches(where: { id: { _in: $teardown_0_insert_instructors_teaches } }) { affected_rows }
+  delete_subjects(where: { id: { _in: $teardown_0_insert_instructors_teaches_subject } }) { affected_rows }
+  delete_instructors(where: { id: { _in: $teardown_0_insert_instructors } }) { affected_rows }
+}`),
+			expectedCaptured: map[string]interface{}{
+				"teardown_0_insert_instructors":                 []interface{}{float64(1), float64(6)},
+				"teardown_0_insert_instructors_teaches":         []interface{}{float64(2), float64(4), float64(7)},
+				"teardown_0_insert_instructors_teaches_subject": []interface{}{float64(3), float64(5), float64(8)},
+			},
+		},
+		{
+			name: "insert one and configured conventions, without the hasura metadata",
+			givenFixture: Fixture{
+				Setup:   `mutation { insert_subjects_one(object: { name: "CS101" }) { subject_id name } insert_subjects(objects: []) { returning { subject_id } } }`,
+				Captors: map[string]string{"cs101": "/data/insert_subjects_one/name"},
+			},
+			givenConf: AutoTeardown{
+				PrimaryKey:     "subject_id",
+				PrimaryKeyType: "bigint",
+				DeleteField:    func(table string) string { return "remove_" + table },
+				CaptorPrefix:   "td",
+			},
+			expectedTeardown: gopointer.OfString(`mutation ($td0_insert_subjects_one: bigint!, $td0_insert_subjects: [bigint!]!) {
+  remove_subjects(where: { subject_id: { _eq: $td0_insert_subjects_one } }) { affected_rows }
+  remove_subjects_2: remove_subjects(where: { subject_id: { _in: $td0_insert_subjects } }) { affected_rows }
+}`),
+			expectedCaptured: map[string]interface{}{
+				"cs101":                   "CS101",
+				"td0_insert_subjects_one": float64(1),
+				"td0_insert_subjects":     []interface{}{},
+			},
+		},
+		{
+			name:         "query: no teardown",
+			givenFixture: Fixture{Setup: `query { subjects { id } }`},
+		},
+		{
+			name:         "primary key not selected",
+			givenFixture: Fixture{Setup: `mutation { insert_subjects(objects: [{ name: "CS101" }]) { affected_rows } }`},
+			expectedErr:  "fixture[0].setup: insert_subjects: select `returning { id }` for the generated teardown",
+		},
+		{
+			name:         "nested insert not selected",
+			givenFixture: Fixture{Setup: `mutation { insert_teaches_one(object: { subject: { data: { name: "CS101" } } }) { id } }`},
+			givenHasura:  meta,
+			expectedErr:  "fixture[0].setup: insert_teaches_one.subject: select `subject { id }` for the generated teardown",
+		},
+		{
+			name:         "nested insert without the hasura metadata",
+			givenFixture: Fixture{Setup: `mutation { insert_teaches_one(object: { subject: { data: { name: "CS101" } } }) { id subject { id } } }`},
+			expectedErr:  "fixture[0].setup: insert_teaches_one.subject: set Fixtures.Hasura or AutoTeardown.Relationship to resolve the table of the nested insert",
+		},
+		{
+			name: "object relationship inserted by some of the objects",
+			givenFixture: Fixture{
+				Setup: `mutation {
+					insert_teaches(objects: [{ subject: { data: { name: "CS101" } } }, { subject_id: 1 }]) { returning { id subject { id } } }
+				}`,
+			},
+			givenHasura: meta,
+			expectedErr: "fixture[0].setup: insert_teaches.subject: inserted by some of the objects only, write the teardown instead",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := tc.givenConf
+			fixtures := Fixtures{Fixtures: []Fixture{tc.givenFixture}, AutoTeardown: &conf, Hasura: tc.givenHasura}
+			fixtures.Parse()
+			if tc.expectedErr != "" {
+				if assert.Error(t, fixtures.parseErr) {
+					assert.Contains(t, fixtures.parseErr.Error(), tc.expectedErr)
+				}
+				return
+			}
+			if !assert.NoError(t, fixtures.parseErr) {
+				return
+			}
+			if tc.expectedTeardown == nil {
+				assert.Nil(t, fixtures.Fixtures[0].Teardown)
+			} else if assert.NotNil(t, fixtures.Fixtures[0].Teardown) {
+				assert.Equal(t, *tc.expectedTeardown, *fixtures.Fixtures[0].Teardown)
+			}
+			if tc.expectedCaptured != nil {
+				captured, err := fixtures.DryRun()
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedCaptured, captured)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// ErrBatchNotSupported is returned by BatchExecutor.DoBatch when the server doesn't answer a batch with a list of
+// responses, or rejects it as a bad request (status 400, 404, 405, 415); Setup then falls back to sending the
+// requests one by one
+var ErrBatchNotSupported = errors.New("batch not supported")
+
+// BatchExecutor is an Executor that can also send several requests in one HTTP request: a JSON array of requests,
+// answered by an array of responses in the same order (Apollo-style batching).
+// *BatchClient satisfies this interface.
+type BatchExecutor interface {
+	Executor
+	DoBatch(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error)
+}
+
+// BatchClient is a graphqlclient.Client that also sends batches
+type BatchClient struct {
+	*graphqlclient.Client
+	url           string
+	httpClient    *http.Client
+	customHeaders http.Header
+}
+
+// NewBatchClient creates a BatchClient targeting the graphql server url, as graphqlclient.New does
+func NewBatchClient(url string, httpClient *http.Client, customHeaders http.Header) *BatchClient {
+	if customHeaders == nil {
+		customHeaders = http.Header{}
+	}
+	client := graphqlclient.New(url, httpClient, customHeaders)
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &BatchClient{Client: client, url: url, httpClient: httpClient, customHeaders: customHeaders}
+}
+
+// DoBatch sends the requests as a JSON array, and returns the responses (one per request, each as Do would get it)
+func (c *BatchClient) DoBatch(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(graphqlRequests); err != nil {
+		return nil, fmt.Errorf("error encoding request body: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url, &buf)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header = c.customHeaders.Clone()
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusUnsupportedMediaType:
+		return nil, fmt.Errorf("%w: bad response status code: %v body: %q", ErrBatchNotSupported, resp.Status, body)
+	default:
+		// as graphqlclient reports it, e.g., for IsTransientError
+		return nil, fmt.Errorf("bad response status code: %v body: %q", resp.Status, body)
+	}
+	var responses []json.RawMessage
+	if err := json.Unmarshal(body, &responses); err != nil {
+		return nil, fmt.Errorf("%w: response is not a json array: %q", ErrBatchNotSupported, body)
+	}
+	if len(responses) != len(graphqlRequests) {
+		return nil, fmt.Errorf("%d response(s) to %d request(s)", len(responses), len(graphqlRequests))
+	}
+	responseBytes := make([][]byte, 0, len(responses))
+	for _, response := range responses {
+		responseBytes = append(responseBytes, response)
+	}
+	return responseBytes, nil
+}
+
+// batchSize returns how many of the setup groups (from the first) go in one batch: with fs.BatchSetup and a
+// BatchExecutor, the consecutive single-operation queries not using the captors of the batch (they need its
+// response first).
+// Mutations are sent alone: the batched requests may run in any order, while a mutation may depend on the previous
+// ones without captors (e.g., by a literal id). 1 means no batch.
+func (fs *Fixtures) batchSize(groups []setupGroup, graphqlClient Executor) int {
+	if _, ok := graphqlClient.(BatchExecutor); !ok || !fs.BatchSetup {
+		return 1
+	}
+	captors := map[string]bool{}
+	for size, group := range groups {
+		if group.operation != "query" || len(group.steps) > 1 || usesAny(group.variables, captors) {
+			if size == 0 {
+				return 1
+			}
+			return size
+		}
+		for _, fIdx := range group.fixtureIdxs {
+			for captorName := range fs.Fixtures[fIdx].Captors {
+				captors[captorName] = true
+			}
+		}
+	}
+	return len(groups)
+}
+
+// usesAny returns whether any of the variables is one of the captors
+func usesAny(varNames []string, captors map[string]bool) bool {
+	for _, varName := range varNames {
+		if captors[varName] {
+			return true
+		}
+	}
+	return false
+}
+
+// setupBatch sends the setup requests of the groups in one batch, then completes each in sequence, stopping at the
+// first failure (an item's response with errors fails its fixture, unless fs.Retry deems it retryable: then the
+// group is sent again alone, with retry). The batch is bounded by the shortest of the groups' timeouts, and retried
+// as a whole per fs.Retry. If the server doesn't support batches, the groups are setup one by one instead.
+func (fs *Fixtures) setupBatch(ctx context.Context, batchExecutor BatchExecutor, groups []setupGroup) error {
+	for _, group := range groups {
+		if err := fs.runSetupHooks(ctx, group, PhaseBeforeSetup); err != nil {
+			return err
+		}
+	}
+
+	batchTime := time.Now()
+	requests := make([]graphqlclient.Request, 0, len(groups))
+	var timeout time.Duration // the shortest of the groups'
+	for _, group := range groups {
+		request, err := newGraphqlRequest(group.setup, group.steps[0].name, group.variables, fs.captured)
+		if err != nil {
+			return fs.recordFailure(ctx, fs.newEvent(group.fixtureIdxs[0], PhaseSetup, StatusFailed), err)
+		}
+		requests = append(requests, request)
+		if group.timeout > 0 && (timeout <= 0 || group.timeout < timeout) {
+			timeout = group.timeout
+		}
+	}
+	batchCtx, cancel := withOptionalTimeout(ctx, timeout)
+	responses, err := fs.doBatchWithRetry(batchCtx, batchExecutor, groups, requests)
+	cancel()
+	if errors.Is(err, ErrBatchNotSupported) {
+		// nothing is created by the queries: safe to send them again, one by one
+		for _, group := range groups {
+			if err := fs.resendSetup(ctx, batchExecutor, group); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		setupEvent := fs.newEvent(groups[0].fixtureIdxs[0], PhaseSetup, StatusFailed)
+		setupEvent.Time, setupEvent.Duration, setupEvent.Operation = batchTime, time.Since(batchTime), groups[0].operation
+		return fs.recordFailure(ctx, setupEvent, fmt.Errorf("batched request failed: %w", err))
+	}
+
+	for i, group := range groups {
+		setupEvent := fs.newEvent(group.fixtureIdxs[0], PhaseSetup, StatusCompleted)
+		setupEvent.Time, setupEvent.Duration, setupEvent.Operation = batchTime, time.Since(batchTime), group.operation
+		jsonParsedResp, err := parseGraphqlResponse(responses[i])
+		if err != nil && fs.Retry.appliesTo(group.operation) && fs.Retry.isRetryable(err) {
+			if err := fs.resendSetup(ctx, batchExecutor, group); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fs.recordFailure(ctx, setupEvent, fmt.Errorf("batched request failed: %w", err))
+		}
+		if err := fs.completeSetup(ctx, group, setupEvent, jsonParsedResp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resendSetup sends the setup request of the group alone (with retry), and completes it
+func (fs *Fixtures) resendSetup(ctx context.Context, graphqlClient Executor, group setupGroup) error {
+	jsonParsedResp, setupEvent, err := fs.sendSetup(ctx, graphqlClient, group)
+	if err != nil {
+		return err
+	}
+	return fs.completeSetup(ctx, group, setupEvent, jsonParsedResp)
+}
+
+// doBatchWithRetry sends the batch, and retries it according to fs.Retry (as a query), but not when the server
+// doesn't support batches. Each attempt is recorded as an event of each group's (first) fixture when retry applies.
+func (fs *Fixtures) doBatchWithRetry(ctx context.Context, batchExecutor BatchExecutor, groups []setupGroup,
+	requests []graphqlclient.Request) ([][]byte, error) {
+	maxAttempts := fs.Retry.maxAttempts("query")
+	for attempt := 1; ; attempt++ {
+		attemptTime := time.Now()
+		reqCtx, span := fs.tracer().Start(ctx, SpanRequest, Attr(AttrOperationType, "query"), Attr(AttrAttempt, attempt),
+			Attr(AttrBatchSize, len(requests)))
+		responses, err := batchExecutor.DoBatch(reqCtx, requests)
+		fs.endSpan(span, err)
+		if maxAttempts == 1 || errors.Is(err, ErrBatchNotSupported) {
+			return responses, err
+		}
+		// reach here: retry applies; record every attempt
+		willRetry := err != nil && attempt < maxAttempts && fs.Retry.isRetryable(err)
+		var backoff time.Duration
+		if willRetry {
+			backoff = fs.Retry.backoff(attempt)
+		}
+		for _, group := range groups {
+			attemptEvent := fs.newEvent(group.fixtureIdxs[0], PhaseSetup, StatusAttemptSucceeded)
+			attemptEvent.Time, attemptEvent.Duration = attemptTime, time.Since(attemptTime)
+			attemptEvent.Operation, attemptEvent.Attempt, attemptEvent.MaxAttempts = "query", attempt, maxAttempts
+			if err != nil {
+				attemptEvent.Status, attemptEvent.Error = StatusAttemptFailed, err.Error()
+			}
+			attemptEvent.WillRetry, attemptEvent.Backoff = willRetry, backoff
+			fs.record(attemptEvent)
+		}
+		if !willRetry {
+			return responses, err
+		}
+		if sleepErr := sleepCtx(ctx, backoff); sleepErr != nil {
+			return nil, fmt.Errorf("%w (retry aborted: %v)", err, sleepErr)
+		}
+	}
+}
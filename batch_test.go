@@ -0,0 +1,295 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// batchServer answers each query with responses[query]; a batch (json array) with an array of those, unless
+// !supportsBatch. The bodies of the http requests are appended to bodies.
+func batchServer(t *testing.T, responses map[string]string, supportsBatch bool, bodies *[]string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, strings.TrimSpace(string(body)))
+		if bytes.HasPrefix(body, []byte("[")) {
+			if !supportsBatch {
+				_, _ = w.Write([]byte(`{ "errors": [ { "message": "expected an object" } ] }`))
+				return
+			}
+			var requests []graphqlclient.Request
+			assert.NoError(t, json.Unmarshal(body, &requests))
+			var items []string
+			for _, request := range requests {
+				items = append(items, responses[request.Query])
+			}
+			_, _ = w.Write([]byte("[" + strings.Join(items, ",") + "]"))
+			return
+		}
+		var request graphqlclient.Request
+		assert.NoError(t, json.Unmarshal(body, &request))
+		_, _ = w.Write([]byte(responses[request.Query]))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestBatchSetup(t *testing.T) {
+	givenFixtures := func() []Fixture {
+		return []Fixture{
+			{Setup: `query { abc { id } }`, Captors: map[string]string{"abc_id": "/data/abc/0/id"}},
+			{Setup: `query { xyz { id } }`, Captors: map[string]string{"xyz_id": "/data/xyz/0/id"}},
+			{Setup: `query ($abc_id: Int!) { def(where: { abc_id: { _eq: $abc_id } }) { id } }`}, // needs fixture[0]'s response
+			{Setup: `mutation { insert_ghi(objects: {}) { affected_rows } }`},
+			{Setup: `query { jkl { id } }`},
+		}
+	}
+	responses := map[string]string{
+		`query { abc { id } }`: `{ "data": { "abc": [ { "id": 1 } ] } }`,
+		`query { xyz { id } }`: `{ "data": { "xyz": [ { "id": 2 } ] } }`,
+		`query ($abc_id: Int!) { def(where: { abc_id: { _eq: $abc_id } }) { id } }`: `{ "data": { "def": [] } }`,
+		`mutation { insert_ghi(objects: {}) { affected_rows } }`:                    `{ "data": { "insert_ghi": { "affected_rows": 1 } } }`,
+		`query { jkl { id } }`: `{ "data": { "jkl": [] } }`,
+	}
+
+	testCases := []struct {
+		name               string
+		givenResponses     map[string]string
+		givenSupportsBatch bool
+		expectedBodies     []string
+		expectedSetupUntil int
+		expectedErr        string
+	}{
+		{
+			name:               "independent queries batched",
+			givenSupportsBatch: true,
+			expectedBodies: []string{
+				`[{"query":"query { abc { id } }"},{"query":"query { xyz { id } }"}]`,
+				`{"query":"query ($abc_id: Int!) { def(where: { abc_id: { _eq: $abc_id } }) { id } }","variables":{"abc_id":1}}`,
+				`{"query":"mutation { insert_ghi(objects: {}) { affected_rows } }"}`,
+				`{"query":"query { jkl { id } }"}`,
+			},
+			expectedSetupUntil: 4,
+		},
+		{
+			name: "batch not supported: one by one",
+			expectedBodies: []string{
+				`[{"query":"query { abc { id } }"},{"query":"query { xyz { id } }"}]`,
+				`{"query":"query { abc { id } }"}`,
+				`{"query":"query { xyz { id } }"}`,
+				`{"query":"query ($abc_id: Int!) { def(where: { abc_id: { _eq: $abc_id } }) { id } }","variables":{"abc_id":1}}`,
+				`{"query":"mutation { insert_ghi(objects: {}) { affected_rows } }"}`,
+				`{"query":"query { jkl { id } }"}`,
+			},
+			expectedSetupUntil: 4,
+		},
+		{
+			name: "an item of the batch fails",
+			givenResponses: map[string]string{
+				`query { xyz { id } }`: `{ "errors": [ { "message": "field \"xyz\" not found in type: 'query_root'" } ] }`,
+			},
+			givenSupportsBatch: true,
+			expectedBodies: []string{
+				`[{"query":"query { abc { id } }"},{"query":"query { xyz { id } }"}]`,
+			},
+			expectedSetupUntil: 0,
+			expectedErr:        `fixture[1].setup failed: batched request failed: `,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			serverResponses := map[string]string{}
+			for query, response := range responses {
+				serverResponses[query] = response
+			}
+			for query, response := range tc.givenResponses {
+				serverResponses[query] = response
+			}
+			var bodies []string
+			url := batchServer(t, serverResponses, tc.givenSupportsBatch, &bodies)
+			fixtures := Fixtures{Fixtures: givenFixtures(), BatchSetup: true}
+
+			err := fixtures.Setup(context.Background(), NewBatchClient(url, nil, nil))
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedBodies, bodies)
+			if assert.NotNil(t, fixtures.SetupUntil()) {
+				assert.Equal(t, tc.expectedSetupUntil, *fixtures.SetupUntil())
+			}
+		})
+	}
+}
+
+func TestBatchSetupWithoutBatchExecutor(t *testing.T) {
+	var requests []graphqlclient.Request
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = []byte(`{ "data": {} }`)
+		requests = append(requests, req)
+		return nil
+	})
+	fixtures := Fixtures{Fixtures: []Fixture{{Setup: `query { abc { id } }`}, {Setup: `query { xyz { id } }`}}, BatchSetup: true}
+
+	assert.NoError(t, fixtures.Setup(context.Background(), executor))
+	assert.Len(t, requests, 2)
+}
+
+// fakeBatchExecutor answers each request of a batch with `{ "data": {} }`, unless doBatch is given
+type fakeBatchExecutor struct {
+	requests []graphqlclient.Request // sent alone
+	batches  int
+	doBatch  func(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error)
+}
+
+func (e *fakeBatchExecutor) Do(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+	*resp.(*[]byte) = []byte(`{ "data": {} }`)
+	e.requests = append(e.requests, req)
+	return nil
+}
+
+func (e *fakeBatchExecutor) DoBatch(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error) {
+	e.batches++
+	if e.doBatch != nil {
+		return e.doBatch(ctx, graphqlRequests)
+	}
+	responses := make([][]byte, 0, len(graphqlRequests))
+	for range graphqlRequests {
+		responses = append(responses, []byte(`{ "data": {} }`))
+	}
+	return responses, nil
+}
+
+func TestBatchSetupFailures(t *testing.T) {
+	givenFixtures := func() []Fixture {
+		return []Fixture{
+			{Setup: `query { abc { id } }`, SetupTimeout: time.Minute},
+			{Setup: `query { xyz { id } }`, SetupTimeout: time.Second},
+		}
+	}
+
+	t.Run("bounded by the shortest timeout", func(t *testing.T) {
+		var deadline time.Duration
+		executor := &fakeBatchExecutor{}
+		executor.doBatch = func(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error) {
+			if d, ok := ctx.Deadline(); ok {
+				deadline = time.Until(d)
+			}
+			return [][]byte{[]byte(`{ "data": {} }`), []byte(`{ "data": {} }`)}, nil
+		}
+		fixtures := Fixtures{Fixtures: givenFixtures(), BatchSetup: true}
+
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+		assert.True(t, deadline > 0 && deadline <= time.Second, "deadline in %v", deadline)
+	})
+
+	t.Run("not sent again one by one on other errors", func(t *testing.T) {
+		executor := &fakeBatchExecutor{}
+		fixtures := Fixtures{Fixtures: givenFixtures(), BatchSetup: true}
+		executor.doBatch = func(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error) {
+			return nil, errors.New("1 response(s) to 2 request(s)")
+		}
+
+		err := fixtures.Setup(context.Background(), executor)
+		assert.EqualError(t, err, "fixture[0].setup failed: batched request failed: 1 response(s) to 2 request(s)")
+		assert.Empty(t, executor.requests)
+		assert.Nil(t, fixtures.SetupUntil())
+	})
+
+	t.Run("retried per the retry policy", func(t *testing.T) {
+		executor := &fakeBatchExecutor{}
+		executor.doBatch = func(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error) {
+			if executor.batches == 1 {
+				return nil, fmt.Errorf("error sending request: %w", io.ErrUnexpectedEOF)
+			}
+			return [][]byte{[]byte(`{ "data": {} }`), []byte(`{ "data": {} }`)}, nil
+		}
+		fixtures := Fixtures{Fixtures: givenFixtures(), BatchSetup: true, Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}}
+
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+		assert.Equal(t, 2, executor.batches)
+		assert.Empty(t, executor.requests)
+		assert.Equal(t, []string{
+			"fixture[0].setup: attempt 1/2 failed, retry in 1ms: error sending request: unexpected EOF",
+			"fixture[1].setup: attempt 1/2 failed, retry in 1ms: error sending request: unexpected EOF",
+			"fixture[0].setup: attempt 2/2 succeeded",
+			"fixture[1].setup: attempt 2/2 succeeded",
+			"fixture[0].setup: completed",
+			"fixture[0].captors: not exist",
+			"fixture[1].setup: completed",
+			"fixture[1].captors: not exist",
+		}, fixtures.Logs())
+	})
+
+	t.Run("retryable item error sent again alone", func(t *testing.T) {
+		executor := &fakeBatchExecutor{}
+		executor.doBatch = func(ctx context.Context, graphqlRequests []graphqlclient.Request) ([][]byte, error) {
+			return [][]byte{
+				[]byte(`{ "data": {} }`),
+				[]byte(`{ "errors": [ { "message": "database query error", "extensions": { "code": "unexpected" } } ] }`),
+			}, nil
+		}
+		fixtures := Fixtures{Fixtures: givenFixtures(), BatchSetup: true,
+			Retry: &RetryPolicy{MaxAttempts: 2, Retryable: RetryOnCodes(CodeUnexpected)}}
+
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+		assert.Equal(t, []graphqlclient.Request{{Query: `query { xyz { id } }`}}, executor.requests)
+		assert.Equal(t, 1, *fixtures.SetupUntil())
+	})
+}
+
+func TestBatchClientStatus(t *testing.T) {
+	testCases := []struct {
+		name             string
+		status           int
+		expectedBatches  int
+		expectedRequests int
+	}{
+		{name: "503 retried as a batch", status: http.StatusServiceUnavailable, expectedBatches: 2},
+		{name: "405 sent one by one", status: http.StatusMethodNotAllowed, expectedBatches: 1, expectedRequests: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches, requests := 0, 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				if !bytes.HasPrefix(body, []byte("[")) {
+					requests++
+					_, _ = w.Write([]byte(`{ "data": {} }`))
+					return
+				}
+				batches++
+				if batches == 1 {
+					w.WriteHeader(tc.status)
+					return
+				}
+				_, _ = w.Write([]byte(`[ { "data": {} }, { "data": {} } ]`))
+			}))
+			defer server.Close()
+			fixtures := Fixtures{
+				Fixtures:   []Fixture{{Setup: `query { abc { id } }`}, {Setup: `query { xyz { id } }`}},
+				BatchSetup: true,
+				Retry:      &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			}
+
+			assert.NoError(t, fixtures.Setup(context.Background(), NewBatchClient(server.URL, nil, nil)))
+			assert.Equal(t, tc.expectedBatches, batches)
+			assert.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/gmm1900/graphqlfixture"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// headerFlags collects repeated -H "name: value" flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	var headers []string
+	for name, values := range h {
+		for _, value := range values {
+			headers = append(headers, name+": "+value)
+		}
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (h headerFlags) Set(s string) error {
+	idx := strings.Index(s, ":")
+	if idx <= 0 {
+		return fmt.Errorf("expect header as name: value, got %q", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(s[:idx]), strings.TrimSpace(s[idx+1:]))
+	return nil
+}
+
+func runCleanup(args []string) error {
+	flagSet := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	url := flagSet.String("url", "", "graphql endpoint (required)")
+	headers := headerFlags{}
+	flagSet.Var(headers, "H", "request header as \"name: value\" (repeatable)")
+	timeout := flagSet.Duration("timeout", time.Minute, "time limit of the whole cleanup")
+	output := flagSet.String("o", "", "write the steps still failing to this file (default: overwrite the input file); removed with the input file once all the steps are done")
+	input, err := parseFlags(flagSet, args)
+	if err != nil {
+		return err
+	}
+	if *url == "" {
+		return fmt.Errorf("cleanup: -url is required")
+	}
+	if input == "" || input == "-" {
+		return fmt.Errorf("cleanup: remediation file is required")
+	}
+
+	in, err := openInput(input)
+	if err != nil {
+		return err
+	}
+	remediation, err := graphqlfixture.ReadRemediation(in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	remaining, runErr := remediation.Run(ctx, graphqlclient.New(*url, nil, http.Header(headers)))
+	fmt.Fprintf(os.Stderr, "cleanup: %d of %d step(s) done\n", len(remediation.Steps)-len(remaining.Steps), len(remediation.Steps))
+	if runErr == nil {
+		// nothing left to replay: remove the file, as Fixtures.RemediationFile is once everything is torn down
+		for _, file := range []string{input, *output} {
+			if file == "" || file == "-" {
+				continue
+			}
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("cleanup: fail to remove %s: %w", file, err)
+			}
+		}
+		return nil
+	}
+
+	// reach here: some steps still fail; keep them for another try
+	if *output == "" {
+		*output = input
+	}
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	err = remaining.WriteJSON(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return runErr
+}
@@ -0,0 +1,85 @@
+// Command graphqlfixture works with the artifacts of a fixture run outside of go code, e.g., in CI scripts.
+//
+// Usage:
+//
+//	graphqlfixture report [-format json|junit] [-suite name] [-o output] report.json
+//	    converts the JSON report (written by Report.WriteJSON) into JUnit XML (or re-formats the JSON)
+//
+//	graphqlfixture cleanup -url endpoint [-H "name: value"]... [-timeout 1m] [-o output] remediation.json
+//	    runs the outstanding teardowns (written by Remediation.WriteJSON or Fixtures.RemediationFile);
+//	    the steps still failing are written back for another try
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "report":
+		err = runReport(os.Args[2:])
+	case "cleanup":
+		err = runCleanup(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: graphqlfixture <command> [flags] [args]
+
+commands:
+  report    convert a JSON run report into JUnit XML
+  cleanup   run the outstanding teardowns of a remediation file`)
+}
+
+// openInput opens the file, or stdin if path is "" or "-"
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(path)
+}
+
+// createOutput creates the file, or returns stdout if path is "" or "-"
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// parseFlags parses the flags of a command, which takes at most one positional argument
+func parseFlags(flagSet *flag.FlagSet, args []string) (string, error) {
+	if err := flagSet.Parse(args); err != nil {
+		return "", err
+	}
+	if flagSet.NArg() > 1 {
+		return "", fmt.Errorf("%s: expect at most 1 argument, got %d", flagSet.Name(), flagSet.NArg())
+	}
+	return flagSet.Arg(0), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gmm1900/graphqlfixture"
+)
+
+func runReport(args []string) error {
+	flagSet := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := flagSet.String("format", "junit", "output format: junit or json")
+	suiteName := flagSet.String("suite", "graphqlfixture", "junit testsuite name")
+	output := flagSet.String("o", "", "output file (default stdout)")
+	input, err := parseFlags(flagSet, args)
+	if err != nil {
+		return err
+	}
+	if *format != "junit" && *format != "json" { // before the output is created, not to truncate it for nothing
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	in, err := openInput(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	report, err := graphqlfixture.ReadReport(in)
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if *format == "junit" {
+		err = report.WriteJUnit(out, *suiteName)
+	} else {
+		err = report.WriteJSON(out)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package graphqlfixture
+
+import (
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	gqlast "github.com/graphql-go/graphql/language/ast"
+	"strings"
+)
+
+// tableWrite is a table inserted into by a setup, by a root insert or a nested insert through a relationship
+type tableWrite struct {
+	fixtureIdx int
+	path       string   // the insert, e.g., "insert_instructors.teaches"
+	tables     []string // the graphql name of the table; without the hasura metadata, the names it may have
+}
+
+// teardownCoverage reports (with RuleTeardownCoverage) the tables inserted into by the setups that no teardown
+// deletes from, and (with RuleCascadeTeardown) those deleted only by ON DELETE CASCADE.
+// With fs.Hasura, the relationships of the nested inserts are resolved to their tables, and the cascades are known;
+// without, the table of relationship `r` is guessed to be `r` or `rs` (e.g., `teaches`, `subject` for `subjects`).
+func (fs *Fixtures) teardownCoverage(report func(fIdx int, rule string, phase Phase, format string, args ...interface{})) {
+	var writes []tableWrite
+	deleted := map[string]bool{}
+	for fIdx, f := range fs.Fixtures {
+		if setupDoc, err := f.setupDoc(); err == nil {
+			writes = append(writes, insertedTables(fIdx, setupDoc, fs.Hasura)...)
+		}
+		if f.Teardown == nil {
+			continue
+		}
+		if teardownDoc, err := f.teardownDoc(); err == nil {
+			for table := range deletedTables(teardownDoc) {
+				deleted[table] = true
+			}
+		}
+	}
+
+	reported := map[string]bool{} // by the first write of each table
+	for _, write := range writes {
+		if reported[write.tables[0]] {
+			continue
+		}
+		reported[write.tables[0]] = true
+		covered := false
+		for _, table := range write.tables {
+			covered = covered || deleted[table]
+		}
+		if covered {
+			continue
+		}
+		if cascadeFrom := cascadeSource(fs.Hasura, write.tables[0], deleted); cascadeFrom != "" {
+			report(write.fixtureIdx, RuleCascadeTeardown, PhaseSetup,
+				"%s inserts into %s, which no teardown deletes from but ON DELETE CASCADE from %s", write.path, write.tables[0], cascadeFrom)
+			continue
+		}
+		report(write.fixtureIdx, RuleTeardownCoverage, PhaseSetup, "%s inserts into %s, which no teardown deletes from", write.path, write.tables[0])
+	}
+}
+
+// insertedTables returns the tables inserted into by the mutation root fields of the doc, and their nested inserts
+// (i.e., `{ data: ... }` of a relationship). Objects given as a variable can't be looked into.
+func insertedTables(fIdx int, doc *gqlast.Document, meta *hasurameta.Schema) []tableWrite {
+	var writes []tableWrite
+	var walk func(path string, table string, value gqlast.Value)
+	walk = func(path string, table string, value gqlast.Value) {
+		switch v := value.(type) {
+		case *gqlast.ListValue:
+			for _, elem := range v.Values {
+				walk(path, table, elem)
+			}
+		case *gqlast.ObjectValue:
+			for _, field := range v.Fields {
+				nestedInsert, ok := field.Value.(*gqlast.ObjectValue)
+				if !ok {
+					continue
+				}
+				for _, nestedField := range nestedInsert.Fields {
+					if nestedField.Name.Value != "data" {
+						continue
+					}
+					nestedPath := path + "." + field.Name.Value
+					nestedTables := relationshipTables(meta, table, field.Name.Value)
+					if nestedTables == nil {
+						continue // not a relationship, e.g., a json column with `data`
+					}
+					writes = append(writes, tableWrite{fixtureIdx: fIdx, path: nestedPath, tables: nestedTables})
+					walk(nestedPath, nestedTables[0], nestedField.Value)
+				}
+			}
+		}
+	}
+
+	for _, field := range rootFields(doc, "mutation") {
+		name := field.Name.Value
+		if !strings.HasPrefix(name, "insert_") {
+			continue
+		}
+		table := strings.TrimPrefix(name, "insert_")
+		argName := "objects"
+		if strings.HasSuffix(name, "_one") {
+			table = strings.TrimSuffix(table, "_one")
+			argName = "object"
+		}
+		writes = append(writes, tableWrite{fixtureIdx: fIdx, path: name, tables: []string{table}})
+		for _, arg := range field.Arguments {
+			if arg.Name.Value == argName {
+				walk(name, table, arg.Value)
+			}
+		}
+	}
+	return writes
+}
+
+// relationshipTables returns the graphql name of the table the relationship refers to (nil if not a relationship);
+// without the hasura metadata, the names it may have
+func relationshipTables(meta *hasurameta.Schema, table string, relName string) []string {
+	if meta == nil {
+		return []string{relName, relName + "s"}
+	}
+	if relTable, _, found := hasuraRelationship(meta, table, relName); found {
+		return []string{relTable}
+	}
+	return nil
+}
+
+// hasuraRelationship returns the graphql name of the table the relationship of the table refers to, and whether it's
+// an array relationship, per the hasura metadata; found false if it's not a relationship
+func hasuraRelationship(meta *hasurameta.Schema, table string, relName string) (string, bool, bool) {
+	t, found := meta.Tables[table]
+	if !found {
+		return "", false, false
+	}
+	rel, isArray := t.Relationship(relName)
+	if rel == nil {
+		return "", false, false
+	}
+	remoteTable := meta.Table(rel.RemoteSchema, rel.RemoteTable)
+	if remoteTable == nil {
+		return "", false, false
+	}
+	return remoteTable.GraphqlName(), isArray, true
+}
+
+// deletedTables returns the tables deleted from by the mutation root fields of the doc (delete_X, delete_X_by_pk)
+func deletedTables(doc *gqlast.Document) map[string]bool {
+	tables := map[string]bool{}
+	for _, field := range rootFields(doc, "mutation") {
+		if name := field.Name.Value; strings.HasPrefix(name, "delete_") {
+			tables[strings.TrimSuffix(strings.TrimPrefix(name, "delete_"), "_by_pk")] = true
+		}
+	}
+	return tables
+}
+
+// cascadeSource returns the deleted table the rows of the table are deleted with, by (a chain of) ON DELETE CASCADE;
+// empty if none, or unknown without the hasura metadata
+func cascadeSource(meta *hasurameta.Schema, table string, deleted map[string]bool) string {
+	if meta == nil {
+		return ""
+	}
+	visited := map[string]bool{}
+	var source func(table string) string
+	source = func(table string) string {
+		if visited[table] {
+			return ""
+		}
+		visited[table] = true
+		t, found := meta.Tables[table]
+		if !found {
+			return ""
+		}
+		for _, fk := range t.ForeignKeys {
+			refTable := meta.Table(fk.RefSchema, fk.RefTable)
+			if fk.OnDelete != "cascade" || refTable == nil {
+				continue
+			}
+			if deleted[refTable.GraphqlName()] {
+				return refTable.GraphqlName()
+			}
+			if from := source(refTable.GraphqlName()); from != "" {
+				return from
+			}
+		}
+		return ""
+	}
+	return source(table)
+}
@@ -0,0 +1,90 @@
+package graphqlfixture
+
+import (
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	meta, err := hasurameta.Load("example/hasura")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testCases := []struct {
+		name                string
+		givenFixtures       []Fixture
+		givenHasura         *hasurameta.Schema
+		expectedDiagnostics []string
+	}{
+		{
+			name: "nested insert not deleted",
+			givenFixtures: []Fixture{
+				{
+					Setup: `mutation {
+						insert_instructors_one(object: { name: "Murphy", teaches: { data: { subject: { data: { name: "CS101" } } } } }) { id }
+					}`,
+					Captors:  map[string]string{"murphy_id": "/data/insert_instructors_one/id"},
+					Teardown: gopointer.OfString(`mutation ($murphy_id: Int!) { delete_instructors_by_pk(id: $murphy_id) { id } }`),
+				},
+			},
+			expectedDiagnostics: []string{
+				"fixture[0].setup: warning: insert_instructors_one.teaches inserts into teaches, which no teardown deletes from [teardown-coverage]",
+				"fixture[0].setup: warning: insert_instructors_one.teaches.subject inserts into subject, which no teardown deletes from [teardown-coverage]",
+			},
+		},
+		{
+			name: "nested inserts deleted by later fixtures; table names guessed from the relationships",
+			givenFixtures: []Fixture{
+				{
+					Setup:    `mutation { insert_teaches(objects: [{ instructor_id: 1, subject: { data: { name: "CS101" } } }]) { affected_rows } }`,
+					Teardown: gopointer.OfString(`mutation { delete_teaches(where: { instructor_id: { _eq: 1 } }) { affected_rows } }`),
+				},
+				{
+					Setup:    `query { subjects { id } }`,
+					Teardown: gopointer.OfString(`mutation { delete_subjects(where: { name: { _eq: "CS101" } }) { affected_rows } }`),
+				},
+			},
+		},
+		{
+			name: "with the hasura metadata: relationships resolved, cascade known",
+			givenFixtures: []Fixture{
+				{
+					Setup: `mutation {
+						insert_instructors_one(object: { name: "Murphy", teaches: { data: { subject: { data: { name: "CS101" } } } } }) { id }
+					}`,
+					Captors:  map[string]string{"murphy_id": "/data/insert_instructors_one/id"},
+					Teardown: gopointer.OfString(`mutation ($murphy_id: Int!) { delete_instructors_by_pk(id: $murphy_id) { id } }`),
+				},
+			},
+			givenHasura: meta,
+			expectedDiagnostics: []string{
+				"fixture[0].setup: info: insert_instructors_one.teaches inserts into teaches, which no teardown deletes from but ON DELETE CASCADE from instructors [cascade-teardown]",
+				"fixture[0].setup: warning: insert_instructors_one.teaches.subject inserts into subjects, which no teardown deletes from [teardown-coverage]",
+			},
+		},
+		{
+			name: "suppressed",
+			givenFixtures: []Fixture{
+				{
+					Setup:        `mutation { insert_subjects(objects: [{ name: "CS101" }]) { affected_rows } }`,
+					LintSuppress: []string{RuleTeardownCoverage},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixtures := Fixtures{Fixtures: tc.givenFixtures, Hasura: tc.givenHasura}
+			var actual []string
+			for _, diagnostic := range fixtures.ParseDiagnostics() {
+				actual = append(actual, diagnostic.String())
+			}
+			assert.NoError(t, fixtures.parseErr)
+			assert.Equal(t, tc.expectedDiagnostics, actual)
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package graphqlfixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Jeffail/gabs/v2"
+	gqlast "github.com/graphql-go/graphql/language/ast"
+	"github.com/hashicorp/go-multierror"
+	"strconv"
+	"strings"
+)
+
+// DryRun walks the fixtures without any graphql server: each Setup gets a plausible response synthesized from its
+// selection set, the captors run against it, and the teardown variables are bound from the synthesized captures.
+// Every step that would fail is reported (no early exit), e.g., a captor path that doesn't match the setup's
+// selection set, or a list index beyond the number of inserted objects.
+// The fixtures' run state (captured, SetupUntil ..) is not touched; the synthesized captures are returned.
+//
+// The response is synthesized with Hasura conventions:
+//   - insert_X(objects: [...]) returns as many `returning` rows as objects; insert_X_one(object: ..) returns one object
+//   - update_X / delete_X return `affected_rows` 1 and one `returning` row; X_by_pk returns one object
+//   - a query root field X returns a list of one row
+//   - `id` and `*_id` fields get placeholder ids (1, 2, 3 ..) unless given in the inserted object
+//   - other scalar fields echo the inserted value if any, or else the field name as placeholder
+//   - a nested field with selection is a list if it's `returning` or ends with "s" (e.g., `teaches`), else an object;
+//     a nested insert `{ data: [...] }` gives the list length
+func (fs *Fixtures) DryRun() (map[string]interface{}, error) {
+	if !fs.parsed {
+		fs.Parse()
+	}
+	if fs.parseErr != nil {
+		return nil, fmt.Errorf("parse error: %w", fs.parseErr)
+	}
+
+	var multierr *multierror.Error
+	synth := &responseSynthesizer{}
+	captured := map[string]interface{}{}
+	for fIdx, f := range fs.Fixtures {
+		fixtureName := fmt.Sprintf("fixture[%d]", fIdx)
+
+		// 1. setup: synthesize the response
+		if missed := missingVariables(f.setupVariables, captured); len(missed) > 0 {
+			multierr = multierror.Append(multierr,
+				fmt.Errorf("%s.setup: variables not captured by previous fixtures: %s", fixtureName, strings.Join(missed, ", ")))
+		}
+		doc, err := parseGraphql(f.Setup)
+		if err != nil { // shouldn't happen, since the fixtures have passed parsing
+			multierr = multierror.Append(multierr, fmt.Errorf("%s.setup: is invalid. %w", fixtureName, err))
+			continue
+		}
+		var resp *gabs.Container
+		for _, step := range f.setupOperationSteps() {
+			var stepResp *gabs.Container
+			if stepResp, err = synth.synthesize(doc, step.name, captured); err != nil {
+				break
+			}
+			resp = mergeResponses(resp, stepResp)
+		}
+		if err != nil {
+			multierr = multierror.Append(multierr, fmt.Errorf("%s.setup: cannot synthesize response: %w", fixtureName, err))
+			continue
+		}
+
+		// 2. captors: run against the synthesized response
+		for captorName, captorPath := range f.Captors {
+			captorVal, err := captureValue(resp, captorPath)
+			if err != nil {
+				multierr = multierror.Append(multierr,
+					fmt.Errorf("%s.captors: %s (%s) not found: %w", fixtureName, captorName, captorPath, err))
+				continue
+			}
+			captured[captorName] = captorVal
+		}
+
+		// 3. teardown: bind the variables
+		if f.Teardown != nil {
+			if missed := missingVariables(f.teardownVariables, captured); len(missed) > 0 {
+				multierr = multierror.Append(multierr,
+					fmt.Errorf("%s.teardown: variables not captured: %s", fixtureName, strings.Join(missed, ", ")))
+			}
+		}
+	}
+
+	return captured, multierr.ErrorOrNil()
+}
+
+// missingVariables returns the varNames not in captured
+func missingVariables(varNames []string, captured map[string]interface{}) []string {
+	var missed []string
+	for _, varName := range varNames {
+		if _, found := captured[varName]; !found {
+			missed = append(missed, varName)
+		}
+	}
+	return missed
+}
+
+// responseSynthesizer synthesizes graphql responses; placeholder ids are unique across the responses it synthesizes
+type responseSynthesizer struct {
+	lastID    int
+	fragments map[string]*gqlast.FragmentDefinition // of the doc being synthesized
+	variables map[string]interface{}                // of the doc being synthesized
+}
+
+// synthesize returns the response of the named operation in the doc (the first if no name), as if the graphql
+// server returned it
+func (s *responseSynthesizer) synthesize(doc *gqlast.Document, operationName string, variables map[string]interface{}) (*gabs.Container, error) {
+	s.fragments = map[string]*gqlast.FragmentDefinition{}
+	s.variables = variables
+	var operation *gqlast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch node := def.(type) {
+		case *gqlast.OperationDefinition:
+			if operation == nil && (operationName == "" || node.Name != nil && node.Name.Value == operationName) {
+				operation = node
+			}
+		case *gqlast.FragmentDefinition:
+			s.fragments[node.Name.Value] = node
+		}
+	}
+	if operation == nil {
+		return nil, fmt.Errorf("no operation found")
+	}
+
+	data := map[string]interface{}{}
+	for _, field := range flattenFields(operation.SelectionSet, s.fragments) {
+		data[responseKey(field)] = s.rootField(operation.Operation, field)
+	}
+	// round trip through json, to have the same value types as a real response (e.g., numbers as float64)
+	jsonBytes, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return nil, err
+	}
+	return gabs.ParseJSON(jsonBytes)
+}
+
+// rootField synthesizes the value of a root field per Hasura conventions
+func (s *responseSynthesizer) rootField(operation string, field *gqlast.Field) interface{} {
+	name := field.Name.Value
+	args := s.arguments(field)
+	switch {
+	case operation == "mutation" && strings.HasPrefix(name, "insert_") && strings.HasSuffix(name, "_one"):
+		input, _ := args["object"].(map[string]interface{})
+		return s.object(field.SelectionSet, input)
+	case operation == "mutation" && strings.HasPrefix(name, "insert_"):
+		var inputs []interface{}
+		switch objects := args["objects"].(type) {
+		case []interface{}:
+			inputs = objects
+		case map[string]interface{}: // a single object is accepted as a list of one
+			inputs = []interface{}{objects}
+		}
+		return s.mutationResponse(field.SelectionSet, inputs)
+	case strings.HasSuffix(name, "_by_pk"):
+		return s.object(field.SelectionSet, args)
+	case operation == "mutation":
+		return s.mutationResponse(field.SelectionSet, []interface{}{nil})
+	case strings.HasSuffix(name, "_aggregate"):
+		return s.object(field.SelectionSet, nil)
+	default:
+		return []interface{}{s.object(field.SelectionSet, nil)}
+	}
+}
+
+// mutationResponse synthesizes the Hasura mutation response: { affected_rows, returning }
+func (s *responseSynthesizer) mutationResponse(selectionSet *gqlast.SelectionSet, inputs []interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, field := range flattenFields(selectionSet, s.fragments) {
+		switch field.Name.Value {
+		case "affected_rows":
+			result[responseKey(field)] = len(inputs)
+		case "returning":
+			rows := make([]interface{}, 0, len(inputs))
+			for _, input := range inputs {
+				inputMap, _ := input.(map[string]interface{})
+				rows = append(rows, s.object(field.SelectionSet, inputMap))
+			}
+			result[responseKey(field)] = rows
+		default:
+			result[responseKey(field)] = s.value(field, nil)
+		}
+	}
+	return result
+}
+
+// object synthesizes an object of the selection set; input is the inserted object (if any) to echo the values
+func (s *responseSynthesizer) object(selectionSet *gqlast.SelectionSet, input map[string]interface{}) map[string]interface{} {
+	if selectionSet == nil {
+		return nil
+	}
+	obj := map[string]interface{}{}
+	for _, field := range flattenFields(selectionSet, s.fragments) {
+		var inputVal interface{}
+		if input != nil {
+			inputVal = input[field.Name.Value]
+		}
+		obj[responseKey(field)] = s.value(field, inputVal)
+	}
+	return obj
+}
+
+// value synthesizes the value of a (non-root) field
+func (s *responseSynthesizer) value(field *gqlast.Field, inputVal interface{}) interface{} {
+	name := field.Name.Value
+	if field.SelectionSet == nil { // scalar
+		switch {
+		case inputVal != nil:
+			return inputVal
+		case name == "id" || strings.HasSuffix(name, "_id"):
+			s.lastID++
+			return s.lastID
+		case name == "__typename":
+			return "placeholder"
+		case name == "affected_rows" || name == "count":
+			return 1
+		default:
+			return name
+		}
+	}
+
+	// nested object or list: a nested insert looks like { data: [...] } or { data: {...} }
+	var nestedInputs []interface{}
+	if inputMap, ok := inputVal.(map[string]interface{}); ok {
+		switch data := inputMap["data"].(type) {
+		case []interface{}:
+			nestedInputs = data
+		case map[string]interface{}:
+			nestedInputs = []interface{}{data}
+		}
+	}
+	if name == "returning" || strings.HasSuffix(name, "s") {
+		if nestedInputs == nil {
+			nestedInputs = []interface{}{nil}
+		}
+		list := make([]interface{}, 0, len(nestedInputs))
+		for _, nestedInput := range nestedInputs {
+			nestedInputMap, _ := nestedInput.(map[string]interface{})
+			list = append(list, s.object(field.SelectionSet, nestedInputMap))
+		}
+		return list
+	}
+	var nestedInputMap map[string]interface{}
+	if len(nestedInputs) > 0 {
+		nestedInputMap, _ = nestedInputs[0].(map[string]interface{})
+	}
+	return s.object(field.SelectionSet, nestedInputMap)
+}
+
+// arguments returns the field's arguments as go values, with variables resolved
+func (s *responseSynthesizer) arguments(field *gqlast.Field) map[string]interface{} {
+	args := map[string]interface{}{}
+	for _, arg := range field.Arguments {
+		args[arg.Name.Value] = astValue(arg.Value, s.variables)
+	}
+	return args
+}
+
+// responseKey returns the key of the field in the response: the alias if any, otherwise the field name
+func responseKey(field *gqlast.Field) string {
+	if field.Alias != nil {
+		return field.Alias.Value
+	}
+	return field.Name.Value
+}
+
+// astValue converts the graphql literal into go value (as json-decoded); variables are resolved from `variables`
+func astValue(value gqlast.Value, variables map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case *gqlast.Variable:
+		return variables[v.Name.Value]
+	case *gqlast.IntValue:
+		i, _ := strconv.ParseFloat(v.Value, 64)
+		return i
+	case *gqlast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *gqlast.StringValue:
+		return v.Value
+	case *gqlast.BooleanValue:
+		return v.Value
+	case *gqlast.EnumValue:
+		return v.Value
+	case *gqlast.ListValue:
+		list := make([]interface{}, 0, len(v.Values))
+		for _, elem := range v.Values {
+			list = append(list, astValue(elem, variables))
+		}
+		return list
+	case *gqlast.ObjectValue:
+		obj := map[string]interface{}{}
+		for _, field := range v.Fields {
+			obj[field.Name.Value] = astValue(field.Value, variables)
+		}
+		return obj
+	}
+	return nil
+}
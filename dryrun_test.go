@@ -0,0 +1,117 @@
+package graphqlfixture
+
+import (
+	"github.com/gmm1900/gopointer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	testCases := []struct {
+		name             string
+		fixtures         []Fixture
+		expectedCaptured map[string]interface{}
+		expectedErrs     []string
+	}{
+		{
+			name: "captures from inserted objects, nested inserts and echoed values",
+			fixtures: []Fixture{
+				{
+					Setup: `mutation {
+						insert_subjects(objects: [{ name: "CS101" }, { name: "CS102" }]) { returning { id name } }
+					}`,
+					Captors: map[string]string{
+						"cs101_id":   "/data/insert_subjects/returning/0/id",
+						"cs102_name": "/data/insert_subjects/returning/1/name",
+					},
+					Teardown: gopointer.OfString(`mutation ($cs101_id: Int!) { delete_subjects(where: { id: { _eq: $cs101_id } }) { affected_rows } }`),
+				},
+				{
+					Setup: `mutation ($cs101_id: Int!) {
+						insert_instructors_one(object: { name: "Murphy", teaches: { data: [{ subject_id: $cs101_id }, { subject_id: 9 }] } }) {
+							id
+							teaches { subject_id subject { name } }
+						}
+					}`,
+					Captors: map[string]string{
+						"murphy_id":          "/data/insert_instructors_one/id",
+						"murphy_subject_id":  "/data/insert_instructors_one/teaches/0/subject_id",
+						"murphy_subject2_id": "/data/insert_instructors_one/teaches/1/subject_id",
+						"murphy_subject":     "/data/insert_instructors_one/teaches/0/subject/name",
+					},
+				},
+			},
+			expectedCaptured: map[string]interface{}{
+				"cs101_id":           float64(1),
+				"cs102_name":         "CS102",
+				"murphy_id":          float64(3),
+				"murphy_subject_id":  float64(1),
+				"murphy_subject2_id": float64(9),
+				"murphy_subject":     "name",
+			},
+		},
+		{
+			name: "every failing step is reported",
+			fixtures: []Fixture{
+				{
+					Setup: `mutation { insert_abc(objects: [{ name: "abc1" }]) { returning { id } } }`,
+					Captors: map[string]string{
+						"abc_id": "/data/insert_abc/returning/1/id", // only one object inserted
+					},
+					Teardown: gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+				},
+				{
+					Setup: `mutation ($abc_id: Int!) { insert_def_one(object: { abc_id: $abc_id }) { id } }`,
+					Captors: map[string]string{
+						"def_id": "/data/insert_def/id", // the field is insert_def_one
+					},
+				},
+			},
+			expectedCaptured: map[string]interface{}{},
+			expectedErrs: []string{
+				"fixture[0].captors: abc_id (/data/insert_abc/returning/1/id) not found",
+				"fixture[0].teardown: variables not captured: abc_id",
+				"fixture[1].setup: variables not captured by previous fixtures: abc_id",
+				"fixture[1].captors: def_id (/data/insert_def/id) not found",
+			},
+		},
+		{
+			name: "queries and aliases",
+			fixtures: []Fixture{
+				{
+					Setup:   `query { first: abc(limit: 1) { id } abc_by_pk(id: 7) { id name } }`,
+					Captors: map[string]string{"first_id": "/data/first/0/id", "abc": "/data/abc_by_pk"},
+				},
+			},
+			expectedCaptured: map[string]interface{}{
+				"first_id": float64(1),
+				"abc":      map[string]interface{}{"id": float64(7), "name": "name"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixtures := Fixtures{Fixtures: tc.fixtures}
+			captured, err := fixtures.DryRun()
+			assert.Equal(t, tc.expectedCaptured, captured)
+			if len(tc.expectedErrs) == 0 {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				for _, expectedErr := range tc.expectedErrs {
+					assert.Contains(t, err.Error(), expectedErr)
+				}
+			}
+			// the run state is untouched
+			assert.Nil(t, fixtures.SetupUntil())
+			assert.Empty(t, fixtures.Events())
+		})
+	}
+}
+
+func TestDryRunParseError(t *testing.T) {
+	fixtures := Fixtures{Fixtures: []Fixture{{Setup: `mutation { insert_abc(`}}}
+	_, err := fixtures.DryRun()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "parse error")
+}
@@ -0,0 +1,154 @@
+package graphqlfixture
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Hasura error codes, found in the `extensions.code` of a graphql error.
+// See https://hasura.io/docs/latest/graphql/core/api-reference/graphql-api/errors.html (not an exhaustive list)
+const (
+	CodeConstraintViolation = "constraint-violation" // e.g., unique or foreign key violation
+	CodeValidationFailed    = "validation-failed"    // e.g., unknown field, wrong argument type
+	CodePermissionError     = "permission-error"     // the role is not allowed to perform the operation
+	CodeAccessDenied        = "access-denied"        // e.g., admin secret missing or wrong
+	CodeDataException       = "data-exception"       // e.g., invalid input syntax for a column type
+	CodeUnexpected          = "unexpected"           // hasura internal error
+)
+
+// GraphQLErrorLocation is the line/column in the graphql document where the error is reported
+type GraphQLErrorLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is a single entry from the `errors` of a graphql response.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"` // string (field name) or number (list index) segments
+	Locations  []GraphQLErrorLocation `json:"locations,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"` // hasura puts `code` and `path` (json path into the request) here
+}
+
+func (e *GraphQLError) Error() string {
+	if code := e.Code(); code != "" {
+		return fmt.Sprintf("%s (%s)", e.Message, code)
+	}
+	return e.Message
+}
+
+// Code returns `extensions.code` (empty if not available)
+func (e *GraphQLError) Code() string {
+	if e.Extensions == nil {
+		return ""
+	}
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// ResponseError is returned when the graphql response contains `errors`.
+// Usable with errors.As from Setup and Teardown errors; the first graphql error can also be
+// extracted directly with errors.As into *GraphQLError.
+type ResponseError struct {
+	Errors []*GraphQLError // parsed from the response; can be empty if the `errors` is not in the expected shape
+	Raw    interface{}     // the `errors` as-is from the response
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("graphql response contains error: %v", e.Raw)
+}
+
+// Unwrap returns the first graphql error (if any)
+func (e *ResponseError) Unwrap() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e.Errors[0]
+}
+
+// Codes returns the `extensions.code` of all the graphql errors (skipping those without code)
+func (e *ResponseError) Codes() []string {
+	var codes []string
+	for _, gqlErr := range e.Errors {
+		if code := gqlErr.Code(); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// HasCode reports whether any of the graphql errors has the given `extensions.code`
+func (e *ResponseError) HasCode(code string) bool {
+	for _, c := range e.Codes() {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// newResponseError converts the `errors` from the graphql response (already json-decoded) to ResponseError.
+// The spec says `errors` is a list, but a single error object is tolerated too.
+func newResponseError(raw interface{}) *ResponseError {
+	respErr := &ResponseError{Raw: raw}
+
+	var rawErrs []interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		rawErrs = v
+	case map[string]interface{}:
+		rawErrs = []interface{}{v}
+	}
+
+	for _, rawErr := range rawErrs {
+		m, ok := rawErr.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		gqlErr := &GraphQLError{}
+		gqlErr.Message, _ = m["message"].(string)
+		gqlErr.Path, _ = m["path"].([]interface{})
+		gqlErr.Extensions, _ = m["extensions"].(map[string]interface{})
+		if locs, ok := m["locations"].([]interface{}); ok {
+			for _, loc := range locs {
+				locMap, ok := loc.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				line, _ := locMap["line"].(float64)
+				column, _ := locMap["column"].(float64)
+				gqlErr.Locations = append(gqlErr.Locations, GraphQLErrorLocation{Line: int(line), Column: int(column)})
+			}
+		}
+		respErr.Errors = append(respErr.Errors, gqlErr)
+	}
+	return respErr
+}
+
+// HasErrorCode reports whether err (or any error it wraps) is a graphql response error with the given `extensions.code`
+func HasErrorCode(err error, code string) bool {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HasCode(code)
+	}
+	var gqlErr *GraphQLError
+	if errors.As(err, &gqlErr) {
+		return gqlErr.Code() == code
+	}
+	return false
+}
+
+// IsConstraintViolation reports whether err is caused by a hasura `constraint-violation`, e.g., unique key or foreign key
+func IsConstraintViolation(err error) bool {
+	return HasErrorCode(err, CodeConstraintViolation)
+}
+
+// IsValidationFailed reports whether err is caused by a hasura `validation-failed`, e.g., the graphql doesn't match the schema
+func IsValidationFailed(err error) bool {
+	return HasErrorCode(err, CodeValidationFailed)
+}
+
+// IsPermissionError reports whether err is caused by a hasura `permission-error` or `access-denied`
+func IsPermissionError(err error) bool {
+	return HasErrorCode(err, CodePermissionError) || HasErrorCode(err, CodeAccessDenied)
+}
@@ -0,0 +1,114 @@
+package graphqlfixture
+
+import (
+	"context"
+	"errors"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestNewResponseError(t *testing.T) {
+	testCases := []struct {
+		name             string
+		givenRaw         interface{}
+		expectedErrors   []*GraphQLError
+		expectedCodes    []string
+		expectedErrorStr string
+	}{
+		{
+			name: "hasura constraint violation",
+			givenRaw: []interface{}{
+				map[string]interface{}{
+					"message":    `Uniqueness violation. duplicate key value violates unique constraint "students_name_key"`,
+					"extensions": map[string]interface{}{"code": "constraint-violation", "path": "$.selectionSet.insert_students.args.objects"},
+				},
+			},
+			expectedErrors: []*GraphQLError{
+				{
+					Message:    `Uniqueness violation. duplicate key value violates unique constraint "students_name_key"`,
+					Extensions: map[string]interface{}{"code": "constraint-violation", "path": "$.selectionSet.insert_students.args.objects"},
+				},
+			},
+			expectedCodes:    []string{CodeConstraintViolation},
+			expectedErrorStr: `graphql response contains error: [map[extensions:map[code:constraint-violation path:$.selectionSet.insert_students.args.objects] message:Uniqueness violation. duplicate key value violates unique constraint "students_name_key"]]`,
+		},
+		{
+			name: "spec error with path and locations",
+			givenRaw: []interface{}{
+				map[string]interface{}{
+					"message":   "cannot query field",
+					"path":      []interface{}{"students", 0.0, "name"},
+					"locations": []interface{}{map[string]interface{}{"line": 2.0, "column": 5.0}},
+				},
+			},
+			expectedErrors: []*GraphQLError{
+				{
+					Message:   "cannot query field",
+					Path:      []interface{}{"students", 0.0, "name"},
+					Locations: []GraphQLErrorLocation{{Line: 2, Column: 5}},
+				},
+			},
+			expectedCodes:    nil,
+			expectedErrorStr: "graphql response contains error: [map[locations:[map[column:5 line:2]] message:cannot query field path:[students 0 name]]]",
+		},
+		{
+			name:             "single error object instead of list",
+			givenRaw:         map[string]interface{}{"extensions": map[string]interface{}{}},
+			expectedErrors:   []*GraphQLError{{Extensions: map[string]interface{}{}}},
+			expectedCodes:    nil,
+			expectedErrorStr: "graphql response contains error: map[extensions:map[]]",
+		},
+		{
+			name:             "unexpected shape",
+			givenRaw:         "boom",
+			expectedErrors:   nil,
+			expectedCodes:    nil,
+			expectedErrorStr: "graphql response contains error: boom",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// WHEN
+			respErr := newResponseError(tc.givenRaw)
+			// THEN
+			assert.Equal(t, tc.expectedErrors, respErr.Errors)
+			assert.Equal(t, tc.expectedCodes, respErr.Codes())
+			assert.EqualError(t, respErr, tc.expectedErrorStr)
+		})
+	}
+}
+
+func TestSetupErrorClassification(t *testing.T) {
+	// GIVEN
+	mockServer := graphqlclient.MockGraphqlServer{
+		MockedRespBody: [][]byte{
+			[]byte(`{ "errors": [ { "message": "Foreign key violation.", "extensions": { "code": "constraint-violation", "path": "$" } } ] }`),
+		},
+	}
+	mockServer.Start(t)
+	defer mockServer.Close()
+	fixtures := Fixtures{
+		Fixtures: []Fixture{
+			{Setup: `mutation { insert_abc(objects: { parent_id: 1 }) { affected_rows } }`},
+		},
+	}
+
+	// WHEN
+	err := fixtures.Setup(context.Background(), graphqlclient.New(mockServer.URL, nil, http.Header{}))
+
+	// THEN
+	var respErr *ResponseError
+	if assert.True(t, errors.As(err, &respErr)) {
+		assert.Equal(t, []string{CodeConstraintViolation}, respErr.Codes())
+	}
+	var gqlErr *GraphQLError
+	if assert.True(t, errors.As(err, &gqlErr)) {
+		assert.Equal(t, "Foreign key violation.", gqlErr.Message)
+	}
+	assert.True(t, IsConstraintViolation(err))
+	assert.False(t, IsValidationFailed(err))
+	assert.False(t, IsPermissionError(err))
+}
@@ -0,0 +1,109 @@
+package graphqlfixture
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Phase is the step of a fixture that an Event is about
+type Phase string
+
+const (
+	PhaseSetup    Phase = "setup"
+	PhaseCaptors  Phase = "captors"
+	PhaseTeardown Phase = "teardown"
+)
+
+// Status is the outcome of the Phase that an Event reports
+type Status string
+
+const (
+	StatusCompleted        Status = "completed"
+	StatusFailed           Status = "failed"
+	StatusSkipped          Status = "skipped"           // the fixture doesn't have this phase, e.g., no captors, no teardown
+	StatusAttemptFailed    Status = "attempt-failed"    // one attempt of a retried graphql request failed (see Event.WillRetry)
+	StatusAttemptSucceeded Status = "attempt-succeeded" // one attempt of a retried graphql request succeeded
+)
+
+// Event is a structured log entry of the setup and teardown execution.
+type Event struct {
+	Time        time.Time     `json:"time"`
+	FixtureIdx  int           `json:"fixtureIdx"`
+	FixtureName string        `json:"fixtureName,omitempty"` // Fixture.Name, if given
+	Phase       Phase         `json:"phase"`
+	Status      Status        `json:"status"`
+	Duration    time.Duration `json:"duration"`            // how long the phase (or the attempt) took
+	Operation   string        `json:"operation,omitempty"` // the graphql operation type of the request, e.g., "mutation"
+	// of a setup or teardown with several named operations: the (last) operation sent, e.g., the one that failed
+	OperationName string        `json:"operationName,omitempty"`
+	Captures      int           `json:"captures,omitempty"` // number of values captured (captors phase)
+	Attempt       int           `json:"attempt,omitempty"`  // only for attempt events: 1-based attempt number
+	MaxAttempts   int           `json:"maxAttempts,omitempty"`
+	WillRetry     bool          `json:"willRetry,omitempty"` // only for failed attempt: whether another attempt follows
+	Backoff       time.Duration `json:"backoff,omitempty"`   // only for failed attempt that will retry: wait before the next attempt
+	Error         string        `json:"error,omitempty"`
+}
+
+// Step returns the name of the fixture step, e.g., "fixture[1].setup"
+func (e Event) Step() string {
+	return fmt.Sprintf("fixture[%d].%s", e.FixtureIdx, e.Phase)
+}
+
+// String renders the event as a human readable log line (as returned by Logs())
+func (e Event) String() string {
+	switch e.Status {
+	case StatusCompleted:
+		if e.Phase == PhaseCaptors {
+			return fmt.Sprintf("%s: completed with %d capture(s)", e.Step(), e.Captures)
+		}
+		return fmt.Sprintf("%s: completed", e.Step())
+	case StatusSkipped:
+		return fmt.Sprintf("%s: not exist", e.Step())
+	case StatusFailed:
+		return fmt.Sprintf("%s failed: %s", e.Step(), e.Error)
+	case StatusAttemptSucceeded:
+		return fmt.Sprintf("%s: attempt %d/%d succeeded", e.Step(), e.Attempt, e.MaxAttempts)
+	case StatusAttemptFailed:
+		if e.WillRetry {
+			return fmt.Sprintf("%s: attempt %d/%d failed, retry in %v: %s",
+				e.Step(), e.Attempt, e.MaxAttempts, e.Backoff.Round(time.Millisecond), e.Error)
+		}
+		return fmt.Sprintf("%s: attempt %d/%d failed, giving up: %s", e.Step(), e.Attempt, e.MaxAttempts, e.Error)
+	}
+	return fmt.Sprintf("%s: %s", e.Step(), e.Status)
+}
+
+// Events returns the structured log of setup and teardown, in the order they happened
+func (fs *Fixtures) Events() []Event {
+	return fs.events
+}
+
+// newEvent creates an event for the fixture's phase; Time is set to now
+func (fs *Fixtures) newEvent(fIdx int, phase Phase, status Status) Event {
+	return Event{
+		Time:        time.Now(),
+		FixtureIdx:  fIdx,
+		FixtureName: fs.Fixtures[fIdx].Name,
+		Phase:       phase,
+		Status:      status,
+	}
+}
+
+// record keeps the event (with secrets redacted), and its rendered form in logs
+func (fs *Fixtures) record(e Event) {
+	e.Error = fs.redactText(e.Error)
+	fs.events = append(fs.events, e)
+	fs.logs = append(fs.logs, e.String())
+}
+
+// recordFailure records the failed event with the err, and returns err prefixed with the step name.
+// The OnError hooks are called with the returned error.
+func (fs *Fixtures) recordFailure(ctx context.Context, e Event, err error) error {
+	e.Status = StatusFailed
+	e.Error = err.Error()
+	fs.record(e)
+	stepErr := fs.redactError(fmt.Errorf("%s failed: %w", e.Step(), err))
+	fs.runOnErrorHooks(ctx, e.FixtureIdx, stepErr)
+	return stepErr
+}
@@ -0,0 +1,87 @@
+package graphqlfixture
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	// GIVEN
+	responses := [][]byte{
+		[]byte(`{ "data": { "insert_abc": { "returning": [ { "id": 13 } ] } } }`),
+		[]byte(`{ "data": { "abc": [ { "id": 13 } ] } }`),
+		[]byte(`{ "errors": [ { "message": "boom", "extensions": { "code": "unexpected" } } ] }`),
+	}
+	calls := 0
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = responses[calls]
+		calls++
+		return nil
+	})
+	fixtures := Fixtures{
+		Fixtures: []Fixture{
+			{
+				Name:     "abc",
+				Setup:    `mutation { insert_abc(objects: { name: "abc1"}) { returning { id } } }`,
+				Captors:  map[string]string{"abc_id": "/data/insert_abc/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+			},
+			{
+				Setup: `query { abc { id } }`,
+			},
+		},
+	}
+
+	// WHEN
+	assert.NoError(t, fixtures.Setup(context.Background(), executor))
+	assert.Error(t, fixtures.Teardown(context.Background(), executor))
+
+	// THEN
+	expectedEvents := []Event{
+		{FixtureIdx: 0, FixtureName: "abc", Phase: PhaseSetup, Status: StatusCompleted, Operation: "mutation"},
+		{FixtureIdx: 0, FixtureName: "abc", Phase: PhaseCaptors, Status: StatusCompleted, Captures: 1},
+		{FixtureIdx: 1, Phase: PhaseSetup, Status: StatusCompleted, Operation: "query"},
+		{FixtureIdx: 1, Phase: PhaseCaptors, Status: StatusSkipped},
+		{FixtureIdx: 1, Phase: PhaseTeardown, Status: StatusSkipped},
+		{FixtureIdx: 0, FixtureName: "abc", Phase: PhaseTeardown, Status: StatusFailed, Operation: "mutation",
+			Error: "graphql response contains error: [map[extensions:map[code:unexpected] message:boom]]"},
+	}
+	cmpOpts := cmpopts.IgnoreFields(Event{}, "Time", "Duration")
+	if !cmp.Equal(expectedEvents, fixtures.Events(), cmpOpts) {
+		t.Errorf("Events mismatched %v", cmp.Diff(expectedEvents, fixtures.Events(), cmpOpts))
+	}
+	for i, e := range fixtures.Events() {
+		assert.False(t, e.Time.IsZero())
+		assert.Equal(t, e.String(), fixtures.Logs()[i]) // logs is the rendered view of events
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	e := Event{
+		Time:        time.Date(2021, 2, 14, 16, 40, 28, 0, time.UTC),
+		FixtureIdx:  2,
+		Phase:       PhaseSetup,
+		Status:      StatusAttemptFailed,
+		Duration:    1500 * time.Millisecond,
+		Operation:   "query",
+		Attempt:     1,
+		MaxAttempts: 3,
+		WillRetry:   true,
+		Backoff:     100 * time.Millisecond,
+		Error:       "connection refused",
+	}
+	jsonBytes, err := json.Marshal(e)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"time": "2021-02-14T16:40:28Z", "fixtureIdx": 2, "phase": "setup", "status": "attempt-failed", "duration": 1500000000,
+		"operation": "query", "attempt": 1, "maxAttempts": 3, "willRetry": true, "backoff": 100000000, "error": "connection refused"
+	}`, string(jsonBytes))
+	assert.Equal(t, "fixture[2].setup: attempt 1/3 failed, retry in 100ms: connection refused", e.String())
+}
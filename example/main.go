@@ -2,14 +2,12 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/gmm1900/gopointer"
 	"github.com/gmm1900/graphqlclient"
 	"github.com/gmm1900/graphqlfixture"
 	"log"
 	"net/http"
-	"syscall"
 	"time"
 )
 
@@ -132,30 +130,21 @@ func main() {
 	ctx := context.Background()
 
 	// wait for max 2min for the hasura-server to be up
-	ctx2Min, cancel := context.WithTimeout(ctx, 2 * time.Minute)
-	defer cancel()
-
-WAIT_FOR_HASURA_SERVER:
-	for {
-		select {
-		case <-ctx2Min.Done():
-			log.Fatal("2 minutes reached; still unable to reach hasura-server")
-		default:
-			// send an empty request: just to test if the hasura-server is up
-			var anyResp string
-			err := graphqlClient.Do(ctx, graphqlclient.Request{ Query: `query { students { id } } `}, &anyResp)
-			if err == nil {
-				break WAIT_FOR_HASURA_SERVER
-			}
-			if errors.Is(err, syscall.ECONNREFUSED) {
-				log.Println("hasura-server is not yet up; retry in 5 sec...")
-				time.Sleep(5 * time.Second)
-			}
-		}
+	readyInterval := 5 * time.Second
+	err := graphqlfixture.WaitReady(ctx, graphqlClient, graphqlfixture.ReadyOptions{
+		Query: `query { students { id } }`,
+		Interval: readyInterval,
+		Timeout: 2 * time.Minute,
+		Progress: func(attempt int, err error) {
+			log.Printf("hasura-server is not yet up; retry in %v...", readyInterval)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// call fixture setup
-	err := fixtures.Setup(ctx, graphqlClient)
+	err = fixtures.Setup(ctx, graphqlClient)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -6,10 +6,25 @@ import (
 	"fmt"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/gmm1900/graphqlclient"
+	"strings"
+	"time"
 )
 
 // Setup calls each fixture's Setup (graphql call) in sequence, and captures the values from the responses.
-func (fs *Fixtures) Setup(ctx context.Context, graphqlClient *graphqlclient.Client) error {
+// With AtomicSetup, the independent mutations are merged into one request (see setupGroups); with BatchSetup, the
+// independent queries are sent in one batch (see batchSize).
+func (fs *Fixtures) Setup(ctx context.Context, graphqlClient Executor) error {
+	ctx, span := fs.tracer().Start(ctx, SpanSetup, Attr(AttrFixtureCount, len(fs.Fixtures)))
+	err := fs.setup(ctx, graphqlClient)
+	if remediationErr := fs.writeRemediationFile(); err == nil {
+		err = remediationErr
+	}
+	err = fs.redactError(err)
+	fs.endSpan(span, err)
+	return err
+}
+
+func (fs *Fixtures) setup(ctx context.Context, graphqlClient Executor) error {
 	if !fs.parsed {
 		fs.Parse()
 	}
@@ -26,43 +41,213 @@ func (fs *Fixtures) Setup(ctx context.Context, graphqlClient *graphqlclient.Clie
 	// - fs.setupUntilIdx records the last successful setupUntilIdx
 	fs.captured = map[string]interface{}{}
 
-	for fIdx, f := range fs.Fixtures {
-		fixtureName := fmt.Sprintf("fixture[%d]", fIdx)
+	// one request per fixture; or per group of fixtures merged into one mutation, with AtomicSetup;
+	// or the consecutive independent queries in one batch, with BatchSetup
+	groups := fs.setupGroups()
+	for gIdx := 0; gIdx < len(groups); gIdx++ {
+		if batchSize := fs.batchSize(groups[gIdx:], graphqlClient); batchSize > 1 {
+			if err := fs.setupBatch(ctx, graphqlClient.(BatchExecutor), groups[gIdx:gIdx+batchSize]); err != nil {
+				return err
+			}
+			gIdx += batchSize - 1
+			continue
+		}
+		if err := fs.runSetupHooks(ctx, groups[gIdx], PhaseBeforeSetup); err != nil {
+			return err
+		}
+		jsonParsedResp, setupEvent, err := fs.sendSetup(ctx, graphqlClient, groups[gIdx])
+		if err != nil {
+			return err
+		}
+		if err := fs.completeSetup(ctx, groups[gIdx], setupEvent, jsonParsedResp); err != nil {
+			return err
+		}
+	}
 
-		// 1. execute setup
-		jsonParsedResp, err := doGraphqlRequest(ctx, graphqlClient, f.Setup, f.setupVariables, fs.captured)
+	return nil
+}
+
+// runSetupHooks runs the hooks of the phase for each fixture of the group
+func (fs *Fixtures) runSetupHooks(ctx context.Context, group setupGroup, phase Phase) error {
+	for _, fIdx := range group.fixtureIdxs {
+		if err := fs.runHooks(ctx, fIdx, phase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendSetup sends the setup request of the group (with retry), and returns the response and the setup event
+// (of the group's first fixture) to be completed; the failure is already recorded.
+func (fs *Fixtures) sendSetup(ctx context.Context, graphqlClient Executor, group setupGroup) (*gabs.Container, Event, error) {
+	firstIdx := group.fixtureIdxs[0]
+	setupEvent := fs.newEvent(firstIdx, PhaseSetup, StatusCompleted)
+	setupEvent.Operation = group.operation
+	setupCtx, span := fs.startFixtureSpan(ctx, SpanFixtureSetup, firstIdx)
+	setupCtx, cancel := withOptionalTimeout(setupCtx, group.timeout)
+	// the operations in sequence, their responses merged
+	var jsonParsedResp *gabs.Container
+	var err error
+	persisted := false // whether a step other than a query has succeeded
+	for _, step := range group.steps {
+		if len(group.steps) > 1 {
+			setupEvent.OperationName = step.name
+		}
+		var stepResp *gabs.Container
+		stepResp, err = fs.doGraphqlRequestWithRetry(setupCtx, graphqlClient, setupEvent, group.setup, step)
 		if err != nil {
-			return fs.logAndReturnError("%s.setup failed: %w", fixtureName, err)
+			if len(group.steps) > 1 {
+				err = fmt.Errorf("operation %s: %w", step.name, err)
+			}
+			break
+		}
+		jsonParsedResp = mergeResponses(jsonParsedResp, stepResp)
+		persisted = persisted || step.operation != "query"
+	}
+	cancel()
+	fs.endSpan(span, err)
+	setupEvent.Duration = time.Since(setupEvent.Time)
+	if err != nil {
+		if merged := group.merged(); merged != "" {
+			err = fmt.Errorf("merged setup of %s: %w", merged, err)
+		}
+		if persisted {
+			fs.setupPartially(group, jsonParsedResp)
 		}
-		// reach here: the setup is done (if the graphql is mutation, the data is already persisted)
-		// then teardown needs to start at least from this fixture.
-		fs.logs = append(fs.logs, fmt.Sprintf("%s.setup: completed", fixtureName))
-		setupUntilIdx := fIdx // make a copy
-		fs.setupUntilIdx = &setupUntilIdx
+		return nil, setupEvent, fs.recordFailure(ctx, setupEvent, err)
+	}
+	return jsonParsedResp, setupEvent, nil
+}
 
-		// 2. captures from response
-		if len(f.Captors) == 0 {
-			fs.logs = append(fs.logs, fmt.Sprintf("%s.captors: not exist", fixtureName))
-			return nil
-		}
-		// reach here: there are captures to handle
-		for captorName, captorPath := range f.Captors {
-			// captorVal can be single value, or map, or array.
-			capturedGabsObj, err := jsonParsedResp.JSONPointer(captorPath)
-			if err != nil {
-				return fs.logAndReturnError("%s.captors failed: %s (%s) not found: %w", fixtureName, captorName, captorPath, err)
+// setupPartially records the group as setup, when a later operation failed after the data of an earlier one is
+// persisted: teardown (and remediation) need to start at least from its last fixture. The captors found in the
+// responses so far are captured, for the teardown to use; the missing ones are left for the teardown to report.
+func (fs *Fixtures) setupPartially(group setupGroup, jsonParsedResp *gabs.Container) {
+	setupUntilIdx := group.fixtureIdxs[len(group.fixtureIdxs)-1] // make a copy
+	fs.setupUntilIdx = &setupUntilIdx
+	for i, fIdx := range group.fixtureIdxs {
+		for captorName, captorPath := range fs.Fixtures[fIdx].Captors {
+			if captorVal, err := captureValue(group.response(i, jsonParsedResp), captorPath); err == nil {
+				fs.captured[captorName] = captorVal
 			}
-			fs.captured[captorName] = capturedGabsObj.Data()
 		}
-		// reach here: captures are done
-		fs.logs = append(fs.logs, fmt.Sprintf("%s.captors: completed with %d capture(s)", fixtureName, len(f.Captors)))
 	}
+}
+
+// completeSetup records the group's setup as done, then captures from the response, and runs the AfterSetup hooks
+func (fs *Fixtures) completeSetup(ctx context.Context, group setupGroup, setupEvent Event, jsonParsedResp *gabs.Container) error {
+	// reach here: the setup is done (if the graphql is mutation, the data is already persisted)
+	// then teardown needs to start at least from this fixture (the last of the merged ones).
+	for _, fIdx := range group.fixtureIdxs {
+		memberEvent := setupEvent
+		memberEvent.FixtureIdx, memberEvent.FixtureName = fIdx, fs.Fixtures[fIdx].Name
+		fs.record(memberEvent)
+	}
+	setupUntilIdx := group.fixtureIdxs[len(group.fixtureIdxs)-1] // make a copy
+	fs.setupUntilIdx = &setupUntilIdx
+
+	for i, fIdx := range group.fixtureIdxs {
+		// 2. captures from response
+		err := fs.capture(ctx, fIdx, group.response(i, jsonParsedResp))
+		// keep the remediation file up-to-date in case the process dies before Setup returns.
+		// best effort: the error (if any) is reported at the end of Setup
+		_ = fs.writeRemediationFile()
+		if err != nil {
+			return err
+		}
+
+		if err := fs.runHooks(ctx, fIdx, PhaseAfterSetup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// capture extracts the values from the fixture's setup response by its captors into fs.captured
+func (fs *Fixtures) capture(ctx context.Context, fIdx int, jsonParsedResp *gabs.Container) (err error) {
+	f := fs.Fixtures[fIdx]
+	if len(f.Captors) == 0 {
+		// nothing to capture: the setup goes on with the next fixture
+		fs.record(fs.newEvent(fIdx, PhaseCaptors, StatusSkipped))
+		return nil
+	}
+	// reach here: there are captures to handle
+	ctx, span := fs.startFixtureSpan(ctx, SpanFixtureCaptors, fIdx)
+	defer func() { fs.endSpan(span, err) }()
+	captorsEvent := fs.newEvent(fIdx, PhaseCaptors, StatusCompleted)
+	for captorName, captorPath := range f.Captors {
+		// captorVal can be single value, or map, or array.
+		captorVal, err := captureValue(jsonParsedResp, captorPath)
+		if err != nil {
+			captorsEvent.Duration = time.Since(captorsEvent.Time)
+			return fs.recordFailure(ctx, captorsEvent, fmt.Errorf("%s (%s) not found: %w", captorName, captorPath, err))
+		}
+		fs.captured[captorName] = captorVal
+	}
+	// reach here: captures are done
+	captorsEvent.Captures = len(f.Captors)
+	captorsEvent.Duration = time.Since(captorsEvent.Time)
+	fs.record(captorsEvent)
+	span.SetAttributes(Attr(AttrCaptureCount, len(f.Captors)))
 	return nil
 }
 
-// Teardown calls each fixture's Teardown (graphql call) in reverse sequence.
-func (fs *Fixtures) Teardown(ctx context.Context, graphqlClient *graphqlclient.Client) error {
+// captureValue extracts the value at the json pointer from the response. A `*` segment collects from every element
+// of the list, e.g., "/data/insert_abc/returning/*/id" is the list of all the ids (flattened, if more than one `*`)
+func captureValue(jsonParsedResp *gabs.Container, captorPath string) (interface{}, error) {
+	if !strings.Contains(captorPath+"/", "/*/") {
+		capturedGabsObj, err := jsonParsedResp.JSONPointer(captorPath)
+		if err != nil {
+			return nil, err
+		}
+		return capturedGabsObj.Data(), nil
+	}
+	wildcardIdx := strings.Index(captorPath+"/", "/*/")
+	listGabsObj, err := jsonParsedResp.JSONPointer(captorPath[:wildcardIdx])
+	if err != nil {
+		return nil, err
+	}
+	list, ok := listGabsObj.Data().([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("found %T at %s, but `*` expects a list", listGabsObj.Data(), captorPath[:wildcardIdx])
+	}
+	restPath := ""
+	if wildcardIdx+2 < len(captorPath) {
+		restPath = captorPath[wildcardIdx+2:]
+	}
+	values := []interface{}{}
+	for _, elem := range list {
+		if restPath == "" {
+			values = append(values, elem)
+			continue
+		}
+		value, err := captureValue(gabs.Wrap(elem), restPath)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(restPath+"/", "/*/") { // flattened
+			values = append(values, value.([]interface{})...)
+		} else {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// Teardown calls each fixture's Teardown (graphql call) in reverse sequence; or with OrderTeardownByForeignKeys,
+// the teardowns deleting the rows that reference others' (by a foreign key not ON DELETE CASCADE) go before those.
+func (fs *Fixtures) Teardown(ctx context.Context, graphqlClient Executor) error {
+	ctx, span := fs.tracer().Start(ctx, SpanTeardown, Attr(AttrFixtureCount, len(fs.Fixtures)))
+	err := fs.teardown(ctx, graphqlClient)
+	if remediationErr := fs.writeRemediationFile(); err == nil {
+		err = remediationErr
+	}
+	err = fs.redactError(err)
+	fs.endSpan(span, err)
+	return err
+}
+
+func (fs *Fixtures) teardown(ctx context.Context, graphqlClient Executor) error {
 	// only do teardown if it has been setup before (even partial), and has not been torn down before.
 	// having setup before means the parsing is already passed.
 	if fs.setupUntilIdx == nil {
@@ -72,76 +257,176 @@ func (fs *Fixtures) Teardown(ctx context.Context, graphqlClient *graphqlclient.C
 		return fmt.Errorf("teardown has already been attempted until fixture[%d]", *fs.teardownUntilIdx)
 	}
 
-	// reach here: can attempt teardown in reverse order
-	for fIdx := *fs.setupUntilIdx; fIdx >= 0; fIdx-- {
+	// the cleanup should still happen even if the caller's ctx is done (e.g., the test timed out), if so configured
+	if fs.DetachedTeardown {
+		var cancel context.CancelFunc
+		ctx, cancel = withOptionalTimeout(detachedContext{parent: ctx}, fs.TeardownBudget)
+		defer cancel()
+	}
+
+	// reach here: can attempt teardown in reverse order (or the foreign key order)
+	for _, fIdx := range fs.teardownOrder(*fs.setupUntilIdx) {
 		f := fs.Fixtures[fIdx]
-		fixtureName := fmt.Sprintf("fixture[%d]", fIdx)
 
 		if f.Teardown == nil { // this fixture doesn't have teardown step
-			fs.logs = append(fs.logs, fmt.Sprintf("%s.teardown: not exist", fixtureName))
+			fs.record(fs.newEvent(fIdx, PhaseTeardown, StatusSkipped))
 			continue
 		}
 
+		if err := fs.runHooks(ctx, fIdx, PhaseBeforeTeardown); err != nil {
+			return err
+		}
+
 		// execute teardown
-		_, err := doGraphqlRequest(ctx, graphqlClient, *f.Teardown, f.teardownVariables, fs.captured)
+		teardownEvent := fs.newEvent(fIdx, PhaseTeardown, StatusCompleted)
+		teardownEvent.Operation = f.teardownOperation
+		teardownCtx, span := fs.startFixtureSpan(ctx, SpanFixtureTeardown, fIdx)
+		teardownCtx, cancel := withOptionalTimeout(teardownCtx, f.TeardownTimeout)
+		var err error
+		steps := f.teardownOperationSteps()
+		for _, step := range steps { // the operations in sequence
+			if len(steps) > 1 {
+				teardownEvent.OperationName = step.name
+			}
+			if _, err = fs.doGraphqlRequestWithRetry(teardownCtx, graphqlClient, teardownEvent, *f.Teardown, step); err != nil {
+				if len(steps) > 1 {
+					err = fmt.Errorf("operation %s: %w", step.name, err)
+				}
+				break
+			}
+		}
+		cancel()
+		fs.endSpan(span, err)
+		teardownEvent.Duration = time.Since(teardownEvent.Time)
 		if err != nil {
-			return fs.logAndReturnError("%s.teardown failed: %w", fixtureName, err)
+			return fs.recordFailure(ctx, teardownEvent, err)
 		}
-		fs.logs = append(fs.logs, fmt.Sprintf("%s.teardown: completed", fixtureName))
+		fs.record(teardownEvent)
 		teardownUntilIdx := fIdx // making a copy
 		fs.teardownUntilIdx = &teardownUntilIdx
+		_ = fs.writeRemediationFile() // best effort: the error (if any) is reported at the end of Teardown
+
+		if err := fs.runHooks(ctx, fIdx, PhaseAfterTeardown); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (fs *Fixtures) logAndReturnError(format string, a ...interface{}) error {
-	err := fmt.Errorf(format, a...)
-	fs.logs = append(fs.logs, err.Error())
-	return err
+// withOptionalTimeout is context.WithTimeout, except that timeout <= 0 means no timeout
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// detachedContext keeps the values of the parent context, but not its cancellation or deadline
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// doGraphqlRequestWithRetry calls doGraphqlRequest, and retries according to fs.Retry.
+// Each attempt is recorded as an event (of the same fixture and phase as stepEvent) when retry applies.
+func (fs *Fixtures) doGraphqlRequestWithRetry(ctx context.Context, graphqlClient Executor, stepEvent Event,
+	graphqlQueryStr string, step operationStep) (*gabs.Container, error) {
+	operation := step.operation
+	maxAttempts := fs.Retry.maxAttempts(operation)
+	for attempt := 1; ; attempt++ {
+		attemptEvent := fs.newEvent(stepEvent.FixtureIdx, stepEvent.Phase, StatusAttemptSucceeded)
+		attemptEvent.Operation, attemptEvent.Attempt, attemptEvent.MaxAttempts = operation, attempt, maxAttempts
+		reqCtx, span := fs.tracer().Start(ctx, SpanRequest, Attr(AttrOperationType, operation), Attr(AttrAttempt, attempt))
+		jsonParsedResp, err := doGraphqlRequest(reqCtx, graphqlClient, graphqlQueryStr, step.name, step.variables, fs.captured)
+		fs.endSpan(span, err)
+		attemptEvent.Duration = time.Since(attemptEvent.Time)
+		if maxAttempts == 1 {
+			return jsonParsedResp, err
+		}
+		// reach here: retry applies; record every attempt
+		if err == nil {
+			fs.record(attemptEvent)
+			return jsonParsedResp, nil
+		}
+		attemptEvent.Status, attemptEvent.Error = StatusAttemptFailed, err.Error()
+		if attempt >= maxAttempts || !fs.Retry.isRetryable(err) {
+			fs.record(attemptEvent)
+			return nil, err
+		}
+		attemptEvent.WillRetry, attemptEvent.Backoff = true, fs.Retry.backoff(attempt)
+		fs.record(attemptEvent)
+		if sleepErr := sleepCtx(ctx, attemptEvent.Backoff); sleepErr != nil {
+			return nil, fmt.Errorf("%w (retry aborted: %v)", err, sleepErr)
+		}
+	}
 }
 
 // doGraphqlRequest composes the variables (if applicable), send the graphql request,
 // and parse the graphql response for errors
 // Used in both Setup and Teardown.
-func doGraphqlRequest(ctx context.Context, graphqlClient *graphqlclient.Client,
-	graphqlQueryStr string, varNames []string, captured map[string]interface{}) (*gabs.Container, error) {
+func doGraphqlRequest(ctx context.Context, graphqlClient Executor,
+	graphqlQueryStr string, operationName string, varNames []string, captured map[string]interface{}) (*gabs.Container, error) {
 	// 1. prepare request variables
+	request, err := newGraphqlRequest(graphqlQueryStr, operationName, varNames, captured)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. call graphql server
+	var resp []byte
+	err = graphqlClient.Do(ctx, request, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("graphql request failed: %w", err)
+	}
+	return parseGraphqlResponse(resp)
+}
+
+// newGraphqlRequest returns the request of the graphql (of the operation, if named), with the variables from captured
+func newGraphqlRequest(graphqlQueryStr string, operationName string, varNames []string, captured map[string]interface{}) (graphqlclient.Request, error) {
 	var variables map[string]interface{}
 	if len(varNames) > 0 {
 		variables = map[string]interface{}{}
 		for _, varName := range varNames {
 			varVal, found := captured[varName]
 			if !found { // shouldn't happen, since the fixtures should have passed parsing
-				return nil, fmt.Errorf("cannot find variable %s in captured", varName)
+				return graphqlclient.Request{}, fmt.Errorf("cannot find variable %s in captured", varName)
 			}
 			variables[varName] = varVal
 		}
 	}
+	return graphqlclient.Request{Query: graphqlQueryStr, OperationName: operationName, Variables: variables}, nil
+}
 
-	// 2. call graphql server
-	var resp []byte
-	err := graphqlClient.Do(
-		ctx,
-		graphqlclient.Request{
-			Query: graphqlQueryStr,
-			Variables: variables,
-		},
-		&resp,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("graphql request failed: %w", err)
+// mergeResponses returns the response with the data of both (resp2's data overriding resp1's of the same key);
+// resp2 if resp1 is nil
+func mergeResponses(resp1 *gabs.Container, resp2 *gabs.Container) *gabs.Container {
+	if resp1 == nil {
+		return resp2
+	}
+	data := map[string]interface{}{}
+	for _, resp := range []*gabs.Container{resp1, resp2} {
+		for key, value := range resp.Search("data").ChildrenMap() {
+			data[key] = value.Data()
+		}
 	}
-	// examine if errors exist in the response
+	return gabs.Wrap(map[string]interface{}{"data": data})
+}
+
+// parseGraphqlResponse parses the graphql response, and examines if errors exist in it
+func parseGraphqlResponse(resp []byte) (*gabs.Container, error) {
 	jsonParsedResp, err := gabs.ParseJSON(resp)
 	if err != nil {
 		return nil, fmt.Errorf("graphql response is not json: %w", err)
 	}
 	errorsGabsObj, err := jsonParsedResp.JSONPointer("/errors")
 	if err == nil { // means "errors" found
-		return nil, fmt.Errorf("graphql response contains error: %v", errorsGabsObj.Data())
+		return nil, newResponseError(errorsGabsObj.Data())
 	}
 
 	return jsonParsedResp, nil
 }
-
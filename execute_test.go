@@ -2,13 +2,16 @@ package graphqlfixture
 
 import (
 	"context"
+	"errors"
 	"github.com/gmm1900/gopointer"
 	"github.com/gmm1900/graphqlclient"
+	"github.com/gmm1900/graphqlfixture/fixturetest"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
+	"time"
 )
 
 type testCase struct {
@@ -131,7 +134,7 @@ func TestSetup(t *testing.T) {
 			// THEN
 			assert.Equal(t, tc.expectedCapturedRequests, tc.givenMockServer.CapturedReqBody)
 			cmpOpts := []cmp.Option{
-				cmpopts.IgnoreFields(Fixtures{}, "Fixtures"),
+				cmpopts.IgnoreFields(Fixtures{}, "Fixtures", "events", "parseDiagnostics"), // events has timing; covered by TestEvents
 				cmp.AllowUnexported(Fixtures{}),
 			}
 			want, got := tc.expectedSetupResult, tc.givenFixtures
@@ -221,20 +224,24 @@ func TestTeardown(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T){
-			// GIVEN
-			tc.givenMockServer.Start(t)
-			defer tc.givenMockServer.Close()
+			// GIVEN: each expected request gets the mocked response; the server verifies them (in order) at the end
+			mockServer := fixturetest.NewServer(t).InOrder()
+			for i, req := range tc.expectedCapturedRequests {
+				mockServer.Expect().
+					Query(req["query"].(string)).
+					Variables(req["variables"].(map[string]interface{})).
+					Respond(string(tc.givenMockServer.MockedRespBody[i]))
+			}
 
 			ctx := context.Background()
-			graphqlClient := graphqlclient.New(tc.givenMockServer.URL, nil, http.Header{})
+			graphqlClient := graphqlclient.New(mockServer.URL, nil, http.Header{})
 
 			// WHEN
 			tc.givenFixtures.Teardown(ctx, graphqlClient)
 
 			// THEN
-			// assert.Equal(t, tc.expectedCapturedRequests, tc.givenMockServer.CapturedReqBody)
 			cmpOpts := []cmp.Option{
-				cmpopts.IgnoreFields(Fixtures{}, "Fixtures", "parsed", "parseErr", "captured", "setupUntilIdx"),
+				cmpopts.IgnoreFields(Fixtures{}, "Fixtures", "parsed", "parseErr", "parseDiagnostics", "captured", "setupUntilIdx", "events"),
 				cmp.AllowUnexported(Fixtures{}),
 			}
 			want, got := tc.expectedSetupResult, tc.givenFixtures
@@ -244,3 +251,95 @@ func TestTeardown(t *testing.T) {
 		})
 	}
 }
+
+func TestSetupTimeout(t *testing.T) {
+	// GIVEN: an executor that hangs until the ctx is done
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	fixtures := Fixtures{
+		Fixtures: []Fixture{
+			{Setup: `mutation { insert_abc(objects: {}) { affected_rows } }`, SetupTimeout: 10 * time.Millisecond},
+		},
+	}
+
+	// WHEN
+	err := fixtures.Setup(context.Background(), executor)
+
+	// THEN
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, []string{"fixture[0].setup failed: graphql request failed: context deadline exceeded"}, fixtures.Logs())
+	assert.Nil(t, fixtures.SetupUntil())
+}
+
+func TestSetupPastFixtureWithoutCaptors(t *testing.T) {
+	// GIVEN: fixture[0] has no captors; the setup used to stop there, leaving fixture[1] not setup
+	var requests []graphqlclient.Request
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = []byte(`{ "data": { "insert_abc": { "affected_rows": 1 } } }`)
+		requests = append(requests, req)
+		return nil
+	})
+	fixtures := Fixtures{
+		Fixtures: []Fixture{
+			{Setup: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+			{Setup: `mutation { insert_abc(objects: {}) { affected_rows } }`, Captors: map[string]string{"rows": "/data/insert_abc/affected_rows"}},
+		},
+	}
+
+	// WHEN
+	err := fixtures.Setup(context.Background(), executor)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, 1, *fixtures.SetupUntil())
+	assert.Equal(t, map[string]interface{}{"rows": 1.0}, fixtures.captured)
+	assert.Equal(t, []string{
+		"fixture[0].setup: completed",
+		"fixture[0].captors: not exist",
+		"fixture[1].setup: completed",
+		"fixture[1].captors: completed with 1 capture(s)",
+	}, fixtures.Logs())
+}
+
+func TestTeardownWithCancelledContext(t *testing.T) {
+	newFixtures := func(detached bool) Fixtures {
+		return Fixtures{
+			parsed:           true,
+			captured:         map[string]interface{}{},
+			setupUntilIdx:    gopointer.OfInt(1),
+			DetachedTeardown: detached,
+			TeardownBudget:   time.Second,
+			Fixtures: []Fixture{
+				{Setup: `doesnt matter for this test`, Teardown: gopointer.OfString(`mutation { delete_abc(where: {}) { affected_rows } }`)},
+				{Setup: `doesnt matter for this test`, Teardown: gopointer.OfString(`mutation { delete_xyz(where: {}) { affected_rows } }`)},
+			},
+		}
+	}
+	// executor honors the ctx like an http client would
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		*resp.(*[]byte) = []byte(`{ "data": {} }`)
+		return nil
+	})
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("attached teardown fails", func(t *testing.T) {
+		fixtures := newFixtures(false)
+		err := fixtures.Teardown(cancelledCtx, executor)
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Nil(t, fixtures.TeardownUntil())
+	})
+
+	t.Run("detached teardown still cleans up", func(t *testing.T) {
+		fixtures := newFixtures(true)
+		err := fixtures.Teardown(cancelledCtx, executor)
+		assert.NoError(t, err)
+		assert.Equal(t, gopointer.OfInt(0), fixtures.TeardownUntil())
+	})
+}
@@ -0,0 +1,20 @@
+package graphqlfixture
+
+import (
+	"context"
+	"github.com/gmm1900/graphqlclient"
+)
+
+// Executor sends the graphql request and decodes the graphql response into `response`.
+// *graphqlclient.Client satisfies this interface.
+type Executor interface {
+	Do(ctx context.Context, graphqlRequest graphqlclient.Request, response interface{}) error
+}
+
+// ExecutorFunc adapts a function to Executor
+type ExecutorFunc func(ctx context.Context, graphqlRequest graphqlclient.Request, response interface{}) error
+
+// Do calls f
+func (f ExecutorFunc) Do(ctx context.Context, graphqlRequest graphqlclient.Request, response interface{}) error {
+	return f(ctx, graphqlRequest, response)
+}
@@ -0,0 +1,232 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Step is a setup or teardown graphql request of a fixture, with the variables resolved from the captured values.
+// Not redacted: see ExportCurl and ExportHTTP for the redacted renderings.
+type Step struct {
+	FixtureIdx  int
+	FixtureName string
+	Phase       Phase // PhaseSetup or PhaseTeardown
+	Executed    bool  // whether the request has been sent (successfully or not); otherwise it's only planned
+	Request     graphqlclient.Request
+	Unresolved  []string // variables not captured (yet); they are null in Request.Variables
+	Merged      []int    // with AtomicSetup, the fixtures merged into this setup request (FixtureIdx is the first); nil if not merged
+}
+
+// Name returns the step name, e.g., "fixture[1].setup"
+func (s Step) Name() string {
+	return fmt.Sprintf("fixture[%d].%s", s.FixtureIdx, s.Phase)
+}
+
+// Steps returns the setup requests in sequence then the teardown requests in reverse sequence (or in the foreign key
+// order, with OrderTeardownByForeignKeys), i.e., in the order they are executed. If planned is false, only the steps that have been executed are returned.
+// With AtomicSetup, the merged fixtures have one setup request, as sent. With BatchSetup, the batched queries are
+// still returned one by one: being independent, sending them alone has the same effect.
+func (fs *Fixtures) Steps(planned bool) ([]Step, error) {
+	if !fs.parsed {
+		fs.Parse()
+	}
+	if fs.parseErr != nil {
+		return nil, fmt.Errorf("parse error: %w", fs.parseErr)
+	}
+
+	// by step name: whether it has been sent, and of several named operations, the last one sent
+	executed := map[string]bool{}
+	lastOperation := map[string]string{}
+	for _, e := range fs.events {
+		if e.Phase == PhaseSetup || e.Phase == PhaseTeardown {
+			executed[e.Step()] = e.Status != StatusSkipped
+			lastOperation[e.Step()] = e.OperationName
+		}
+	}
+
+	var steps []Step
+	addSteps := func(fIdx int, phase Phase, query string, opSteps []operationStep, merged []int) {
+		name := Step{FixtureIdx: fIdx, Phase: phase}.Name()
+		opExecuted := executed[name]
+		for _, opStep := range opSteps {
+			step := Step{
+				FixtureIdx:  fIdx,
+				FixtureName: fs.Fixtures[fIdx].Name,
+				Phase:       phase,
+				Executed:    opExecuted,
+				Request:     graphqlclient.Request{Query: query, OperationName: opStep.name},
+				Merged:      merged,
+			}
+			if len(opSteps) > 1 && opStep.name == lastOperation[name] {
+				opExecuted = false // the operations after the last one sent
+			}
+			if !step.Executed && !planned {
+				continue
+			}
+			if len(opStep.variables) > 0 {
+				step.Request.Variables = map[string]interface{}{}
+				for _, varName := range opStep.variables {
+					varVal, found := fs.captured[varName]
+					if !found {
+						step.Unresolved = append(step.Unresolved, varName)
+					}
+					step.Request.Variables[varName] = varVal
+				}
+			}
+			steps = append(steps, step)
+		}
+	}
+	for _, group := range fs.setupGroups() {
+		var merged []int
+		if len(group.fixtureIdxs) > 1 {
+			merged = group.fixtureIdxs
+		}
+		addSteps(group.fixtureIdxs[0], PhaseSetup, group.setup, group.steps, merged)
+	}
+	// in the order of Teardown, from the last fixture setup; the fixtures not setup (planned only) go first, as if they were
+	teardownOrder := fs.teardownOrder(len(fs.Fixtures) - 1)
+	if fs.setupUntilIdx != nil {
+		var notSetup []int
+		for _, fIdx := range teardownOrder {
+			if fIdx > *fs.setupUntilIdx {
+				notSetup = append(notSetup, fIdx)
+			}
+		}
+		teardownOrder = append(notSetup, fs.teardownOrder(*fs.setupUntilIdx)...)
+	}
+	for _, fIdx := range teardownOrder {
+		if f := fs.Fixtures[fIdx]; f.Teardown != nil {
+			addSteps(fIdx, PhaseTeardown, *f.Teardown, f.teardownOperationSteps(), nil)
+		}
+	}
+	return steps, nil
+}
+
+// ExportOptions configures ExportCurl and ExportHTTP.
+// The sensitive headers, variables and values (see Fixtures.Redaction) are masked in the exports.
+type ExportOptions struct {
+	URL     string      // the graphql endpoint
+	Headers http.Header // the headers sent with each request, e.g., the same as given to graphqlclient.New
+	Planned bool        // also export the steps not executed yet
+}
+
+// exportHeaders returns the headers (with Content-Type) as sorted "name: value" lines, secrets masked
+func (fs *Fixtures) exportHeaders(opts ExportOptions) []string {
+	headers := fs.Redaction.RedactHeader(opts.Headers)
+	lines := []string{"Content-Type: application/json"}
+	var names []string
+	for name := range headers {
+		if !strings.EqualFold(name, "Content-Type") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			lines = append(lines, fmt.Sprintf("%s: %s", name, fs.redactText(value)))
+		}
+	}
+	return lines
+}
+
+// exportBody returns the step's request as json (the same as sent by graphqlclient), secrets masked
+func (fs *Fixtures) exportBody(step Step) (string, error) {
+	req := step.Request
+	req.Variables = fs.Redaction.RedactVariables(req.Variables)
+	body, err := requestBody(req)
+	if err != nil {
+		return "", err
+	}
+	return fs.redactText(body), nil
+}
+
+// stepComment describes the step, e.g., "fixture[1].setup (planned; unresolved variables: abc_id)"
+func stepComment(step Step) string {
+	comment := step.Name()
+	if step.FixtureName != "" {
+		comment += " " + step.FixtureName
+	}
+	status := "executed"
+	if !step.Executed {
+		status = "planned"
+	}
+	if len(step.Merged) > 1 {
+		names := make([]string, 0, len(step.Merged))
+		for _, fIdx := range step.Merged {
+			names = append(names, fmt.Sprintf("fixture[%d]", fIdx))
+		}
+		status += "; merged: " + strings.Join(names, ", ")
+	}
+	if len(step.Unresolved) > 0 {
+		status += "; unresolved variables: " + strings.Join(step.Unresolved, ", ")
+	}
+	return fmt.Sprintf("%s (%s)", comment, status)
+}
+
+// requestBody returns the request as json, the same as sent by graphqlclient
+func requestBody(req graphqlclient.Request) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(req); err != nil {
+		return "", fmt.Errorf("fail to encode request: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// ExportCurl writes each step as a curl command, to reproduce the requests by hand
+func (fs *Fixtures) ExportCurl(w io.Writer, opts ExportOptions) error {
+	steps, err := fs.Steps(opts.Planned)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, step := range steps {
+		body, err := fs.exportBody(step)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "# %s\n", stepComment(step))
+		fmt.Fprintf(&buf, "curl -sS -X POST %s", shellQuote(opts.URL))
+		for _, header := range fs.exportHeaders(opts) {
+			fmt.Fprintf(&buf, " \\\n  -H %s", shellQuote(header))
+		}
+		fmt.Fprintf(&buf, " \\\n  --data-raw %s\n\n", shellQuote(body))
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// ExportHTTP writes each step as a request of a `.http` file (as used by REST client editor plugins)
+func (fs *Fixtures) ExportHTTP(w io.Writer, opts ExportOptions) error {
+	steps, err := fs.Steps(opts.Planned)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, step := range steps {
+		body, err := fs.exportBody(step)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "### %s\n", stepComment(step))
+		fmt.Fprintf(&buf, "POST %s\n", opts.URL)
+		for _, header := range fs.exportHeaders(opts) {
+			fmt.Fprintf(&buf, "%s\n", header)
+		}
+		fmt.Fprintf(&buf, "\n%s\n\n", body)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
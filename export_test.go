@@ -0,0 +1,161 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"context"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	// GIVEN: fixture[0] is setup, fixture[1] fails at setup
+	responses := [][]byte{
+		[]byte(`{ "data": { "insert_abc": { "returning": [ { "id": 13 } ] } } }`),
+		[]byte(`{ "errors": [ { "message": "boom" } ] }`),
+	}
+	calls := 0
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = responses[calls]
+		calls++
+		return nil
+	})
+	fixtures := Fixtures{
+		Fixtures: []Fixture{
+			{
+				Name:     "abc",
+				Setup:    `mutation { insert_abc(objects: { name: "it's abc"}) { returning { id } } }`,
+				Captors:  map[string]string{"abc_id": "/data/insert_abc/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+			},
+			{
+				Setup:    `mutation ($abc_id: Int!) { insert_xyz(objects: { abc_id: $abc_id }) { returning { id } } }`,
+				Captors:  map[string]string{"xyz_id": "/data/insert_xyz/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($xyz_id: Int!) { delete_xyz(where: { id: { _eq: $xyz_id } }) { affected_rows } }`),
+			},
+		},
+	}
+	assert.Error(t, fixtures.Setup(context.Background(), executor))
+	opts := ExportOptions{
+		URL: "http://localhost:8080/v1/graphql",
+		Headers: http.Header{
+			"X-Hasura-Admin-Secret": []string{"adminsecret"},
+			"X-Hasura-Role":         []string{"admin"},
+		},
+	}
+
+	t.Run("curl executed", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, fixtures.ExportCurl(&buf, opts))
+		assert.Equal(t, `# fixture[0].setup abc (executed)
+curl -sS -X POST 'http://localhost:8080/v1/graphql' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Hasura-Admin-Secret: ***' \
+  -H 'X-Hasura-Role: admin' \
+  --data-raw '{"query":"mutation { insert_abc(objects: { name: \"it'\''s abc\"}) { returning { id } } }"}'
+
+# fixture[1].setup (executed)
+curl -sS -X POST 'http://localhost:8080/v1/graphql' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Hasura-Admin-Secret: ***' \
+  -H 'X-Hasura-Role: admin' \
+  --data-raw '{"query":"mutation ($abc_id: Int!) { insert_xyz(objects: { abc_id: $abc_id }) { returning { id } } }","variables":{"abc_id":13}}'
+
+`, buf.String())
+	})
+
+	t.Run("http planned", func(t *testing.T) {
+		var buf bytes.Buffer
+		opts := opts
+		opts.Planned = true
+		opts.Headers = nil
+		assert.NoError(t, fixtures.ExportHTTP(&buf, opts))
+		assert.Equal(t, `### fixture[0].setup abc (executed)
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+
+{"query":"mutation { insert_abc(objects: { name: \"it's abc\"}) { returning { id } } }"}
+
+### fixture[1].setup (executed)
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+
+{"query":"mutation ($abc_id: Int!) { insert_xyz(objects: { abc_id: $abc_id }) { returning { id } } }","variables":{"abc_id":13}}
+
+### fixture[1].teardown (planned; unresolved variables: xyz_id)
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+
+{"query":"mutation ($xyz_id: Int!) { delete_xyz(where: { id: { _eq: $xyz_id } }) { affected_rows } }","variables":{"xyz_id":null}}
+
+### fixture[0].teardown abc (planned)
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+
+{"query":"mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }","variables":{"abc_id":13}}
+
+`, buf.String())
+	})
+}
+
+func TestExportAtomicSetup(t *testing.T) {
+	t.Run("planned", func(t *testing.T) {
+		fixtures := Fixtures{
+			Fixtures: []Fixture{
+				{Setup: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+				{Setup: `mutation { insert_xyz(objects: {}) { affected_rows } }`},
+			},
+			AtomicSetup: true,
+		}
+
+		var buf bytes.Buffer
+		assert.NoError(t, fixtures.ExportHTTP(&buf, ExportOptions{URL: "http://localhost:8080/v1/graphql", Planned: true}))
+		assert.Equal(t, `### fixture[0].setup (planned; merged: fixture[0], fixture[1])
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+
+{"query":"mutation {\n  f0_insert_abc: insert_abc(objects: {}) {\n    affected_rows\n  }\n  f1_insert_xyz: insert_xyz(objects: {}) {\n    affected_rows\n  }\n}"}
+
+`, buf.String())
+	})
+
+	t.Run("executed, with a shared variable", func(t *testing.T) {
+		fixtures := Fixtures{
+			Fixtures: []Fixture{
+				{Setup: `mutation { insert_abc_one(object: {}) { id } }`, Captors: map[string]string{"abc_id": "/data/insert_abc_one/id"}},
+				{Setup: `mutation ($abc_id: Int!) { insert_def(objects: { abc_id: $abc_id }) { affected_rows } }`},
+				{Setup: `mutation ($abc_id: Int!) { insert_xyz(objects: { abc_id: $abc_id }) { affected_rows } }`},
+			},
+			AtomicSetup: true,
+		}
+		responses := [][]byte{
+			[]byte(`{ "data": { "insert_abc_one": { "id": 13 } } }`),
+			[]byte(`{ "data": { "f1_insert_def": { "affected_rows": 1 }, "f2_insert_xyz": { "affected_rows": 1 } } }`),
+		}
+		sent := 0
+		executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+			*resp.(*[]byte) = responses[sent]
+			sent++
+			return nil
+		})
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+
+		var buf bytes.Buffer
+		assert.NoError(t, fixtures.ExportHTTP(&buf, ExportOptions{URL: "http://localhost:8080/v1/graphql"}))
+		assert.Equal(t, `### fixture[0].setup (executed)
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+
+{"query":"mutation { insert_abc_one(object: {}) { id } }"}
+
+### fixture[1].setup (executed; merged: fixture[1], fixture[2])
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+
+{"query":"mutation ($abc_id: Int!) {\n  f1_insert_def: insert_def(objects: {abc_id: $abc_id}) {\n    affected_rows\n  }\n  f2_insert_xyz: insert_xyz(objects: {abc_id: $abc_id}) {\n    affected_rows\n  }\n}","variables":{"abc_id":13}}
+
+`, buf.String())
+	})
+}
@@ -1,26 +1,59 @@
 package graphqlfixture
 
+import (
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	"time"
+)
+
 // Fixture contains the setup, teardown logic for a piece of fixtures, and the data needs to be extracted (captured) from the fixture, e.g., IDs.
 type Fixture struct {
+	Name string // optional: a logical name of the fixture, e.g., "subjects"; shows up in events and reports
 	Setup string // the graphql to seed the fixture (expect mutation.. could be query too? to just get some existing data, e.g., max of something)
-	Captors map[string]string // directives for capturing data from the setup response: key = captor name, the "logical name" of the captured value, value = the jsonpath ino the response to extract the value
+	Captors map[string]string // directives for capturing data from the setup response: key = captor name, the "logical name" of the captured value, value = the jsonpath ino the response to extract the value (a `*` segment collects from every list element, e.g., "/data/insert_abc/returning/*/id")
 	Teardown *string // the graphql to remove the seeded fixture (expect delete mutation). optional, if no new fixture is created during setup.
+	SetupTimeout time.Duration // optional: time limit of the setup graphql call (including retries). 0 means no limit other than the ctx's.
+	TeardownTimeout time.Duration // optional: time limit of the teardown graphql call (including retries). 0 means no limit other than the ctx's.
+	Hooks Hooks // optional: go code to run around this fixture's setup and teardown
+	LintSuppress []string // optional: the lint rules (by ID, e.g., RuleMissingTeardown) not to report for this fixture; see Lint()
+	SetupOperations []string // optional: the operations (by name) of Setup to run, in sequence: one request each (with operationName); the captors apply to their responses merged. Required if Setup has several named operations.
+	TeardownOperations []string // optional: the operations (by name) of Teardown to run, in sequence. Required if Teardown has several named operations.
 
 	// internal: variable names parsed from graphql (== captor names)
 	setupVariables []string
 	teardownVariables []string
+	// internal: operation type parsed from graphql ("query", "mutation" ..)
+	setupOperation string
+	teardownOperation string
+	// internal: the operations to run, as parsed from graphql
+	setupSteps []operationStep
+	teardownSteps []operationStep
 }
 
 type Fixtures struct {
 	Fixtures []Fixture // a list of fixtures, to be setup in this sequence, and torn down in the reverse sequence
+	Hooks Hooks // optional: go code to run around every fixture's setup and teardown
+	RemediationFile string // optional: path of a file kept up-to-date with Remediation() during Setup and Teardown (removed once all torn down), so a failed or skipped teardown can be finished later.
+	Redaction *Redaction // optional: what's sensitive, to be masked in logs, errors, reports and exports. Nil means only DefaultSecretHeaders are masked (in exports).
+	Tracer Tracer // optional: traces the setup and teardown. Nil means no tracing.
+	Retry *RetryPolicy // optional: retry the graphql requests upon transient failures. Nil means no retry.
+	DetachedTeardown bool // if true, Teardown runs on a context detached from the caller's ctx (values kept, cancellation and deadline dropped), so the cleanup still happens after the caller's ctx is done.
+	TeardownBudget time.Duration // optional: time limit of the whole detached teardown. 0 means no limit (only the per-fixture TeardownTimeout applies). Only used with DetachedTeardown.
+	Hasura *hasurameta.Schema // optional: the tables of the Hasura project (see hasurameta.Load), for the teardown coverage diagnostics to follow the relationships and ON DELETE CASCADE
+	Schema *Schema // optional: the graphql schema (see LoadSchema) every setup and teardown is validated against in Parse(). Nil means syntax check only.
+	OrderTeardownByForeignKeys bool // if true, Teardown deletes the children before their parents per the foreign keys (from Hasura, or else the object relationships in Schema), otherwise in the reverse sequence; see Fixtures.Teardown
+	AtomicSetup bool // if true, Setup merges the consecutive mutations not using each other's captors into one mutation (with the root fields aliased), which Hasura runs in one transaction: each merged group commits or rolls back together. The variables (captors) are declared once, not renamed, and the captor paths apply as they are (the response is split back per fixture); Steps() and the exports show the merged request as sent, with the aliased root fields. A fixture with its own BeforeSetup/AfterSetup hook is not merged.
+	BatchSetup bool // if true and the executor is a BatchExecutor, Setup sends the consecutive queries not using each other's captors in one batched http request; otherwise (or if the server doesn't support batches) one by one.
+	AutoTeardown *AutoTeardown // optional: generate the teardown of the fixtures without one from the Hasura inserts of their setup, in Parse(). Nil means no generation.
 
 	// internal: parsing
 	parsed bool // if false, Fixtures need to go through the Parse() step first.
 	parseErr error // if parsed = true && parseErr != nil, these fixtures are not ready for setup (hint: test case not written correctly).
+	parseDiagnostics []Diagnostic // problems found by parsing that don't prevent the setup, e.g., a table no teardown deletes from
 
 	// internal: execution
 	captured map[string]interface{} // key = captor name, value = extracted value from the setup graphql response
 	setupUntilIdx *int // the index to the last fixture that was successfully set up. Nil if not setup before.
 	teardownUntilIdx *int // the index to the last fixture that was successfully torn down. Nil if not torndown before.
-	logs []string // track info on setup and teardown (success or failure). Since this is a rather fragile fixture-gen (not db transaction, cannot rollback), an unsuccessful execution will require manual intervention (e.g., delete data from db)
+	events []Event // structured log of setup and teardown; logs is the rendered view of it
+	logs []string // track info on setup and teardown (success or failure). Since this is a rather fragile fixture-gen (not db transaction, cannot rollback), an unsuccessful execution will require manual intervention (e.g., delete data from db); see Remediation()
 }
\ No newline at end of file
@@ -0,0 +1,321 @@
+package fixturetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RecordEnv is the environment variable that makes Cassette record (again) even if the cassette file exists
+const RecordEnv = "GRAPHQLFIXTURE_RECORD"
+
+// ErrStaleCassette is returned by the Replayer when the requests don't match the recorded ones, e.g., the fixtures
+// have changed since the cassette was recorded
+var ErrStaleCassette = errors.New("cassette is stale")
+
+// CassetteFile is the content of a cassette file: the requests of a fixture run, with their responses, in order.
+// The headers are not recorded, so the cassettes don't keep secrets like the admin secret.
+type CassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded graphql request and its response
+type Interaction struct {
+	Request Request `json:"request"`
+	// the request variables with the values generated by the server (found in previous responses, e.g., the ids)
+	// replaced by references to where they were found, e.g., "{{#0/data/insert_abc/returning/0/id}}"
+	NormalizedVariables map[string]interface{} `json:"normalizedVariables,omitempty"`
+	Status              int                    `json:"status"`
+	Response            json.RawMessage        `json:"response"`
+}
+
+// Cassette returns a transport (for the http.Client given to graphqlclient.New) that replays the cassette file at
+// path, or records it through inner (nil for http.DefaultTransport) if the file doesn't exist or RecordEnv is set.
+// When the test ends, the recorded cassette is saved, or the replayed cassette is checked to be fully used.
+func Cassette(t TestingT, path string, inner http.RoundTripper) http.RoundTripper {
+	t.Helper()
+	if _, err := os.Stat(path); os.IsNotExist(err) || os.Getenv(RecordEnv) != "" {
+		recorder := NewRecorder(inner)
+		t.Cleanup(func() {
+			if err := recorder.Save(path); err != nil {
+				t.Errorf("fixturetest: %v", err)
+			}
+		})
+		return recorder
+	}
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Errorf("fixturetest: %v", err)
+		return replayer
+	}
+	t.Cleanup(func() {
+		if err := replayer.Done(); err != nil {
+			t.Errorf("fixturetest: %v", err)
+		}
+	})
+	return replayer
+}
+
+// Recorder is a transport recording the graphql requests and responses going through it
+type Recorder struct {
+	inner        http.RoundTripper
+	mu           sync.Mutex
+	interactions []Interaction
+	generated    generatedValues
+}
+
+// NewRecorder returns a Recorder sending the requests through inner (nil for http.DefaultTransport)
+func NewRecorder(inner http.RoundTripper) *Recorder {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &Recorder{inner: inner}
+}
+
+// RoundTrip sends the request through the inner transport, and records the request and response
+func (rec *Recorder) RoundTrip(httpReq *http.Request) (*http.Response, error) {
+	req, err := readRequest(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := rec.inner.RoundTrip(httpReq)
+	if err != nil {
+		return nil, err // not recorded: a transport error is not a response to replay
+	}
+	respBytes, err := ioutil.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	httpResp.Body = ioutil.NopCloser(bytes.NewReader(respBytes))
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	interaction := Interaction{
+		Request:             req,
+		NormalizedVariables: rec.generated.normalize(req.Variables),
+		Status:              httpResp.StatusCode,
+		Response:            json.RawMessage(respBytes),
+	}
+	if !json.Valid(respBytes) { // e.g., a text body of an error status: kept as a json string
+		interaction.Response, _ = json.Marshal(string(respBytes))
+	}
+	rec.generated.add(len(rec.interactions), respBytes)
+	rec.interactions = append(rec.interactions, interaction)
+	return httpResp, nil
+}
+
+// Cassette returns what has been recorded so far
+func (rec *Recorder) Cassette() *CassetteFile {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return &CassetteFile{Interactions: append([]Interaction{}, rec.interactions...)}
+}
+
+// Save writes what has been recorded to the cassette file at path
+func (rec *Recorder) Save(path string) error {
+	jsonBytes, err := json.MarshalIndent(rec.Cassette(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("fail to encode cassette: %w", err)
+	}
+	if err := ioutil.WriteFile(path, append(jsonBytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("fail to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Replayer is a transport serving the recorded responses of a cassette, in order, without any network.
+// Each request must match the recorded one: the same query (ignoring whitespace), operation name, and variables
+// (the generated values compared by where they were found in the previous responses); otherwise ErrStaleCassette.
+type Replayer struct {
+	cassette  *CassetteFile
+	mu        sync.Mutex
+	next      int
+	generated generatedValues
+}
+
+// NewReplayer reads the cassette file at path
+func NewReplayer(path string) (*Replayer, error) {
+	jsonBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read cassette: %w", err)
+	}
+	var cassette CassetteFile
+	if err := json.Unmarshal(jsonBytes, &cassette); err != nil {
+		return nil, fmt.Errorf("fail to decode cassette %s: %w", path, err)
+	}
+	return &Replayer{cassette: &cassette}, nil
+}
+
+// RoundTrip responds with the next recorded response, if the request matches the recorded one
+func (rep *Replayer) RoundTrip(httpReq *http.Request) (*http.Response, error) {
+	if rep == nil {
+		return nil, fmt.Errorf("%w: no cassette", ErrStaleCassette)
+	}
+	req, err := readRequest(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.next >= len(rep.cassette.Interactions) {
+		return nil, fmt.Errorf("%w: request #%d not recorded: %s", ErrStaleCassette, rep.next, normalizeSpace(req.Query))
+	}
+	idx := rep.next
+	interaction := rep.cassette.Interactions[idx]
+	if diff := interactionDiff(interaction, req, rep.generated.normalize(req.Variables)); diff != "" {
+		return nil, fmt.Errorf("%w: request #%d differs from the recorded one: %s", ErrStaleCassette, idx, diff)
+	}
+	rep.next++
+
+	respBytes := []byte(interaction.Response)
+	var text string
+	if json.Unmarshal(respBytes, &text) == nil { // recorded as a json string, i.e., not a json body
+		respBytes = []byte(text)
+	}
+	rep.generated.add(idx, respBytes)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(respBytes)),
+		ContentLength: int64(len(respBytes)),
+		Request:       httpReq,
+	}, nil
+}
+
+// Done returns ErrStaleCassette if not all the recorded requests have been replayed,
+// e.g., a fixture has been removed since the cassette was recorded
+func (rep *Replayer) Done() error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if remaining := len(rep.cassette.Interactions) - rep.next; remaining > 0 {
+		return fmt.Errorf("%w: %d recorded request(s) not replayed, the next: %s",
+			ErrStaleCassette, remaining, normalizeSpace(rep.cassette.Interactions[rep.next].Request.Query))
+	}
+	return nil
+}
+
+// interactionDiff describes how the request differs from the recorded one; empty if it matches
+func interactionDiff(interaction Interaction, req Request, normalizedVariables map[string]interface{}) string {
+	var diffs []string
+	if recorded, actual := normalizeSpace(interaction.Request.Query), normalizeSpace(req.Query); recorded != actual {
+		diffs = append(diffs, fmt.Sprintf("query %q, recorded %q", actual, recorded))
+	}
+	if interaction.Request.OperationName != req.OperationName {
+		diffs = append(diffs, fmt.Sprintf("operationName %q, recorded %q", req.OperationName, interaction.Request.OperationName))
+	}
+	recordedVariables := normalizeJSON(interaction.NormalizedVariables)
+	if actual := normalizeJSON(normalizedVariables); !reflect.DeepEqual(recordedVariables, actual) {
+		recordedBytes, _ := json.Marshal(recordedVariables)
+		actualBytes, _ := json.Marshal(actual)
+		diffs = append(diffs, fmt.Sprintf("variables %s, recorded %s", actualBytes, recordedBytes))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// readRequest reads the graphql request of the http request, leaving the body readable
+func readRequest(httpReq *http.Request) (Request, error) {
+	var req Request
+	if httpReq.Body == nil {
+		return req, fmt.Errorf("fixturetest: no request body")
+	}
+	bodyBytes, err := ioutil.ReadAll(httpReq.Body)
+	httpReq.Body.Close()
+	if err != nil {
+		return req, fmt.Errorf("fixturetest: fail to read request: %w", err)
+	}
+	httpReq.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+	if err := json.Unmarshal(bodyBytes, &req); err != nil {
+		return req, fmt.Errorf("fixturetest: request is not graphql: %w", err)
+	}
+	return req, nil
+}
+
+// generatedValues are the scalar values found in the responses so far (i.e., generated by the server, like ids),
+// by where they were first found, e.g., "#0/data/insert_abc/returning/0/id"
+type generatedValues struct {
+	refs map[string]string // keyed by the json of the value
+}
+
+func (g *generatedValues) add(interactionIdx int, respBytes []byte) {
+	var resp interface{}
+	if json.Unmarshal(respBytes, &resp) != nil {
+		return
+	}
+	if g.refs == nil {
+		g.refs = map[string]string{}
+	}
+	var walk func(pointer string, value interface{})
+	walk = func(pointer string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(pointer+"/"+key, v[key])
+			}
+		case []interface{}:
+			for i, elem := range v {
+				walk(pointer+"/"+strconv.Itoa(i), elem)
+			}
+		case nil, bool:
+			// too common to tell where they came from
+		default:
+			valueBytes, _ := json.Marshal(v)
+			if _, found := g.refs[string(valueBytes)]; !found {
+				g.refs[string(valueBytes)] = fmt.Sprintf("{{#%d%s}}", interactionIdx, pointer)
+			}
+		}
+	}
+	walk("", resp)
+}
+
+// normalize returns the variables with the generated values replaced by their references
+func (g *generatedValues) normalize(variables map[string]interface{}) map[string]interface{} {
+	if variables == nil {
+		return nil
+	}
+	var replace func(value interface{}) interface{}
+	replace = func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			replaced := map[string]interface{}{}
+			for key, elem := range v {
+				replaced[key] = replace(elem)
+			}
+			return replaced
+		case []interface{}:
+			replaced := make([]interface{}, len(v))
+			for i, elem := range v {
+				replaced[i] = replace(elem)
+			}
+			return replaced
+		case nil, bool:
+			return v
+		default:
+			valueBytes, _ := json.Marshal(v)
+			if ref, found := g.refs[string(valueBytes)]; found {
+				return ref
+			}
+			return v
+		}
+	}
+	return replace(normalizeJSON(variables)).(map[string]interface{})
+}
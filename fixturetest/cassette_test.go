@@ -0,0 +1,95 @@
+package fixturetest
+
+import (
+	"context"
+	"errors"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/gmm1900/graphqlfixture"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCassetteFixtures(subjectName string) graphqlfixture.Fixtures {
+	return graphqlfixture.Fixtures{
+		Fixtures: []graphqlfixture.Fixture{
+			{
+				Setup:    `mutation { insert_subjects(objects: [{ name: "` + subjectName + `" }]) { returning { id } } }`,
+				Captors:  map[string]string{"subject_id": "/data/insert_subjects/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($subject_id: Int!) { delete_subjects(where: { id: { _eq: $subject_id } }) { affected_rows } }`),
+			},
+		},
+	}
+}
+
+// runFixtures runs setup and teardown through the transport; returns the first error
+func runFixtures(fixtures graphqlfixture.Fixtures, url string, transport http.RoundTripper) error {
+	graphqlClient := graphqlclient.New(url, &http.Client{Transport: transport}, http.Header{"x-hasura-admin-secret": []string{"adminsecret"}})
+	if err := fixtures.Setup(context.Background(), graphqlClient); err != nil {
+		return err
+	}
+	return fixtures.Teardown(context.Background(), graphqlClient)
+}
+
+func TestCassette(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+
+	// GIVEN: a cassette recorded against a (fake) hasura
+	hasura, err := LoadHasura("../example/hasura")
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := httptest.NewServer(hasura)
+	recorder := NewRecorder(nil)
+	assert.NoError(t, runFixtures(newCassetteFixtures("CS101"), server.URL, recorder))
+	assert.NoError(t, recorder.Save(cassettePath))
+	server.Close() // no server from now on
+
+	cassetteBytes, err := ioutil.ReadFile(cassettePath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(cassetteBytes), `"subject_id": "{{#0/data/insert_subjects/returning/0/id}}"`)
+	assert.NotContains(t, string(cassetteBytes), "adminsecret")
+
+	t.Run("replays offline", func(t *testing.T) {
+		replayer, err := NewReplayer(cassettePath)
+		assert.NoError(t, err)
+		assert.NoError(t, runFixtures(newCassetteFixtures("CS101"), server.URL, replayer))
+		assert.NoError(t, replayer.Done())
+	})
+
+	t.Run("generated values are matched by where they were found", func(t *testing.T) {
+		// the id in the recorded response changed, e.g., re-recorded on a db with more rows
+		edited := strings.Replace(string(cassetteBytes), `"id": 1`, `"id": 42`, 1)
+		assert.NotEqual(t, string(cassetteBytes), edited)
+		editedPath := filepath.Join(t.TempDir(), "edited.json")
+		assert.NoError(t, ioutil.WriteFile(editedPath, []byte(edited), 0644))
+
+		replayer, err := NewReplayer(editedPath)
+		assert.NoError(t, err)
+		assert.NoError(t, runFixtures(newCassetteFixtures("CS101"), server.URL, replayer))
+		assert.NoError(t, replayer.Done())
+	})
+
+	t.Run("changed fixture is a stale cassette", func(t *testing.T) {
+		replayer, err := NewReplayer(cassettePath)
+		assert.NoError(t, err)
+		err = runFixtures(newCassetteFixtures("CS102"), server.URL, replayer)
+		assert.True(t, errors.Is(err, ErrStaleCassette))
+		assert.Contains(t, err.Error(), `request #0 differs from the recorded one: query "mutation { insert_subjects(objects: [{ name: \"CS102\" }]) { returning { id } } }"`)
+		assert.Error(t, replayer.Done())
+	})
+
+	t.Run("Cassette replays the existing file", func(t *testing.T) {
+		ft := &fakeT{}
+		transport := Cassette(ft, cassettePath, nil)
+		assert.IsType(t, &Replayer{}, transport)
+		assert.NoError(t, runFixtures(newCassetteFixtures("CS101"), server.URL, transport))
+		ft.end()
+		assert.Empty(t, ft.errors)
+	})
+}
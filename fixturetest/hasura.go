@@ -0,0 +1,999 @@
+package fixturetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hasura is an in-memory fake of the Hasura graphql engine, built from the migrations and metadata of a Hasura
+// project (see hasurameta), so that fixtures can run without docker, Postgres or Hasura.
+//
+// It serves `insert_X`, `insert_X_one`, `delete_X`, `delete_X_by_pk`, `X` and `X_by_pk`, with `returning`,
+// nested inserts and relationships (per the metadata), `where` (`_eq`, `_neq`, `_in`, `_nin`, `_is_null`, `_gt`,
+// `_gte`, `_lt`, `_lte`, `_and`, `_or`, `_not`, and through relationships), `limit`, `offset` and `order_by`.
+// Serial columns get sequential ids; NOT NULL, PRIMARY KEY, UNIQUE and FOREIGN KEY constraints (with ON DELETE
+// cascade / set null / restrict) are enforced, and violations are reported with the Hasura error codes.
+// Each request runs in a transaction: nothing is changed if it fails.
+//
+// Hasura can be used as the fixtures' executor (it has the same Do method as graphqlclient.Client),
+// or as an http.Handler, e.g., with httptest.NewServer.
+type Hasura struct {
+	schema    *hasurameta.Schema
+	mu        sync.Mutex
+	rows      map[string][]*record // keyed by the graphql table name, in insertion order
+	sequences map[string]float64   // keyed by "<graphql table name>.<column>"
+}
+
+// record is a table row; compared by pointer for identity
+type record struct {
+	values map[string]interface{}
+}
+
+// NewHasura returns an empty fake Hasura of the schema
+func NewHasura(schema *hasurameta.Schema) *Hasura {
+	h := &Hasura{schema: schema}
+	h.Reset()
+	return h
+}
+
+// LoadHasura returns an empty fake Hasura of the Hasura project in dir (with `migrations/` and `metadata/`)
+func LoadHasura(dir string) (*Hasura, error) {
+	schema, err := hasurameta.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewHasura(schema), nil
+}
+
+// Reset deletes all the rows and restarts the sequences
+func (h *Hasura) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rows = map[string][]*record{}
+	h.sequences = map[string]float64{}
+}
+
+// Rows returns (a copy of) the rows of the table, by graphql name, in insertion order
+func (h *Hasura) Rows(table string) []map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var rows []map[string]interface{}
+	for _, rec := range h.rows[table] {
+		rows = append(rows, copyValues(rec.values))
+	}
+	return rows
+}
+
+// Do executes the graphql request, like graphqlclient.Client.Do: the response is written into *[]byte as is,
+// or else json-unmarshalled
+func (h *Hasura) Do(ctx context.Context, req graphqlclient.Request, response interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	respBytes := h.Execute(req)
+	if bytesResponse, ok := response.(*[]byte); ok {
+		*bytesResponse = respBytes
+		return nil
+	}
+	return json.Unmarshal(respBytes, response)
+}
+
+// ServeHTTP serves the graphql requests, as the `/v1/graphql` endpoint
+func (h *Hasura) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req graphqlclient.Request
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(bodyBytes, &req)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.Write(errorResponse(&hasuraError{code: "invalid-json", message: err.Error()}))
+		return
+	}
+	w.Write(h.Execute(req))
+}
+
+// Execute executes the graphql request, and returns the json response
+func (h *Hasura) Execute(req graphqlclient.Request) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// snapshot, to rollback on error
+	savedRows := map[string][]*record{}
+	for table, recs := range h.rows {
+		var saved []*record
+		for _, rec := range recs {
+			saved = append(saved, &record{values: copyValues(rec.values)})
+		}
+		savedRows[table] = saved
+	}
+	savedSequences := map[string]float64{}
+	for key, value := range h.sequences {
+		savedSequences[key] = value
+	}
+
+	data, err := h.execute(req)
+	if err != nil {
+		h.rows, h.sequences = savedRows, savedSequences
+		return errorResponse(err)
+	}
+	respBytes, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return errorResponse(&hasuraError{code: "unexpected", message: err.Error()})
+	}
+	return respBytes
+}
+
+// hasuraError is an error with the Hasura error code, e.g., "constraint-violation"
+type hasuraError struct {
+	code    string
+	path    string
+	message string
+}
+
+func (e *hasuraError) Error() string { return e.message }
+
+func validationError(format string, args ...interface{}) error {
+	return &hasuraError{code: "validation-failed", message: fmt.Sprintf(format, args...)}
+}
+
+func constraintError(format string, args ...interface{}) error {
+	return &hasuraError{code: "constraint-violation", message: fmt.Sprintf(format, args...)}
+}
+
+func errorResponse(err error) []byte {
+	hErr, ok := err.(*hasuraError)
+	if !ok {
+		hErr = &hasuraError{code: "unexpected", message: err.Error()}
+	}
+	path := hErr.path
+	if path == "" {
+		path = "$"
+	}
+	respBytes, _ := json.Marshal(map[string]interface{}{
+		"errors": []interface{}{map[string]interface{}{
+			"extensions": map[string]interface{}{"path": path, "code": hErr.code},
+			"message":    hErr.message,
+		}},
+	})
+	return respBytes
+}
+
+// request is the state of executing a request
+type request struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func (h *Hasura) execute(req graphqlclient.Request) (*orderedMap, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return nil, &hasuraError{code: "validation-failed", message: fmt.Sprintf("not a valid graphql query: %v", err)}
+	}
+	r := &request{fragments: map[string]*ast.FragmentDefinition{}, variables: normalizeJSON(req.Variables).(map[string]interface{})}
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch node := def.(type) {
+		case *ast.OperationDefinition:
+			if operation == nil && (req.OperationName == "" || (node.Name != nil && node.Name.Value == req.OperationName)) {
+				operation = node
+			}
+		case *ast.FragmentDefinition:
+			r.fragments[node.Name.Value] = node
+		}
+	}
+	if operation == nil {
+		return nil, validationError("operation not found")
+	}
+	for _, varDef := range operation.VariableDefinitions {
+		name := varDef.Variable.Name.Value
+		if _, found := r.variables[name]; !found && varDef.DefaultValue != nil {
+			r.variables[name] = r.value(varDef.DefaultValue)
+		}
+		if _, isNonNull := varDef.Type.(*ast.NonNull); isNonNull && r.variables[name] == nil {
+			return nil, validationError("expecting a value for non-nullable variable: %q", name)
+		}
+	}
+
+	data := &orderedMap{}
+	for _, field := range r.fields(operation.SelectionSet) {
+		value, err := h.rootField(r, operation.Operation, field)
+		if err != nil {
+			if hErr, ok := err.(*hasuraError); ok && hErr.path == "" {
+				hErr.path = "$.selectionSet." + field.Name.Value
+			}
+			return nil, err
+		}
+		data.set(responseKey(field), value)
+	}
+	return data, nil
+}
+
+func (h *Hasura) rootField(r *request, operation string, field *ast.Field) (interface{}, error) {
+	name := field.Name.Value
+	args := r.arguments(field)
+	rootType := operation + "_root"
+	if name == "__typename" {
+		return rootType, nil
+	}
+	switch operation {
+	case ast.OperationTypeMutation:
+		switch {
+		case strings.HasPrefix(name, "insert_") && strings.HasSuffix(name, "_one"):
+			if t := h.schema.Tables[strings.TrimSuffix(strings.TrimPrefix(name, "insert_"), "_one")]; t != nil {
+				object, ok := args["object"].(map[string]interface{})
+				if !ok {
+					return nil, validationError("missing required field 'object'")
+				}
+				recs, _, err := h.insert(t, []interface{}{object})
+				if err != nil {
+					return nil, err
+				}
+				return h.selectRecord(r, t, recs[0], field.SelectionSet)
+			}
+		case strings.HasPrefix(name, "insert_"):
+			if t := h.schema.Tables[strings.TrimPrefix(name, "insert_")]; t != nil {
+				objects, ok := args["objects"].([]interface{})
+				if object, isObject := args["objects"].(map[string]interface{}); isObject { // a list of one may be given as is
+					objects, ok = []interface{}{object}, true
+				}
+				if !ok {
+					return nil, validationError("missing required field 'objects'")
+				}
+				recs, affectedRows, err := h.insert(t, objects)
+				if err != nil {
+					return nil, err
+				}
+				return h.mutationResponse(r, t, recs, affectedRows, field.SelectionSet)
+			}
+		case strings.HasPrefix(name, "delete_") && strings.HasSuffix(name, "_by_pk"):
+			if t := h.schema.Tables[strings.TrimSuffix(strings.TrimPrefix(name, "delete_"), "_by_pk")]; t != nil {
+				recs, err := h.filter(r, t, h.rows[t.GraphqlName()], pkWhere(t, args), nil)
+				if err != nil || len(recs) == 0 {
+					return nil, err
+				}
+				selected, err := h.selectRecord(r, t, recs[0], field.SelectionSet) // before it's gone
+				if err != nil {
+					return nil, err
+				}
+				return selected, h.delete(t, recs)
+			}
+		case strings.HasPrefix(name, "delete_"):
+			if t := h.schema.Tables[strings.TrimPrefix(name, "delete_")]; t != nil {
+				where, ok := args["where"].(map[string]interface{})
+				if !ok {
+					return nil, validationError("missing required field 'where'")
+				}
+				recs, err := h.filter(r, t, h.rows[t.GraphqlName()], where, nil)
+				if err != nil {
+					return nil, err
+				}
+				selected, err := h.mutationResponse(r, t, recs, len(recs), field.SelectionSet) // before they're gone
+				if err != nil {
+					return nil, err
+				}
+				return selected, h.delete(t, recs)
+			}
+		}
+	case ast.OperationTypeQuery:
+		if t := h.schema.Tables[strings.TrimSuffix(name, "_by_pk")]; t != nil && strings.HasSuffix(name, "_by_pk") {
+			recs, err := h.filter(r, t, h.rows[t.GraphqlName()], pkWhere(t, args), nil)
+			if err != nil || len(recs) == 0 {
+				return nil, err
+			}
+			return h.selectRecord(r, t, recs[0], field.SelectionSet)
+		}
+		if t := h.schema.Tables[name]; t != nil {
+			recs, err := h.filter(r, t, h.rows[t.GraphqlName()], args["where"], args)
+			if err != nil {
+				return nil, err
+			}
+			return h.selectRecords(r, t, recs, field.SelectionSet)
+		}
+	}
+	return nil, validationError("field %q not found in type: '%s'", name, rootType)
+}
+
+// pkWhere returns the where matching the primary key given as the arguments (of X_by_pk)
+func pkWhere(t *hasurameta.Table, args map[string]interface{}) map[string]interface{} {
+	where := map[string]interface{}{}
+	for _, column := range t.PrimaryKey {
+		where[column] = map[string]interface{}{"_eq": args[column]}
+	}
+	return where
+}
+
+func (h *Hasura) mutationResponse(r *request, t *hasurameta.Table, recs []*record, affectedRows int, selectionSet *ast.SelectionSet) (interface{}, error) {
+	result := &orderedMap{}
+	for _, field := range r.fields(selectionSet) {
+		switch field.Name.Value {
+		case "affected_rows":
+			result.set(responseKey(field), affectedRows)
+		case "returning":
+			returning, err := h.selectRecords(r, t, recs, field.SelectionSet)
+			if err != nil {
+				return nil, err
+			}
+			result.set(responseKey(field), returning)
+		case "__typename":
+			result.set(responseKey(field), t.GraphqlName()+"_mutation_response")
+		default:
+			return nil, validationError("field %q not found in type: '%s_mutation_response'", field.Name.Value, t.GraphqlName())
+		}
+	}
+	return result, nil
+}
+
+// insert inserts the objects (with their nested inserts); returns the records of the objects, and the number of
+// rows inserted including the nested ones
+func (h *Hasura) insert(t *hasurameta.Table, objects []interface{}) ([]*record, int, error) {
+	var recs []*record
+	affectedRows := 0
+	for _, obj := range objects {
+		object, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, 0, validationError("expected an object for type '%s_insert_input'", t.GraphqlName())
+		}
+		rec, count, err := h.insertObject(t, object)
+		if err != nil {
+			return nil, 0, err
+		}
+		recs = append(recs, rec)
+		affectedRows += count
+	}
+	return recs, affectedRows, nil
+}
+
+func (h *Hasura) insertObject(t *hasurameta.Table, object map[string]interface{}) (*record, int, error) {
+	rec := &record{values: map[string]interface{}{}}
+	affectedRows := 1
+	arrayInserts := map[*hasurameta.Relationship]interface{}{}
+
+	// the columns, and the object relationships (inserted first, as this row references them)
+	for _, key := range sortedKeys(object) {
+		value := object[key]
+		if t.Column(key) != nil {
+			rec.values[key] = value
+			continue
+		}
+		rel, isArray := t.Relationship(key)
+		if rel == nil {
+			return nil, 0, validationError("field %q not found in type: '%s_insert_input'", key, t.GraphqlName())
+		}
+		data, _ := value.(map[string]interface{})
+		if data == nil || data["data"] == nil {
+			return nil, 0, validationError("missing required field 'data' for relationship %q", key)
+		}
+		if isArray {
+			arrayInserts[rel] = data["data"]
+			continue
+		}
+		remote := h.schema.Table(rel.RemoteSchema, rel.RemoteTable)
+		remoteObject, ok := data["data"].(map[string]interface{})
+		if !ok {
+			return nil, 0, validationError("expected an object for relationship %q", key)
+		}
+		remoteRec, count, err := h.insertObject(remote, remoteObject)
+		if err != nil {
+			return nil, 0, err
+		}
+		affectedRows += count
+		for i, column := range rel.Columns {
+			rec.values[column] = remoteRec.values[rel.RemoteColumns[i]]
+		}
+	}
+
+	// defaults
+	for _, column := range t.Columns {
+		if _, given := rec.values[column.Name]; given {
+			continue
+		}
+		rec.values[column.Name] = h.defaultValue(t, column)
+	}
+
+	if err := h.checkConstraints(t, rec); err != nil {
+		return nil, 0, err
+	}
+	h.rows[t.GraphqlName()] = append(h.rows[t.GraphqlName()], rec)
+
+	// the array relationships (inserted after, as they reference this row)
+	for _, rel := range sortedRelationships(arrayInserts) {
+		remote := h.schema.Table(rel.RemoteSchema, rel.RemoteTable)
+		remoteObjects, ok := arrayInserts[rel].([]interface{})
+		if remoteObject, isObject := arrayInserts[rel].(map[string]interface{}); isObject { // a list of one may be given as is
+			remoteObjects, ok = []interface{}{remoteObject}, true
+		}
+		if !ok {
+			return nil, 0, validationError("expected a list for relationship %q", rel.Name)
+		}
+		for _, remoteObj := range remoteObjects {
+			remoteObject, ok := remoteObj.(map[string]interface{})
+			if !ok {
+				return nil, 0, validationError("expected an object for relationship %q", rel.Name)
+			}
+			remoteObject = copyValues(remoteObject)
+			for i, column := range rel.Columns {
+				remoteObject[rel.RemoteColumns[i]] = rec.values[column]
+			}
+			_, count, err := h.insertObject(remote, remoteObject)
+			if err != nil {
+				return nil, 0, err
+			}
+			affectedRows += count
+		}
+	}
+	return rec, affectedRows, nil
+}
+
+var (
+	nextvalRegexp       = regexp.MustCompile(`(?i)^nextval\(`)
+	defaultLiteralRegex = regexp.MustCompile(`^'((?:[^']|'')*)'(?:::.*)?$`)
+)
+
+// defaultValue returns the value of the column when not given: the next id for serials, the time for now(),
+// or the literal default
+func (h *Hasura) defaultValue(t *hasurameta.Table, column *hasurameta.Column) interface{} {
+	if strings.HasSuffix(column.Type, "serial") || nextvalRegexp.MatchString(column.Default) {
+		key := t.GraphqlName() + "." + column.Name
+		h.sequences[key]++
+		return h.sequences[key]
+	}
+	def := column.Default
+	switch lower := strings.ToLower(def); {
+	case def == "":
+		return nil
+	case lower == "now()" || lower == "current_timestamp" || strings.HasPrefix(lower, "now()::"):
+		return time.Now().UTC().Format("2006-01-02T15:04:05.999999+00:00")
+	case lower == "true" || lower == "false":
+		return lower == "true"
+	case lower == "null" || strings.HasPrefix(lower, "null::"):
+		return nil
+	}
+	if m := defaultLiteralRegex.FindStringSubmatch(def); m != nil {
+		return strings.ReplaceAll(m[1], "''", "'")
+	}
+	if f, err := strconv.ParseFloat(def, 64); err == nil {
+		return f
+	}
+	return nil // e.g., gen_random_uuid(): not supported
+}
+
+// checkConstraints checks the NOT NULL, PRIMARY KEY, UNIQUE and FOREIGN KEY constraints of the new record
+func (h *Hasura) checkConstraints(t *hasurameta.Table, rec *record) error {
+	for _, column := range t.Columns {
+		if column.NotNull && rec.values[column.Name] == nil {
+			return constraintError("Not-NULL violation. null value in column %q violates not-null constraint", column.Name)
+		}
+	}
+	uniques := t.Uniques
+	if len(t.PrimaryKey) > 0 {
+		uniques = append([]*hasurameta.Unique{{Name: t.Name + "_pkey", Columns: t.PrimaryKey}}, uniques...)
+	}
+	for _, unique := range uniques {
+		for _, existing := range h.rows[t.GraphqlName()] {
+			if existing != rec && sameValues(existing.values, unique.Columns, rec.values, unique.Columns) {
+				return constraintError("Uniqueness violation. duplicate key value violates unique constraint %q", unique.Name)
+			}
+		}
+	}
+	for _, fk := range t.ForeignKeys {
+		if hasNull(rec.values, fk.Columns) {
+			continue
+		}
+		refTable := h.schema.Table(fk.RefSchema, fk.RefTable)
+		found := false
+		if refTable != nil {
+			for _, ref := range h.rows[refTable.GraphqlName()] {
+				found = found || sameValues(ref.values, fk.RefColumns, rec.values, fk.Columns)
+			}
+		}
+		if !found {
+			return constraintError("Foreign key violation. insert or update on table %q violates foreign key constraint %q", t.Name, fk.Name)
+		}
+	}
+	return nil
+}
+
+// delete deletes the records, applying the ON DELETE of the foreign keys referencing them
+func (h *Hasura) delete(t *hasurameta.Table, recs []*record) error {
+	deleting := map[*record]bool{}
+	for _, rec := range recs {
+		deleting[rec] = true
+	}
+	var remaining []*record
+	for _, rec := range h.rows[t.GraphqlName()] {
+		if !deleting[rec] {
+			remaining = append(remaining, rec)
+		}
+	}
+	h.rows[t.GraphqlName()] = remaining
+
+	for _, other := range h.schema.SortedTables() {
+		for _, fk := range other.ForeignKeys {
+			if fk.RefSchema != t.Schema || fk.RefTable != t.Name {
+				continue
+			}
+			var referencing []*record
+			for _, ref := range h.rows[other.GraphqlName()] {
+				for _, rec := range recs {
+					if !hasNull(ref.values, fk.Columns) && sameValues(ref.values, fk.Columns, rec.values, fk.RefColumns) {
+						referencing = append(referencing, ref)
+						break
+					}
+				}
+			}
+			if len(referencing) == 0 {
+				continue
+			}
+			switch fk.OnDelete {
+			case "cascade":
+				if err := h.delete(other, referencing); err != nil {
+					return err
+				}
+			case "set null", "set default":
+				for _, ref := range referencing {
+					for _, column := range fk.Columns {
+						ref.values[column] = nil
+						if fk.OnDelete == "set default" {
+							ref.values[column] = h.defaultValue(other, other.Column(column))
+						}
+						if c := other.Column(column); c != nil && c.NotNull && ref.values[column] == nil {
+							return constraintError("Not-NULL violation. null value in column %q violates not-null constraint", column)
+						}
+					}
+				}
+			default: // restrict, no action
+				return constraintError("Foreign key violation. update or delete on table %q violates foreign key constraint %q on table %q",
+					t.Name, fk.Name, other.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// filter returns the records matching the where, then ordered, offset and limited per args (if any)
+func (h *Hasura) filter(r *request, t *hasurameta.Table, recs []*record, where interface{}, args map[string]interface{}) ([]*record, error) {
+	var matched []*record
+	for _, rec := range recs {
+		ok, err := h.matches(r, t, rec, where)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, rec)
+		}
+	}
+	if args == nil {
+		return matched, nil
+	}
+	if orderBy, found := args["order_by"]; found {
+		if err := orderRecords(t, matched, orderBy); err != nil {
+			return nil, err
+		}
+	}
+	if offset, ok := args["offset"].(float64); ok {
+		if int(offset) >= len(matched) {
+			matched = nil
+		} else {
+			matched = matched[int(offset):]
+		}
+	}
+	if limit, ok := args["limit"].(float64); ok && int(limit) < len(matched) {
+		matched = matched[:int(limit)]
+	}
+	return matched, nil
+}
+
+// matches evaluates the where (a `X_bool_exp`) on the record
+func (h *Hasura) matches(r *request, t *hasurameta.Table, rec *record, where interface{}) (bool, error) {
+	if where == nil {
+		return true, nil
+	}
+	exp, ok := where.(map[string]interface{})
+	if !ok {
+		return false, validationError("expected an object for type '%s_bool_exp'", t.GraphqlName())
+	}
+	for _, key := range sortedKeys(exp) {
+		value := exp[key]
+		var matched bool
+		var err error
+		switch key {
+		case "_and", "_or":
+			exps, isList := value.([]interface{})
+			if !isList {
+				exps = []interface{}{value}
+			}
+			matched = key == "_and"
+			for _, e := range exps {
+				elemMatched, elemErr := h.matches(r, t, rec, e)
+				if elemErr != nil {
+					return false, elemErr
+				}
+				if key == "_and" {
+					matched = matched && elemMatched
+				} else {
+					matched = matched || elemMatched
+				}
+			}
+		case "_not":
+			matched, err = h.matches(r, t, rec, value)
+			matched = !matched
+		default:
+			if t.Column(key) != nil {
+				matched, err = matchesComparison(key, rec.values[key], value)
+			} else if rel, _ := t.Relationship(key); rel != nil {
+				remote := h.schema.Table(rel.RemoteSchema, rel.RemoteTable)
+				var related []*record
+				related, err = h.filter(r, remote, h.related(rel, rec), value, nil)
+				matched = len(related) > 0
+			} else {
+				err = validationError("field %q not found in type: '%s_bool_exp'", key, t.GraphqlName())
+			}
+		}
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchesComparison evaluates the comparison (a `X_comparison_exp`, e.g., { _eq: 1 }) on the column value
+func matchesComparison(column string, actual interface{}, comparison interface{}) (bool, error) {
+	exp, ok := comparison.(map[string]interface{})
+	if !ok {
+		return false, validationError("expected an object for the comparison of %q", column)
+	}
+	for op, expected := range exp {
+		var matched bool
+		switch op {
+		case "_eq":
+			matched = actual != nil && reflect.DeepEqual(actual, expected)
+		case "_neq":
+			matched = actual != nil && !reflect.DeepEqual(actual, expected)
+		case "_in", "_nin":
+			list, _ := expected.([]interface{})
+			found := false
+			for _, elem := range list {
+				found = found || reflect.DeepEqual(actual, elem)
+			}
+			matched = actual != nil && found == (op == "_in")
+		case "_is_null":
+			matched = (actual == nil) == (expected == true)
+		case "_gt", "_gte", "_lt", "_lte":
+			cmp, comparable := compareValues(actual, expected)
+			matched = comparable && ((op == "_gt" && cmp > 0) || (op == "_gte" && cmp >= 0) || (op == "_lt" && cmp < 0) || (op == "_lte" && cmp <= 0))
+		default:
+			return false, validationError("field %q not supported in the comparison of %q", op, column)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// compareValues compares numbers or strings; returns false if not comparable (e.g., null)
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1, true
+			case av > bv:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, true
+			case !av:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// orderRecords sorts the records per order_by, e.g., { name: asc } or [{ name: desc }, { id: asc }]
+func orderRecords(t *hasurameta.Table, recs []*record, orderBy interface{}) error {
+	orderBys, isList := orderBy.([]interface{})
+	if !isList {
+		orderBys = []interface{}{orderBy}
+	}
+	type key struct {
+		column string
+		desc   bool
+	}
+	var keys []key
+	for _, ob := range orderBys {
+		obMap, ok := ob.(map[string]interface{})
+		if !ok {
+			return validationError("expected an object for type '%s_order_by'", t.GraphqlName())
+		}
+		for _, column := range sortedKeys(obMap) {
+			if t.Column(column) == nil {
+				return validationError("field %q not found in type: '%s_order_by'", column, t.GraphqlName())
+			}
+			direction, _ := obMap[column].(string)
+			keys = append(keys, key{column: column, desc: strings.HasPrefix(direction, "desc")})
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		for _, k := range keys {
+			cmp, _ := compareValues(recs[i].values[k.column], recs[j].values[k.column])
+			if cmp != 0 {
+				return (cmp < 0) != k.desc
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// related returns the records of the relationship's remote table related to the record
+func (h *Hasura) related(rel *hasurameta.Relationship, rec *record) []*record {
+	remote := h.schema.Table(rel.RemoteSchema, rel.RemoteTable)
+	if remote == nil || hasNull(rec.values, rel.Columns) {
+		return nil
+	}
+	var related []*record
+	for _, remoteRec := range h.rows[remote.GraphqlName()] {
+		if sameValues(remoteRec.values, rel.RemoteColumns, rec.values, rel.Columns) {
+			related = append(related, remoteRec)
+		}
+	}
+	return related
+}
+
+func (h *Hasura) selectRecords(r *request, t *hasurameta.Table, recs []*record, selectionSet *ast.SelectionSet) ([]interface{}, error) {
+	selected := []interface{}{}
+	for _, rec := range recs {
+		obj, err := h.selectRecord(r, t, rec, selectionSet)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, obj)
+	}
+	return selected, nil
+}
+
+// selectRecord returns the selected fields (columns and relationships) of the record
+func (h *Hasura) selectRecord(r *request, t *hasurameta.Table, rec *record, selectionSet *ast.SelectionSet) (interface{}, error) {
+	if selectionSet == nil {
+		return nil, validationError("missing selection set for type '%s'", t.GraphqlName())
+	}
+	obj := &orderedMap{}
+	for _, field := range r.fields(selectionSet) {
+		name := field.Name.Value
+		if name == "__typename" {
+			obj.set(responseKey(field), t.GraphqlName())
+			continue
+		}
+		if t.Column(name) != nil {
+			obj.set(responseKey(field), rec.values[name])
+			continue
+		}
+		rel, isArray := t.Relationship(name)
+		if rel == nil {
+			return nil, validationError("field %q not found in type: '%s'", name, t.GraphqlName())
+		}
+		remote := h.schema.Table(rel.RemoteSchema, rel.RemoteTable)
+		related := h.related(rel, rec)
+		if !isArray {
+			if len(related) == 0 {
+				obj.set(responseKey(field), nil)
+				continue
+			}
+			value, err := h.selectRecord(r, remote, related[0], field.SelectionSet)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(responseKey(field), value)
+			continue
+		}
+		args := r.arguments(field)
+		related, err := h.filter(r, remote, related, args["where"], args)
+		if err != nil {
+			return nil, err
+		}
+		value, err := h.selectRecords(r, remote, related, field.SelectionSet)
+		if err != nil {
+			return nil, err
+		}
+		obj.set(responseKey(field), value)
+	}
+	return obj, nil
+}
+
+// fields flattens the selection set into fields, expanding the fragments
+func (r *request) fields(selectionSet *ast.SelectionSet) []*ast.Field {
+	if selectionSet == nil {
+		return nil
+	}
+	var fields []*ast.Field
+	for _, selection := range selectionSet.Selections {
+		switch node := selection.(type) {
+		case *ast.Field:
+			fields = append(fields, node)
+		case *ast.InlineFragment:
+			fields = append(fields, r.fields(node.SelectionSet)...)
+		case *ast.FragmentSpread:
+			if fragment, found := r.fragments[node.Name.Value]; found {
+				fields = append(fields, r.fields(fragment.SelectionSet)...)
+			}
+		}
+	}
+	return fields
+}
+
+// arguments returns the field's arguments as (json-decoded) go values, with the variables resolved
+func (r *request) arguments(field *ast.Field) map[string]interface{} {
+	args := map[string]interface{}{}
+	for _, arg := range field.Arguments {
+		args[arg.Name.Value] = r.value(arg.Value)
+	}
+	return args
+}
+
+// value converts the graphql value into (json-decoded) go value
+func (r *request) value(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.Variable:
+		return r.variables[v.Name.Value]
+	case *ast.IntValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(v.Values))
+		for _, elem := range v.Values {
+			list = append(list, r.value(elem))
+		}
+		return list
+	case *ast.ObjectValue:
+		obj := map[string]interface{}{}
+		for _, field := range v.Fields {
+			obj[field.Name.Value] = r.value(field.Value)
+		}
+		return obj
+	}
+	return nil
+}
+
+// responseKey returns the key of the field in the response: the alias if any, otherwise the field name
+func responseKey(field *ast.Field) string {
+	if field.Alias != nil {
+		return field.Alias.Value
+	}
+	return field.Name.Value
+}
+
+// orderedMap is a json object keeping the keys in the order of the selection set, as Hasura does
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = map[string]interface{}{}
+	}
+	if _, found := m.values[key]; !found {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf strings.Builder
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyBytes, _ := json.Marshal(key)
+		valueBytes, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyBytes)
+		buf.WriteByte(':')
+		buf.Write(valueBytes)
+	}
+	buf.WriteByte('}')
+	return []byte(buf.String()), nil
+}
+
+// normalizeJSON round trips the value through json, e.g., for the numbers to be float64 as in the records
+func normalizeJSON(value interface{}) interface{} {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	json.Unmarshal(jsonBytes, &normalized)
+	if normalized == nil {
+		if _, isMap := value.(map[string]interface{}); isMap || value == nil {
+			return map[string]interface{}{}
+		}
+	}
+	return normalized
+}
+
+func sameValues(a map[string]interface{}, aColumns []string, b map[string]interface{}, bColumns []string) bool {
+	if hasNull(a, aColumns) || hasNull(b, bColumns) {
+		return false // null is never equal, as in sql
+	}
+	for i := range aColumns {
+		if !reflect.DeepEqual(a[aColumns[i]], b[bColumns[i]]) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasNull(values map[string]interface{}, columns []string) bool {
+	for _, column := range columns {
+		if values[column] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+	return copied
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedRelationships(m map[*hasurameta.Relationship]interface{}) []*hasurameta.Relationship {
+	rels := make([]*hasurameta.Relationship, 0, len(m))
+	for rel := range m {
+		rels = append(rels, rel)
+	}
+	sort.Slice(rels, func(i, j int) bool { return rels[i].Name < rels[j].Name })
+	return rels
+}
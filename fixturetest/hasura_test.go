@@ -0,0 +1,200 @@
+package fixturetest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/gmm1900/graphqlfixture"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// the fake hasura can be used as the fixtures' executor
+var _ graphqlfixture.Executor = (*Hasura)(nil)
+
+func TestHasura(t *testing.T) {
+	testCases := []struct {
+		name         string
+		requests     []graphqlclient.Request // the last one is asserted
+		expectedResp string
+	}{
+		{
+			name: "insert with nested inserts and returning through relationships",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_subjects(objects: [{ name: "CS101" }, { name: "CS102" }]) { affected_rows } }`},
+				{
+					Query: `mutation ($cs102: Int!) {
+						insert_instructors(objects: [{ name: "Murphy", teaches: { data: [{ subject_id: 1 }, { subject_id: $cs102 }] } }]) {
+							affected_rows
+							returning { id name teaches(order_by: { subject_id: desc }) { subject { name } } }
+						}
+					}`,
+					Variables: map[string]interface{}{"cs102": 2},
+				},
+			},
+			expectedResp: `{"data":{"insert_instructors":{"affected_rows":3,"returning":[{"id":1,"name":"Murphy","teaches":[{"subject":{"name":"CS102"}},{"subject":{"name":"CS101"}}]}]}}}`,
+		},
+		{
+			name: "insert one with nested object insert",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_teaches_one(object: { instructor: { data: { name: "Murphy" } }, subject: { data: { name: "CS101" } } }) { id instructor_id instructor { name } } }`},
+			},
+			expectedResp: `{"data":{"insert_teaches_one":{"id":1,"instructor_id":1,"instructor":{"name":"Murphy"}}}}`,
+		},
+		{
+			name: "query with where, aliases and by pk",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_subjects(objects: [{ name: "CS101" }, { name: "CS102" }, { name: "CS103" }]) { affected_rows } }`},
+				{Query: `query {
+					some: subjects(where: { _or: [{ name: { _eq: "CS101" } }, { id: { _in: [3] } }] }, order_by: { id: desc }) { id }
+					none: subjects(where: { name: { _is_null: true } }) { id }
+					subjects_by_pk(id: 2) { name }
+				}`},
+			},
+			expectedResp: `{"data":{"some":[{"id":3},{"id":1}],"none":[],"subjects_by_pk":{"name":"CS102"}}}`,
+		},
+		{
+			name: "delete cascades",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_instructors_one(object: { name: "Murphy", teaches: { data: { subject: { data: { name: "CS101" } } } } }) { id } }`},
+				{Query: `mutation { delete_subjects(where: { name: { _eq: "CS101" } }) { affected_rows returning { id name } } }`},
+				{Query: `query { teaches { id } instructors { name } }`},
+			},
+			expectedResp: `{"data":{"teaches":[],"instructors":[{"name":"Murphy"}]}}`,
+		},
+		{
+			name: "delete by pk",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_subjects_one(object: { name: "CS101" }) { id } }`},
+				{Query: `mutation { a: delete_subjects_by_pk(id: 1) { name } b: delete_subjects_by_pk(id: 1) { name } }`},
+			},
+			expectedResp: `{"data":{"a":{"name":"CS101"},"b":null}}`,
+		},
+		{
+			name: "unique violation",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_subjects(objects: [{ name: "CS101" }, { name: "CS101" }]) { affected_rows } }`},
+			},
+			expectedResp: `{"errors":[{"extensions":{"code":"constraint-violation","path":"$.selectionSet.insert_subjects"},"message":"Uniqueness violation. duplicate key value violates unique constraint \"subjects_name_key\""}]}`,
+		},
+		{
+			name: "foreign key violation",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_teaches(objects: [{ instructor_id: 1, subject_id: 1 }]) { affected_rows } }`},
+			},
+			expectedResp: `{"errors":[{"extensions":{"code":"constraint-violation","path":"$.selectionSet.insert_teaches"},"message":"Foreign key violation. insert or update on table \"teaches\" violates foreign key constraint \"teaches_instructor_id_fkey\""}]}`,
+		},
+		{
+			name: "not null violation",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { insert_subjects(objects: [{}]) { affected_rows } }`},
+			},
+			expectedResp: `{"errors":[{"extensions":{"code":"constraint-violation","path":"$.selectionSet.insert_subjects"},"message":"Not-NULL violation. null value in column \"name\" violates not-null constraint"}]}`,
+		},
+		{
+			name: "failed request is rolled back",
+			requests: []graphqlclient.Request{
+				{Query: `mutation { a: insert_subjects(objects: [{ name: "CS101" }]) { affected_rows } b: insert_subjects(objects: [{ name: "CS101" }]) { affected_rows } }`},
+				{Query: `mutation { insert_subjects(objects: [{ name: "CS101" }]) { returning { id } } }`},
+			},
+			expectedResp: `{"data":{"insert_subjects":{"returning":[{"id":1}]}}}`,
+		},
+		{
+			name: "unknown field",
+			requests: []graphqlclient.Request{
+				{Query: `query { courses { id } }`},
+			},
+			expectedResp: `{"errors":[{"extensions":{"code":"validation-failed","path":"$.selectionSet.courses"},"message":"field \"courses\" not found in type: 'query_root'"}]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hasura, err := LoadHasura("../example/hasura")
+			if !assert.NoError(t, err) {
+				return
+			}
+			var resp []byte
+			for _, req := range tc.requests {
+				assert.NoError(t, hasura.Do(context.Background(), req, &resp))
+			}
+			assert.JSONEq(t, tc.expectedResp, string(resp))
+		})
+	}
+}
+
+func TestHasuraRunsFixtures(t *testing.T) {
+	// GIVEN: the fake hasura, served over http as the real one would be
+	hasura, err := LoadHasura("../example/hasura")
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := httptest.NewServer(hasura)
+	defer server.Close()
+	graphqlClient := graphqlclient.New(server.URL, nil, http.Header{})
+
+	fixtures := graphqlfixture.Fixtures{
+		Fixtures: []graphqlfixture.Fixture{
+			{
+				Setup:    `mutation { insert_subjects(objects: [{ name: "CS101" }]) { returning { id } } }`,
+				Captors:  map[string]string{"cs101_id": "/data/insert_subjects/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($cs101_id: Int!) { delete_subjects(where: { id: { _eq: $cs101_id } }) { affected_rows } }`),
+			},
+			{
+				Setup: `mutation ($cs101_id: Int!) {
+					insert_instructors(objects: [{ name: "Murphy", teaches: { data: { subject_id: $cs101_id } } }]) {
+						returning { id teaches { subject { name } } }
+					}
+				}`,
+				Captors: map[string]string{
+					"murphy_id":      "/data/insert_instructors/returning/0/id",
+					"murphy_subject": "/data/insert_instructors/returning/0/teaches/0/subject/name",
+				},
+				Teardown: gopointer.OfString(`mutation ($murphy_id: Int!) { delete_instructors(where: { id: { _eq: $murphy_id } }) { affected_rows } }`),
+			},
+		},
+	}
+
+	// WHEN: setup
+	ctx := context.Background()
+	assert.NoError(t, fixtures.Setup(ctx, graphqlClient))
+
+	// THEN
+	var subject string
+	assert.NoError(t, fixtures.GetAndParse("murphy_subject", &subject))
+	assert.Equal(t, "CS101", subject)
+	assert.Len(t, hasura.Rows("teaches"), 1)
+
+	// WHEN: teardown
+	assert.NoError(t, fixtures.Teardown(ctx, graphqlClient))
+
+	// THEN: all cleaned up
+	for _, table := range []string{"subjects", "instructors", "teaches"} {
+		assert.Empty(t, hasura.Rows(table), table)
+	}
+}
+
+func TestHasuraRows(t *testing.T) {
+	hasura, err := LoadHasura("../example/hasura")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var resp map[string]interface{}
+	assert.NoError(t, hasura.Do(context.Background(), graphqlclient.Request{
+		Query: `mutation { insert_subjects_one(object: { name: "CS101" }) { id } }`,
+	}, &resp))
+	respBytes, _ := json.Marshal(resp)
+	assert.JSONEq(t, `{"data":{"insert_subjects_one":{"id":1}}}`, string(respBytes))
+
+	rows := hasura.Rows("subjects")
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, "CS101", rows[0]["name"])
+		assert.NotEmpty(t, rows[0]["created_at"]) // default now()
+	}
+
+	hasura.Reset()
+	assert.Empty(t, hasura.Rows("subjects"))
+}
@@ -0,0 +1,379 @@
+// Package fixturetest provides a mock graphql server for testing code that runs fixtures.
+//
+// Unlike graphqlclient.MockGraphqlServer, which replays responses by index, the Server matches each request against
+// the expectations (by operation name, root field, query text or variables), returns the scripted response,
+// and verifies at the end of the test that every expectation has been met (called N times, in order if asked).
+package fixturetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/lexer"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TestingT is the subset of testing.TB used by the Server
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// Request is a graphql request received by the Server
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Header        http.Header            `json:"-"`
+}
+
+// Server is a mock graphql server (a real http server on localhost). Create it with NewServer.
+type Server struct {
+	URL string // the graphql endpoint
+
+	t            TestingT
+	httpServer   *httptest.Server
+	mu           sync.Mutex
+	expectations []*Expectation
+	inOrder      bool
+	lastMatched  int // idx of the expectation matched last, for InOrder
+	requests     []Request
+}
+
+// NewServer starts a mock graphql server; it's closed, and the expectations verified, when the test ends
+func NewServer(t TestingT) *Server {
+	s := &Server{t: t, lastMatched: -1}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.httpServer.URL
+	t.Cleanup(func() {
+		s.httpServer.Close()
+		s.Verify()
+	})
+	return s
+}
+
+// InOrder requires the expectations to be met in the order they are declared
+func (s *Server) InOrder() *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inOrder = true
+	return s
+}
+
+// Expect declares an expected request; by default it matches any request, expected exactly once,
+// and responds with `{ "data": {} }`
+func (s *Server) Expect() *Expectation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := &Expectation{idx: len(s.expectations), times: 1, status: http.StatusOK, body: []byte(`{ "data": {} }`)}
+	s.expectations = append(s.expectations, e)
+	return e
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// Verify reports the expectations not met (yet); it's called automatically when the test ends
+func (s *Server) Verify() {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.expectations {
+		if e.times >= 0 && e.calls != e.times {
+			s.t.Errorf("fixturetest: expectation %s: called %d time(s), expected %d", e, e.calls, e.times)
+		}
+	}
+}
+
+// serveHTTP answers a request; or a batch (a json array of requests, as sent by graphqlfixture.BatchClient) with the
+// array of the responses. A batch item responding with an http status other than 200 answers the whole batch.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var reqs []Request
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	isBatch := err == nil && strings.HasPrefix(strings.TrimSpace(string(bodyBytes)), "[")
+	if err == nil && isBatch {
+		err = json.Unmarshal(bodyBytes, &reqs)
+	} else if err == nil {
+		reqs = make([]Request, 1)
+		err = json.Unmarshal(bodyBytes, &reqs[0])
+	}
+	if err != nil {
+		s.t.Errorf("fixturetest: fail to read graphql request: %v", err)
+		writeError(w, fmt.Sprintf("fixturetest: invalid request: %v", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bodies := make([][]byte, 0, len(reqs))
+	for _, req := range reqs {
+		req.Header = r.Header
+		status, body := s.respond(req)
+		if status != http.StatusOK || !isBatch {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(body)
+			return
+		}
+		bodies = append(bodies, body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("["))
+	w.Write(bytes.Join(bodies, []byte(",")))
+	w.Write([]byte("]"))
+}
+
+// respond returns the http status and body scripted by the expectation the request matches
+func (s *Server) respond(req Request) (int, []byte) {
+	s.requests = append(s.requests, req)
+	for _, e := range s.expectations {
+		if !e.matches(req) || (e.times >= 0 && e.calls >= e.times) {
+			continue
+		}
+		if s.inOrder && e.idx < s.lastMatched {
+			s.t.Errorf("fixturetest: expectation %s matched out of order: %s", e, req.Query)
+		}
+		if e.idx > s.lastMatched {
+			s.lastMatched = e.idx
+		}
+		e.calls++
+		return e.status, e.body
+	}
+	s.t.Errorf("fixturetest: unexpected request: %s (variables: %v)", req.Query, req.Variables)
+	return http.StatusOK, errorBody("fixturetest: no expectation matches the request")
+}
+
+func writeError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(errorBody(message))
+}
+
+// errorBody returns a graphql response with the error
+func errorBody(message string) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"errors": []interface{}{map[string]interface{}{"message": message, "extensions": map[string]interface{}{"code": "unexpected"}}},
+	})
+	return body
+}
+
+// Expectation is an expected request with its scripted response. All the given criteria must match.
+// Its methods are to be called while declaring, i.e., before the requests are sent.
+type Expectation struct {
+	idx           int
+	operationName *string
+	rootField     *string
+	query         *string
+	queryContains *string
+	variables     map[string]interface{}
+	times         int // -1 for any number of times
+	calls         int
+	status        int
+	body          []byte
+}
+
+// OperationName matches the request's operationName, or else the name of the operation in the query
+func (e *Expectation) OperationName(name string) *Expectation {
+	e.operationName = &name
+	return e
+}
+
+// RootField matches the requests whose operation selects the (root) field, e.g., "insert_abc"
+func (e *Expectation) RootField(name string) *Expectation {
+	e.rootField = &name
+	return e
+}
+
+// Query matches the query text, ignoring differences in whitespace
+func (e *Expectation) Query(query string) *Expectation {
+	normalized := normalizeSpace(query)
+	e.query = &normalized
+	return e
+}
+
+// QueryContains matches the query text containing substr, ignoring differences in whitespace
+func (e *Expectation) QueryContains(substr string) *Expectation {
+	normalized := normalizeSpace(substr)
+	e.queryContains = &normalized
+	return e
+}
+
+// Variables matches the requests having (at least) the variables with the same values, compared as json
+func (e *Expectation) Variables(variables map[string]interface{}) *Expectation {
+	jsonBytes, err := json.Marshal(variables)
+	if err != nil {
+		panic(fmt.Sprintf("fixturetest: variables not json encodable: %v", err))
+	}
+	e.variables = map[string]interface{}{}
+	json.Unmarshal(jsonBytes, &e.variables)
+	return e
+}
+
+// Times expects the request n times (default 1); 0 asserts it's never sent
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// AnyTimes accepts the request any number of times, including none
+func (e *Expectation) AnyTimes() *Expectation {
+	e.times = -1
+	return e
+}
+
+// Respond responds with the raw (json) body
+func (e *Expectation) Respond(body string) *Expectation {
+	e.status, e.body = http.StatusOK, []byte(body)
+	return e
+}
+
+// RespondData responds with `{ "data": data }`
+func (e *Expectation) RespondData(data interface{}) *Expectation {
+	body, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		panic(fmt.Sprintf("fixturetest: data not json encodable: %v", err))
+	}
+	e.status, e.body = http.StatusOK, body
+	return e
+}
+
+// RespondError responds with a graphql error, with the code in the extensions as Hasura does,
+// e.g., "constraint-violation"
+func (e *Expectation) RespondError(message string, code string) *Expectation {
+	body, _ := json.Marshal(map[string]interface{}{
+		"errors": []interface{}{map[string]interface{}{"message": message, "extensions": map[string]interface{}{"code": code}}},
+	})
+	e.status, e.body = http.StatusOK, body
+	return e
+}
+
+// RespondStatus responds with the http status code (and body), e.g., 503 for an unavailable server
+func (e *Expectation) RespondStatus(status int, body string) *Expectation {
+	e.status, e.body = status, []byte(body)
+	return e
+}
+
+// String describes the expectation, for the failure messages
+func (e *Expectation) String() string {
+	var criteria []string
+	if e.operationName != nil {
+		criteria = append(criteria, "operationName="+*e.operationName)
+	}
+	if e.rootField != nil {
+		criteria = append(criteria, "rootField="+*e.rootField)
+	}
+	if e.query != nil {
+		criteria = append(criteria, "query="+*e.query)
+	}
+	if e.queryContains != nil {
+		criteria = append(criteria, "queryContains="+*e.queryContains)
+	}
+	if e.variables != nil {
+		criteria = append(criteria, fmt.Sprintf("variables=%v", e.variables))
+	}
+	return fmt.Sprintf("#%d{%s}", e.idx, strings.Join(criteria, ", "))
+}
+
+func (e *Expectation) matches(req Request) bool {
+	query := normalizeSpace(req.Query)
+	if e.query != nil && *e.query != query {
+		return false
+	}
+	if e.queryContains != nil && !strings.Contains(query, *e.queryContains) {
+		return false
+	}
+	for name, value := range e.variables {
+		if actual, found := req.Variables[name]; !found || !reflect.DeepEqual(value, actual) {
+			return false
+		}
+	}
+	if e.operationName == nil && e.rootField == nil {
+		return true
+	}
+
+	operation := findOperation(req)
+	if operation == nil {
+		return false
+	}
+	if e.operationName != nil {
+		name := req.OperationName
+		if name == "" && operation.Name != nil {
+			name = operation.Name.Value
+		}
+		if name != *e.operationName {
+			return false
+		}
+	}
+	if e.rootField != nil {
+		found := false
+		for _, selection := range operation.SelectionSet.Selections {
+			if field, ok := selection.(*ast.Field); ok && field.Name.Value == *e.rootField {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// findOperation returns the operation to be executed: the one named by operationName, or else the first one
+func findOperation(req Request) *ast.OperationDefinition {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return nil
+	}
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if req.OperationName == "" || (operation.Name != nil && operation.Name.Value == req.OperationName) {
+			return operation
+		}
+	}
+	return nil
+}
+
+// normalizeSpace collapses what's insignificant between the graphql tokens (whitespace, commas, comments) into
+// single spaces, keeping the string literals as is. Text that can't be lexed (e.g., a substring cut in a string
+// literal) has its whitespace and commas collapsed instead.
+func normalizeSpace(s string) string {
+	lex := lexer.Lex(source.NewSource(&source.Source{Body: []byte(s)}))
+	var normalized strings.Builder
+	prevEnd := 0
+	for {
+		token, err := lex(0)
+		if err != nil {
+			return collapseSpace(s)
+		}
+		if token.Kind == lexer.EOF {
+			return normalized.String()
+		}
+		if normalized.Len() > 0 && token.Start > prevEnd {
+			normalized.WriteByte(' ')
+		}
+		normalized.WriteString(s[token.Start:token.End])
+		prevEnd = token.End
+	}
+}
+
+// collapseSpace collapses the whitespace and commas into single spaces
+func collapseSpace(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == ','
+	}), " ")
+}
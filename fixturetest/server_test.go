@@ -0,0 +1,247 @@
+package fixturetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gmm1900/graphqlfixture"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+// fakeT records the failures, to test that the Server reports them
+type fakeT struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+func (t *fakeT) Cleanup(f func()) { t.cleanups = append(t.cleanups, f) }
+func (t *fakeT) end() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func post(t *testing.T, url string, req Request) (int, string) {
+	reqBytes, err := json.Marshal(req)
+	assert.NoError(t, err)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(reqBytes))
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(respBytes)
+}
+
+func TestServerMatching(t *testing.T) {
+	s := NewServer(t)
+	s.Expect().OperationName("InsertAbc").RespondData(map[string]interface{}{"by": "operationName"})
+	s.Expect().RootField("delete_abc").RespondData(map[string]interface{}{"by": "rootField"})
+	s.Expect().Query(`query { abc { id } }`).Respond(`{ "data": { "by": "query" } }`)
+	s.Expect().QueryContains(`insert_xyz`).Variables(map[string]interface{}{"abc_id": 13}).
+		RespondError("boom", "constraint-violation")
+	s.Expect().QueryContains(`insert_xyz`).RespondStatus(http.StatusServiceUnavailable, "unavailable")
+
+	testCases := []struct {
+		name           string
+		req            Request
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "operation name in query",
+			req:            Request{Query: `mutation InsertAbc { insert_abc(objects: {}) { affected_rows } }`},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"data":{"by":"operationName"}}`,
+		},
+		{
+			name:           "root field",
+			req:            Request{Query: `mutation ($id: Int!) { delete_abc(where: { id: { _eq: $id } }) { affected_rows } }`},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"data":{"by":"rootField"}}`,
+		},
+		{
+			name:           "query ignoring whitespace",
+			req:            Request{Query: "query {\n\tabc {\n\t\tid\n\t}\n}"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{ "data": { "by": "query" } }`,
+		},
+		{
+			name:           "variables",
+			req:            Request{Query: `mutation ($abc_id: Int!) { insert_xyz(objects: { abc_id: $abc_id }) { affected_rows } }`, Variables: map[string]interface{}{"abc_id": 13}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"errors":[{"extensions":{"code":"constraint-violation"},"message":"boom"}]}`,
+		},
+		{
+			name:           "variables mismatched falls to the next expectation",
+			req:            Request{Query: `mutation ($abc_id: Int!) { insert_xyz(objects: { abc_id: $abc_id }) { affected_rows } }`, Variables: map[string]interface{}{"abc_id": 14}},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `unavailable`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := post(t, s.URL, tc.req)
+			assert.Equal(t, tc.expectedStatus, status)
+			assert.Equal(t, tc.expectedBody, body)
+		})
+	}
+	assert.Len(t, s.Requests(), len(testCases))
+}
+
+func TestServerVerify(t *testing.T) {
+	testCases := []struct {
+		name           string
+		given          func(s *Server)
+		requests       []Request
+		expectedErrors []string
+	}{
+		{
+			name: "all met",
+			given: func(s *Server) {
+				s.Expect().RootField("insert_abc").Times(2)
+				s.Expect().RootField("delete_abc").AnyTimes()
+			},
+			requests: []Request{
+				{Query: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+				{Query: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+			},
+		},
+		{
+			name: "called too few times, and unexpected request",
+			given: func(s *Server) {
+				s.Expect().RootField("insert_abc").Times(2)
+			},
+			requests: []Request{
+				{Query: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+				{Query: `mutation { insert_xyz(objects: {}) { affected_rows } }`},
+			},
+			expectedErrors: []string{
+				"fixturetest: unexpected request: mutation { insert_xyz(objects: {}) { affected_rows } } (variables: map[])",
+				"fixturetest: expectation #0{rootField=insert_abc}: called 1 time(s), expected 2",
+			},
+		},
+		{
+			name: "out of order",
+			given: func(s *Server) {
+				s.InOrder()
+				s.Expect().RootField("insert_abc")
+				s.Expect().RootField("delete_abc")
+			},
+			requests: []Request{
+				{Query: `mutation { delete_abc(where: {}) { affected_rows } }`},
+				{Query: `mutation { insert_abc(objects: {}) { affected_rows } }`},
+			},
+			expectedErrors: []string{
+				"fixturetest: expectation #0{rootField=insert_abc} matched out of order: mutation { insert_abc(objects: {}) { affected_rows } }",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := &fakeT{}
+			s := NewServer(ft)
+			tc.given(s)
+			for _, req := range tc.requests {
+				post(t, s.URL, req)
+			}
+			ft.end()
+			assert.Equal(t, tc.expectedErrors, ft.errors)
+		})
+	}
+}
+
+func TestNormalizeSpace(t *testing.T) {
+	testCases := []struct {
+		name     string
+		given    string
+		expected string
+	}{
+		{
+			name:     "whitespace, commas and comments between tokens",
+			given:    "query {\n\tabc(where: { id: { _in: [1,2] } }) # the abc\n\t{ id, name }\n}",
+			expected: `query { abc(where: { id: { _in: [1 2] } }) { id name } }`,
+		},
+		{
+			name:     "string literals kept as is",
+			given:    `mutation { insert_abc(objects: { name: "a,  b" }) { affected_rows } }`,
+			expected: `mutation { insert_abc(objects: { name: "a,  b" }) { affected_rows } }`,
+		},
+		{
+			name:     "cut in a string literal",
+			given:    `name: "a,  b`,
+			expected: `name: "a b`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, normalizeSpace(tc.given))
+		})
+	}
+
+	// different literals don't match
+	s := NewServer(t)
+	s.Expect().QueryContains(`name: "a b"`).Times(0)
+	s.Expect().QueryContains(`name: "a, b"`)
+	status, _ := post(t, s.URL, Request{Query: `mutation { insert_abc(objects: { name: "a, b" }) { affected_rows } }`})
+	assert.Equal(t, http.StatusOK, status)
+}
+
+// countingTransport counts the http requests sent
+type countingTransport struct {
+	count int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.count++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestServerBatch(t *testing.T) {
+	t.Run("answered with an array", func(t *testing.T) {
+		s := NewServer(t).InOrder()
+		s.Expect().RootField("abc").RespondData(map[string]interface{}{"abc": []interface{}{map[string]interface{}{"id": 13}}})
+		s.Expect().RootField("xyz").RespondData(map[string]interface{}{"xyz": []interface{}{map[string]interface{}{"id": 21}}})
+		transport := &countingTransport{}
+		fixtures := graphqlfixture.Fixtures{
+			Fixtures: []graphqlfixture.Fixture{
+				{Setup: `query { abc { id } }`, Captors: map[string]string{"abc_id": "/data/abc/0/id"}},
+				{Setup: `query { xyz { id } }`, Captors: map[string]string{"xyz_id": "/data/xyz/0/id"}},
+			},
+			BatchSetup: true,
+		}
+
+		assert.NoError(t, fixtures.Setup(context.Background(), graphqlfixture.NewBatchClient(s.URL, &http.Client{Transport: transport}, nil)))
+		assert.Equal(t, 1, transport.count)
+		assert.Len(t, s.Requests(), 2)
+		assert.Equal(t, []string{
+			"fixture[0].setup: completed",
+			"fixture[0].captors: completed with 1 capture(s)",
+			"fixture[1].setup: completed",
+			"fixture[1].captors: completed with 1 capture(s)",
+		}, fixtures.Logs())
+	})
+
+	t.Run("an item's http status answers the whole batch", func(t *testing.T) {
+		s := NewServer(t)
+		s.Expect().RootField("abc")
+		s.Expect().RootField("xyz").RespondStatus(http.StatusServiceUnavailable, "unavailable")
+
+		resp, err := http.Post(s.URL, "application/json", bytes.NewReader([]byte(`[ { "query": "query { abc { id } }" }, { "query": "query { xyz { id } }" } ]`)))
+		if assert.NoError(t, err) {
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+			assert.Equal(t, "unavailable", string(body))
+		}
+	})
+}
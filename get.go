@@ -35,7 +35,7 @@ func (fs *Fixtures) GetAndParse(captorName string, value interface{}) error {
 	return nil
 }
 
-// Logs return logs
+// Logs return logs: the human readable view of Events()
 func (fs *Fixtures) Logs() []string{
 	return fs.logs
 }
@@ -45,7 +45,9 @@ func (fs *Fixtures) SetupUntil() *int{
 	return fs.setupUntilIdx
 }
 
-// TeardownUntil returns teardownUntilIdx (can be nil)
+// TeardownUntil returns teardownUntilIdx (can be nil): the last fixture torn down, in the order of Teardown.
+// With OrderTeardownByForeignKeys, that's not the reverse sequence: the fixtures torn down are those up to this one in
+// the foreign key order, not all of those from the end down to it (see Remediation for the ones left).
 func (fs *Fixtures) TeardownUntil() *int{
 	return fs.teardownUntilIdx
 }
\ No newline at end of file
@@ -0,0 +1,215 @@
+package graphqlfixture
+
+import (
+	"fmt"
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	gqlast "github.com/graphql-go/graphql/language/ast"
+	"github.com/hashicorp/go-multierror"
+	"sort"
+	"strings"
+)
+
+// LintHasura checks the fixtures against the tables of a Hasura project (see hasurameta.Load), i.e., offline with
+// only the migrations and metadata in the repo:
+//   - the tables referenced by the root fields (insert_X, delete_X, X_by_pk ..) are tracked
+//   - the inserted columns exist (or are relationships)
+//   - the NOT NULL columns without default are supplied; a column filled by the relationship of a nested insert counts
+//   - the relationships used in nested inserts are declared
+//   - every inserted table is deleted by some teardown, directly or by ON DELETE CASCADE from a deleted table
+//
+// Objects passed as variables can't be checked. All the problems found are returned (no early exit).
+func (fs *Fixtures) LintHasura(meta *hasurameta.Schema) error {
+	if !fs.parsed {
+		fs.Parse()
+	}
+	if fs.parseErr != nil {
+		return fmt.Errorf("parse error: %w", fs.parseErr)
+	}
+
+	linter := &hasuraLinter{meta: meta, inserted: map[string]string{}, deleted: map[string]bool{}}
+	for fIdx, f := range fs.Fixtures {
+		setupDoc, err := f.setupDoc()
+		linter.lint(fmt.Sprintf("fixture[%d].setup", fIdx), setupDoc, err, false)
+		if f.Teardown != nil {
+			teardownDoc, err := f.teardownDoc()
+			linter.lint(fmt.Sprintf("fixture[%d].teardown", fIdx), teardownDoc, err, true)
+		}
+	}
+	linter.lintTeardownCoverage()
+	return linter.multierr.ErrorOrNil()
+}
+
+// hasuraLinter collects the problems of the fixtures' graphql, and which tables are inserted and deleted
+type hasuraLinter struct {
+	meta     *hasurameta.Schema
+	inserted map[string]string // the tables inserted by setups, to where first inserted, e.g., "fixture[0].setup"
+	deleted  map[string]bool   // the tables deleted by teardowns
+	multierr *multierror.Error
+}
+
+func (l *hasuraLinter) errorf(where string, format string, args ...interface{}) {
+	l.multierr = multierror.Append(l.multierr, fmt.Errorf("%s: %s", where, fmt.Sprintf(format, args...)))
+}
+
+// lint checks the root fields of all the operations in the doc (as parsed, with err)
+func (l *hasuraLinter) lint(where string, doc *gqlast.Document, err error, isTeardown bool) {
+	if err != nil { // shouldn't happen, since the fixtures have passed parsing
+		l.errorf(where, "is invalid. %v", err)
+		return
+	}
+	fragments := fragmentsOf(doc)
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*gqlast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		for _, field := range flattenFields(operation.SelectionSet, fragments) {
+			l.rootField(where, operation.Operation, field, isTeardown)
+		}
+	}
+}
+
+// rootField checks the table referenced by the root field, and the inserted objects if it's an insert
+func (l *hasuraLinter) rootField(where string, operation string, field *gqlast.Field, isTeardown bool) {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return
+	}
+	where = fmt.Sprintf("%s: %s", where, name)
+	kind, table := l.rootFieldTable(where, operation, name)
+	if table == nil {
+		return // reported by rootFieldTable
+	}
+	switch kind {
+	case "insert", "insert_one":
+		argName := "objects"
+		if kind == "insert_one" {
+			argName = "object"
+		}
+		for _, arg := range field.Arguments {
+			if arg.Name.Value == argName {
+				l.insertObjects(where, table, arg.Value, nil)
+			}
+		}
+	case "delete":
+		if isTeardown {
+			l.deleted[table.GraphqlName()] = true
+		}
+	}
+}
+
+// rootFieldTable returns the kind of the root field ("insert", "insert_one", "delete", "update", "select") and the
+// table it refers to; reports the table not tracked if none
+func (l *hasuraLinter) rootFieldTable(where string, operation string, name string) (string, *hasurameta.Table) {
+	type candidate struct {
+		kind      string
+		prefix    string
+		suffix    string
+		operation string // empty for any
+	}
+	candidates := []candidate{
+		{kind: "insert_one", prefix: "insert_", suffix: "_one", operation: "mutation"},
+		{kind: "insert", prefix: "insert_", operation: "mutation"},
+		{kind: "delete", prefix: "delete_", suffix: "_by_pk", operation: "mutation"},
+		{kind: "delete", prefix: "delete_", operation: "mutation"},
+		{kind: "update", prefix: "update_", suffix: "_by_pk", operation: "mutation"},
+		{kind: "update", prefix: "update_", operation: "mutation"},
+		{kind: "select", suffix: "_by_pk"},
+		{kind: "select", suffix: "_aggregate"},
+		{kind: "select"},
+	}
+	var tableName string // of the first candidate matching the name, to be reported if not tracked
+	for _, c := range candidates {
+		if (c.operation != "" && c.operation != operation) || !strings.HasPrefix(name, c.prefix) || !strings.HasSuffix(name, c.suffix) {
+			continue
+		}
+		candidateName := strings.TrimSuffix(strings.TrimPrefix(name, c.prefix), c.suffix)
+		if table, found := l.meta.Tables[candidateName]; found {
+			return c.kind, table
+		}
+		if tableName == "" {
+			tableName = candidateName
+		}
+	}
+	l.errorf(where, "table %s is not tracked", tableName)
+	return "", nil
+}
+
+// insertObjects checks the inserted object(s): a list, a single object (as a list of one), or a variable (unchecked).
+// filled are the columns filled by the parent of a nested insert.
+func (l *hasuraLinter) insertObjects(where string, table *hasurameta.Table, value gqlast.Value, filled []string) {
+	switch v := value.(type) {
+	case *gqlast.ListValue:
+		for _, elem := range v.Values {
+			l.insertObjects(where, table, elem, filled)
+		}
+	case *gqlast.ObjectValue:
+		l.insertObject(where, table, v, filled)
+	}
+}
+
+// insertObject checks the columns and the nested inserts of an inserted object
+func (l *hasuraLinter) insertObject(where string, table *hasurameta.Table, obj *gqlast.ObjectValue, filled []string) {
+	if _, found := l.inserted[table.GraphqlName()]; !found {
+		l.inserted[table.GraphqlName()] = strings.SplitN(where, ":", 2)[0]
+	}
+	supplied := map[string]bool{}
+	for _, column := range filled {
+		supplied[column] = true
+	}
+	for _, field := range obj.Fields {
+		name := field.Name.Value
+		if table.Column(name) != nil {
+			supplied[name] = true
+			continue
+		}
+		rel, isArray := table.Relationship(name)
+		if rel == nil {
+			l.errorf(where, "column or relationship %s not found in table %s", name, table.GraphqlName())
+			continue
+		}
+		remoteTable := l.meta.Table(rel.RemoteSchema, rel.RemoteTable)
+		if remoteTable == nil {
+			l.errorf(where, "relationship %s.%s: table %s is not tracked", table.GraphqlName(), name, rel.RemoteTable)
+			continue
+		}
+		nestedInsert, ok := field.Value.(*gqlast.ObjectValue)
+		if !ok {
+			continue // e.g., a variable
+		}
+		for _, nestedField := range nestedInsert.Fields {
+			if nestedField.Name.Value != "data" {
+				continue // e.g., on_conflict
+			}
+			nestedWhere := fmt.Sprintf("%s.%s", where, name)
+			if isArray { // the remote rows refer to this one
+				l.insertObjects(nestedWhere, remoteTable, nestedField.Value, rel.RemoteColumns)
+			} else { // this row refers to the remote one
+				l.insertObjects(nestedWhere, remoteTable, nestedField.Value, nil)
+				for _, column := range rel.Columns {
+					supplied[column] = true
+				}
+			}
+		}
+	}
+
+	for _, column := range table.Columns {
+		if column.NotNull && column.Default == "" && !strings.HasSuffix(column.Type, "serial") && !supplied[column.Name] {
+			l.errorf(where, "column %s.%s is NOT NULL without default, but not supplied", table.GraphqlName(), column.Name)
+		}
+	}
+}
+
+// lintTeardownCoverage reports the inserted tables not deleted by any teardown, directly or by ON DELETE CASCADE
+func (l *hasuraLinter) lintTeardownCoverage() {
+	var tables []string
+	for name := range l.inserted {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	for _, name := range tables {
+		if !l.deleted[name] && cascadeSource(l.meta, name, l.deleted) == "" {
+			l.errorf(l.inserted[name], "table %s is inserted but not deleted by any teardown", name)
+		}
+	}
+}
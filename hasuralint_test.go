@@ -0,0 +1,117 @@
+package graphqlfixture
+
+import (
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLintHasura(t *testing.T) {
+	testCases := []struct {
+		name           string
+		givenFixtures  []Fixture
+		expectedErrMsg []string
+	}{
+		{
+			name: "no problem: nested inserts fill the foreign keys, teaches deleted by cascade",
+			givenFixtures: []Fixture{
+				{
+					Setup: `mutation {
+						insert_instructors_one(object: { name: "Murphy", teaches: { data: [{ subject: { data: { name: "CS101" } } }] } }) {
+							id teaches { subject_id }
+						}
+					}`,
+					Captors: map[string]string{
+						"murphy_id": "/data/insert_instructors_one/id",
+						"cs101_id":  "/data/insert_instructors_one/teaches/0/subject_id",
+					},
+					Teardown: gopointer.OfString(`mutation ($murphy_id: Int!, $cs101_id: Int!) {
+						delete_instructors_by_pk(id: $murphy_id) { id }
+						delete_subjects(where: { id: { _eq: $cs101_id } }) { affected_rows }
+					}`),
+				},
+				{
+					Setup: `query { subjects { ...subject } } fragment subject on subjects { id }`,
+				},
+			},
+		},
+		{
+			name: "untracked table, unknown column and relationship",
+			givenFixtures: []Fixture{
+				{
+					Setup:    `mutation { insert_instuctors(objects: [{ name: "Murphy" }]) { affected_rows } }`,
+					Teardown: gopointer.OfString(`mutation { delete_instuctors(where: {}) { affected_rows } }`),
+				},
+				{
+					Setup:    `mutation { insert_subjects(objects: [{ name: "CS101", title: "intro", taught: { data: [] } }]) { affected_rows } }`,
+					Teardown: gopointer.OfString(`mutation { delete_subjects(where: {}) { affected_rows } }`),
+				},
+			},
+			expectedErrMsg: []string{
+				"fixture[0].setup: insert_instuctors: table instuctors is not tracked",
+				"fixture[0].teardown: delete_instuctors: table instuctors is not tracked",
+				"fixture[1].setup: insert_subjects: column or relationship title not found in table subjects",
+				"fixture[1].setup: insert_subjects: column or relationship taught not found in table subjects",
+			},
+		},
+		{
+			name: "not null column not supplied in a nested insert, objects in variable not checked",
+			givenFixtures: []Fixture{
+				{
+					Setup:    `mutation { insert_teaches(objects: { instructor_id: 1, subject: { data: {} } }) { returning { instructor_id subject_id } } }`,
+					Captors:  map[string]string{"teaches": "/data/insert_teaches/returning"},
+					Teardown: gopointer.OfString(`mutation { delete_teaches(where: {}) { affected_rows } delete_subjects(where: {}) { affected_rows } }`),
+				},
+				{
+					Setup: `mutation ($teaches: [teaches_insert_input!]!) { insert_teaches(objects: $teaches) { affected_rows } }`, // unchecked
+				},
+			},
+			expectedErrMsg: []string{
+				"fixture[0].setup: insert_teaches.subject: column subjects.name is NOT NULL without default, but not supplied",
+			},
+		},
+		{
+			name: "inserted but not deleted",
+			givenFixtures: []Fixture{
+				{
+					Setup:    `mutation { insert_subjects_one(object: { name: "CS101" }) { id } }`,
+					Captors:  map[string]string{"cs101_id": "/data/insert_subjects_one/id"},
+					Teardown: gopointer.OfString(`mutation { delete_teaches(where: {}) { affected_rows } }`),
+				},
+				{
+					Setup: `mutation ($cs101_id: Int!) { insert_studies(objects: { subject_id: $cs101_id, student: { data: { name: "Alice" } } }) { affected_rows } }`,
+				},
+			},
+			expectedErrMsg: []string{
+				"fixture[1].setup: table students is inserted but not deleted by any teardown",
+				"fixture[1].setup: table studies is inserted but not deleted by any teardown",
+				"fixture[0].setup: table subjects is inserted but not deleted by any teardown",
+			},
+		},
+	}
+
+	meta, err := hasurameta.Load("example/hasura")
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixtures := Fixtures{Fixtures: tc.givenFixtures}
+			err := fixtures.LintHasura(meta)
+			if len(tc.expectedErrMsg) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var multierr *multierror.Error
+			if assert.ErrorAs(t, err, &multierr) {
+				var errMsgs []string
+				for _, err := range multierr.Errors {
+					errMsgs = append(errMsgs, err.Error())
+				}
+				assert.Equal(t, tc.expectedErrMsg, errMsgs)
+			}
+		})
+	}
+}
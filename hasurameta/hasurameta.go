@@ -0,0 +1,135 @@
+// Package hasurameta loads the database schema of a Hasura project: the tables, columns and constraints from the
+// `CREATE TABLE` / `ALTER TABLE` statements of the migrations, and the relationships from the metadata.
+// It's not a SQL parser: the statements it doesn't understand (functions, triggers, comments ..) are skipped.
+package hasurameta
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Schema is the tables of a Hasura project, keyed by the graphql name, e.g., "subjects" (for "public"."subjects")
+type Schema struct {
+	Tables map[string]*Table
+}
+
+// Table is a database table with its Hasura relationships
+type Table struct {
+	Schema              string // the database schema, e.g., "public"
+	Name                string // the table name
+	CustomName          string // the graphql name configured in the metadata, if any
+	Columns             []*Column
+	PrimaryKey          []string // column names
+	Uniques             []*Unique
+	ForeignKeys         []*ForeignKey
+	ObjectRelationships []*Relationship
+	ArrayRelationships  []*Relationship
+}
+
+// Column is a table column
+type Column struct {
+	Name    string
+	Type    string // as declared, lowercased, e.g., "serial", "text", "timestamptz"
+	NotNull bool
+	Default string // the default expression, e.g., "now()", or "" if none
+}
+
+// Unique is a UNIQUE constraint
+type Unique struct {
+	Name    string
+	Columns []string
+}
+
+// ForeignKey is a FOREIGN KEY constraint: Columns of the table reference RefColumns of RefTable
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefSchema  string
+	RefTable   string // the table name (not the graphql name)
+	RefColumns []string
+	OnDelete   string // lowercased, e.g., "cascade", "restrict", "set null"; "no action" if not given
+}
+
+// Relationship is a Hasura object or array relationship: the rows of the remote table whose RemoteColumns
+// equal the Columns of this table's row
+type Relationship struct {
+	Name          string
+	RemoteSchema  string
+	RemoteTable   string // the table name (not the graphql name)
+	Columns       []string
+	RemoteColumns []string
+}
+
+// GraphqlName returns the name of the table in the graphql schema, e.g., "subjects" and "insert_subjects"
+func (t *Table) GraphqlName() string {
+	if t.CustomName != "" {
+		return t.CustomName
+	}
+	return graphqlName(t.Schema, t.Name)
+}
+
+// Column returns the column by name, or nil if not found
+func (t *Table) Column(name string) *Column {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Relationship returns the object or array relationship by name, and whether it's an array relationship
+func (t *Table) Relationship(name string) (rel *Relationship, isArray bool) {
+	for _, r := range t.ObjectRelationships {
+		if r.Name == name {
+			return r, false
+		}
+	}
+	for _, r := range t.ArrayRelationships {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Table returns the table by the database schema and name (not the graphql name), or nil if not found
+func (s *Schema) Table(schema string, name string) *Table {
+	for _, t := range s.Tables {
+		if t.Schema == schema && t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// SortedTables returns the tables sorted by graphql name, for a stable iteration order
+func (s *Schema) SortedTables() []*Table {
+	var tables []*Table
+	for _, t := range s.Tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].GraphqlName() < tables[j].GraphqlName() })
+	return tables
+}
+
+// Load reads the Hasura project in dir, i.e., `migrations/` and `metadata/` (as in the `hasura` CLI project layout)
+func Load(dir string) (*Schema, error) {
+	schema, err := LoadMigrations(filepath.Join(dir, "migrations"))
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.LoadMetadata(filepath.Join(dir, "metadata")); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// graphqlName is Hasura's default graphql name of a table: the name for "public" tables, else prefixed by the schema
+func graphqlName(schema string, name string) string {
+	if schema == "" || schema == "public" {
+		return name
+	}
+	return fmt.Sprintf("%s_%s", schema, name)
+}
@@ -0,0 +1,140 @@
+package hasurameta
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApplySQL(t *testing.T) {
+	testCases := []struct {
+		name          string
+		sql           string
+		expectedTable *Table
+	}{
+		{
+			name: "create table with table constraints, as generated by the hasura console",
+			sql: `CREATE TABLE "public"."teaches"("id" serial NOT NULL, "created_at" timestamptz NOT NULL DEFAULT now(), "instructor_id" integer NOT NULL, PRIMARY KEY ("id") , FOREIGN KEY ("instructor_id") REFERENCES "public"."instructors"("id") ON UPDATE cascade ON DELETE cascade, UNIQUE ("instructor_id"));
+				CREATE OR REPLACE FUNCTION "public"."set_current_timestamp_updated_at"()
+				RETURNS TRIGGER AS $$
+				BEGIN
+				  NEW."updated_at" = NOW(); -- a ; in the body
+				  RETURN NEW;
+				END;
+				$$ LANGUAGE plpgsql;`,
+			expectedTable: &Table{
+				Schema: "public",
+				Name:   "teaches",
+				Columns: []*Column{
+					{Name: "id", Type: "serial", NotNull: true},
+					{Name: "created_at", Type: "timestamptz", NotNull: true, Default: "now()"},
+					{Name: "instructor_id", Type: "integer", NotNull: true},
+				},
+				PrimaryKey: []string{"id"},
+				Uniques:    []*Unique{{Name: "teaches_instructor_id_key", Columns: []string{"instructor_id"}}},
+				ForeignKeys: []*ForeignKey{{
+					Name: "teaches_instructor_id_fkey", Columns: []string{"instructor_id"},
+					RefSchema: "public", RefTable: "instructors", RefColumns: []string{"id"}, OnDelete: "cascade",
+				}},
+			},
+		},
+		{
+			name: "inline constraints and alter table",
+			sql: `create table teaches (
+					id serial primary key,
+					name text unique,
+					instructor_id integer not null references instructors on delete restrict
+				);
+				alter table teaches add column code text default 'x' not null;
+				alter table teaches alter column name set not null;
+				alter table teaches drop constraint teaches_name_key;
+				ALTER TABLE "public"."teaches" ADD CONSTRAINT "teaches_code_fkey" FOREIGN KEY ("code") REFERENCES "public"."codes"("code") ON DELETE set null;`,
+			expectedTable: &Table{
+				Schema: "public",
+				Name:   "teaches",
+				Columns: []*Column{
+					{Name: "id", Type: "serial", NotNull: true},
+					{Name: "name", Type: "text", NotNull: true},
+					{Name: "instructor_id", Type: "integer", NotNull: true},
+					{Name: "code", Type: "text", NotNull: true, Default: "'x'"},
+				},
+				PrimaryKey: []string{"id"},
+				ForeignKeys: []*ForeignKey{
+					{
+						Name: "teaches_instructor_id_fkey", Columns: []string{"instructor_id"},
+						RefSchema: "public", RefTable: "instructors", RefColumns: []string{"id"}, OnDelete: "restrict",
+					},
+					{
+						Name: "teaches_code_fkey", Columns: []string{"code"},
+						RefSchema: "public", RefTable: "codes", RefColumns: []string{"code"}, OnDelete: "set null",
+					},
+				},
+			},
+		},
+		{
+			name: "references without columns, to the primary key",
+			sql: `create table codes (code text primary key);
+				create table teaches (
+					id serial,
+					code text references codes,
+					parent_id integer references teaches,
+					primary key (id)
+				);`,
+			expectedTable: &Table{
+				Schema: "public",
+				Name:   "teaches",
+				Columns: []*Column{
+					{Name: "id", Type: "serial"},
+					{Name: "code", Type: "text"},
+					{Name: "parent_id", Type: "integer"},
+				},
+				PrimaryKey: []string{"id"},
+				ForeignKeys: []*ForeignKey{
+					{
+						Name: "teaches_code_fkey", Columns: []string{"code"},
+						RefSchema: "public", RefTable: "codes", RefColumns: []string{"code"}, OnDelete: "no action",
+					},
+					{
+						Name: "teaches_parent_id_fkey", Columns: []string{"parent_id"},
+						RefSchema: "public", RefTable: "teaches", RefColumns: []string{"id"}, OnDelete: "no action",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schema := &Schema{Tables: map[string]*Table{}}
+			assert.NoError(t, schema.ApplySQL(tc.sql))
+			if diff := cmp.Diff(tc.expectedTable, schema.Tables["teaches"]); diff != "" {
+				t.Errorf("table mismatched %v", diff)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	schema, err := Load("../example/hasura")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var names []string
+	for _, table := range schema.SortedTables() {
+		names = append(names, table.GraphqlName())
+	}
+	assert.Equal(t, []string{"instructors", "students", "studies", "subjects", "teaches"}, names)
+
+	rel, isArray := schema.Tables["subjects"].Relationship("taught_by")
+	assert.True(t, isArray)
+	assert.Equal(t, &Relationship{
+		Name: "taught_by", RemoteSchema: "public", RemoteTable: "teaches", Columns: []string{"id"}, RemoteColumns: []string{"subject_id"},
+	}, rel)
+
+	rel, isArray = schema.Tables["teaches"].Relationship("instructor")
+	assert.False(t, isArray)
+	assert.Equal(t, &Relationship{
+		Name: "instructor", RemoteSchema: "public", RemoteTable: "instructors", Columns: []string{"instructor_id"}, RemoteColumns: []string{"id"},
+	}, rel)
+}
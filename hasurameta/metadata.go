@@ -0,0 +1,221 @@
+package hasurameta
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tableMetadata is a table entry of the metadata (tables.yaml)
+type tableMetadata struct {
+	Table struct {
+		Schema string `yaml:"schema"`
+		Name   string `yaml:"name"`
+	} `yaml:"table"`
+	Configuration struct {
+		CustomName string `yaml:"custom_name"`
+	} `yaml:"configuration"`
+	ObjectRelationships []relationshipMetadata `yaml:"object_relationships"`
+	ArrayRelationships  []relationshipMetadata `yaml:"array_relationships"`
+}
+
+type relationshipMetadata struct {
+	Name  string `yaml:"name"`
+	Using struct {
+		// a column name (object relationship), or {column, table} (array relationship)
+		ForeignKeyConstraintOn yaml.Node `yaml:"foreign_key_constraint_on"`
+		ManualConfiguration    *struct {
+			RemoteTable   qualifiedTable    `yaml:"remote_table"`
+			ColumnMapping map[string]string `yaml:"column_mapping"`
+		} `yaml:"manual_configuration"`
+	} `yaml:"using"`
+}
+
+// qualifiedTable is either a table name, or {schema, name}
+type qualifiedTable struct {
+	Schema string
+	Name   string
+}
+
+func (q *qualifiedTable) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		q.Schema, q.Name = "public", node.Value
+		return nil
+	}
+	var table struct {
+		Schema string `yaml:"schema"`
+		Name   string `yaml:"name"`
+	}
+	if err := node.Decode(&table); err != nil {
+		return err
+	}
+	q.Schema, q.Name = defaultSchema(table.Schema), table.Name
+	return nil
+}
+
+// LoadMetadata reads the relationships (and custom names) of the tables from the metadata in dir. Both layouts are
+// supported: `tables.yaml` (config v2) and `databases/<db>/tables/tables.yaml` with `!include` (config v3).
+func (s *Schema) LoadMetadata(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "databases", "*", "tables", "tables.yaml"))
+	if err != nil {
+		return err
+	}
+	if v2File := filepath.Join(dir, "tables.yaml"); fileExists(v2File) {
+		files = append(files, v2File)
+	}
+	for _, file := range files {
+		tables, err := readTablesMetadata(file)
+		if err != nil {
+			return err
+		}
+		for _, tm := range tables {
+			if err := s.applyTableMetadata(tm); err != nil {
+				return fmt.Errorf("metadata %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readTablesMetadata reads the table entries of the file, following the `!include`s
+func readTablesMetadata(file string) ([]tableMetadata, error) {
+	yamlBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read metadata: %w", err)
+	}
+	var nodes []yaml.Node
+	if err := yaml.Unmarshal(yamlBytes, &nodes); err != nil {
+		return nil, fmt.Errorf("fail to parse metadata %s: %w", file, err)
+	}
+	var tables []tableMetadata
+	for _, node := range nodes {
+		if node.Kind == yaml.ScalarNode && strings.HasPrefix(node.Value, "!include ") {
+			includedFile := filepath.Join(filepath.Dir(file), strings.TrimSpace(strings.TrimPrefix(node.Value, "!include ")))
+			includedBytes, err := ioutil.ReadFile(includedFile)
+			if err != nil {
+				return nil, fmt.Errorf("fail to read metadata: %w", err)
+			}
+			var tm tableMetadata
+			if err := yaml.Unmarshal(includedBytes, &tm); err != nil {
+				return nil, fmt.Errorf("fail to parse metadata %s: %w", includedFile, err)
+			}
+			tables = append(tables, tm)
+			continue
+		}
+		var tm tableMetadata
+		if err := node.Decode(&tm); err != nil {
+			return nil, fmt.Errorf("fail to parse metadata %s: %w", file, err)
+		}
+		tables = append(tables, tm)
+	}
+	return tables, nil
+}
+
+func (s *Schema) applyTableMetadata(tm tableMetadata) error {
+	t := s.Table(defaultSchema(tm.Table.Schema), tm.Table.Name)
+	if t == nil {
+		return fmt.Errorf("table %s not found in migrations", tm.Table.Name)
+	}
+	if tm.Configuration.CustomName != "" {
+		delete(s.Tables, t.GraphqlName())
+		t.CustomName = tm.Configuration.CustomName
+		s.Tables[t.GraphqlName()] = t
+	}
+
+	for _, rm := range tm.ObjectRelationships {
+		rel, err := s.objectRelationship(t, rm)
+		if err != nil {
+			return fmt.Errorf("table %s: object relationship %s: %w", t.Name, rm.Name, err)
+		}
+		t.ObjectRelationships = append(t.ObjectRelationships, rel)
+	}
+	for _, rm := range tm.ArrayRelationships {
+		rel, err := s.arrayRelationship(t, rm)
+		if err != nil {
+			return fmt.Errorf("table %s: array relationship %s: %w", t.Name, rm.Name, err)
+		}
+		t.ArrayRelationships = append(t.ArrayRelationships, rel)
+	}
+	return nil
+}
+
+// objectRelationship resolves the relationship using the foreign key of the table (or the manual configuration)
+func (s *Schema) objectRelationship(t *Table, rm relationshipMetadata) (*Relationship, error) {
+	if manual := rm.Using.ManualConfiguration; manual != nil {
+		return manualRelationship(rm.Name, manual.RemoteTable, manual.ColumnMapping), nil
+	}
+	var column string
+	if err := rm.Using.ForeignKeyConstraintOn.Decode(&column); err != nil {
+		return nil, fmt.Errorf("foreign_key_constraint_on is not a column: %w", err)
+	}
+	for _, fk := range t.ForeignKeys {
+		if len(fk.Columns) == 1 && fk.Columns[0] == column {
+			return &Relationship{
+				Name:          rm.Name,
+				RemoteSchema:  fk.RefSchema,
+				RemoteTable:   fk.RefTable,
+				Columns:       fk.Columns,
+				RemoteColumns: fk.RefColumns,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no foreign key on column %s", column)
+}
+
+// arrayRelationship resolves the relationship using the foreign key of the remote table (or the manual configuration)
+func (s *Schema) arrayRelationship(t *Table, rm relationshipMetadata) (*Relationship, error) {
+	if manual := rm.Using.ManualConfiguration; manual != nil {
+		return manualRelationship(rm.Name, manual.RemoteTable, manual.ColumnMapping), nil
+	}
+	var using struct {
+		Column string         `yaml:"column"`
+		Table  qualifiedTable `yaml:"table"`
+	}
+	if err := rm.Using.ForeignKeyConstraintOn.Decode(&using); err != nil {
+		return nil, fmt.Errorf("foreign_key_constraint_on is not {column, table}: %w", err)
+	}
+	remote := s.Table(using.Table.Schema, using.Table.Name)
+	if remote == nil {
+		return nil, fmt.Errorf("table %s not found in migrations", using.Table.Name)
+	}
+	for _, fk := range remote.ForeignKeys {
+		if len(fk.Columns) == 1 && fk.Columns[0] == using.Column && fk.RefSchema == t.Schema && fk.RefTable == t.Name {
+			return &Relationship{
+				Name:          rm.Name,
+				RemoteSchema:  remote.Schema,
+				RemoteTable:   remote.Name,
+				Columns:       fk.RefColumns,
+				RemoteColumns: fk.Columns,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no foreign key on %s.%s referencing %s", remote.Name, using.Column, t.Name)
+}
+
+func manualRelationship(name string, remoteTable qualifiedTable, columnMapping map[string]string) *Relationship {
+	rel := &Relationship{Name: name, RemoteSchema: remoteTable.Schema, RemoteTable: remoteTable.Name}
+	for column := range columnMapping {
+		rel.Columns = append(rel.Columns, column)
+	}
+	sort.Strings(rel.Columns)
+	for _, column := range rel.Columns {
+		rel.RemoteColumns = append(rel.RemoteColumns, columnMapping[column])
+	}
+	return rel
+}
+
+func defaultSchema(schema string) string {
+	if schema == "" {
+		return "public"
+	}
+	return schema
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
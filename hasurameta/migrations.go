@@ -0,0 +1,391 @@
+package hasurameta
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LoadMigrations applies the `up.sql` of the migrations in dir, in version order. Both layouts are supported:
+// `<version>_<name>/up.sql` and `<version>_<name>.up.sql`.
+func LoadMigrations(dir string) (*Schema, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && (info.Name() == "up.sql" || strings.HasSuffix(info.Name(), ".up.sql")) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to read migrations: %w", err)
+	}
+	sort.Strings(files) // the versions are timestamps
+
+	schema := &Schema{Tables: map[string]*Table{}}
+	for _, file := range files {
+		sqlBytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read migration: %w", err)
+		}
+		if err := schema.ApplySQL(string(sqlBytes)); err != nil {
+			return nil, fmt.Errorf("migration %s: %w", file, err)
+		}
+	}
+	return schema, nil
+}
+
+var (
+	createTableRegexp = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?((?:"[^"]+"|\w+)(?:\.(?:"[^"]+"|\w+))?)\s*\((.*)\)$`)
+	alterTableRegexp  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?(?:IF\s+EXISTS\s+)?((?:"[^"]+"|\w+)(?:\.(?:"[^"]+"|\w+))?)\s+(.*)$`)
+	dropTableRegexp   = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?((?:"[^"]+"|\w+)(?:\.(?:"[^"]+"|\w+))?)`)
+	constraintRegexp  = regexp.MustCompile(`(?is)^CONSTRAINT\s+("[^"]+"|\w+)\s+(.*)$`)
+	primaryKeyRegexp  = regexp.MustCompile(`(?is)^PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	uniqueRegexp      = regexp.MustCompile(`(?is)^UNIQUE\s*\(([^)]*)\)`)
+	foreignKeyRegexp  = regexp.MustCompile(`(?is)^FOREIGN\s+KEY\s*\(([^)]*)\)\s*(REFERENCES\s+.*)$`)
+	referencesRegexp  = regexp.MustCompile(`(?is)^REFERENCES\s+((?:"[^"]+"|\w+)(?:\.(?:"[^"]+"|\w+))?)\s*(?:\(([^)]*)\))?(.*)$`)
+	onDeleteRegexp    = regexp.MustCompile(`(?is)ON\s+DELETE\s+(CASCADE|RESTRICT|NO\s+ACTION|SET\s+NULL|SET\s+DEFAULT)`)
+	columnRegexp      = regexp.MustCompile(`(?is)^("[^"]+"|\w+)\s+(.*)$`)
+	// the keywords ending the type in a column definition
+	columnConstraintRegexp = regexp.MustCompile(`(?is)\s+(NOT\s+NULL|NULL|DEFAULT|PRIMARY\s+KEY|UNIQUE|REFERENCES|CHECK|CONSTRAINT|COLLATE|GENERATED)\b`)
+	notNullRegexp          = regexp.MustCompile(`NOT\s+NULL`)
+	inlinePrimaryKeyRegexp = regexp.MustCompile(`PRIMARY\s+KEY`)
+	inlineUniqueRegexp     = regexp.MustCompile(`\bUNIQUE\b`)
+	otherConstraintRegexp  = regexp.MustCompile(`(?i)^(CHECK|EXCLUDE)\b`)
+	ifNotExistsRegexp      = regexp.MustCompile(`(?i)^IF\s+NOT\s+EXISTS\s+`)
+	dollarTagRegexp        = regexp.MustCompile(`^\$\w*\$`)
+	defaultRegexp          = regexp.MustCompile(`(?is)\bDEFAULT\s+(.*?)(?:\s+(?:NOT\s+NULL|NULL|PRIMARY\s+KEY|UNIQUE|REFERENCES|CHECK|CONSTRAINT)\b|$)`)
+)
+
+// ApplySQL applies the statements (of a migration) to the schema. The statements not about tables are skipped.
+func (s *Schema) ApplySQL(sql string) error {
+	for _, stmt := range splitStatements(sql) {
+		var err error
+		if m := createTableRegexp.FindStringSubmatch(stmt); m != nil {
+			err = s.createTable(m[1], m[2])
+		} else if m := alterTableRegexp.FindStringSubmatch(stmt); m != nil {
+			err = s.alterTable(m[1], m[2])
+		} else if m := dropTableRegexp.FindStringSubmatch(stmt); m != nil {
+			schemaName, tableName := splitQualifiedName(m[1])
+			if t := s.Table(schemaName, tableName); t != nil {
+				delete(s.Tables, t.GraphqlName())
+			}
+		}
+		if err != nil {
+			return err
+		}
+		s.resolveReferences()
+	}
+	return nil
+}
+
+// resolveReferences resolves the referenced columns of the foreign keys given without (`REFERENCES t`) to the primary
+// key of the referenced table; "id" by convention if its primary key is not known
+func (s *Schema) resolveReferences() {
+	for _, t := range s.Tables {
+		for _, fk := range t.ForeignKeys {
+			if len(fk.RefColumns) > 0 {
+				continue
+			}
+			fk.RefColumns = []string{"id"}
+			if refTable := s.Table(fk.RefSchema, fk.RefTable); refTable != nil && len(refTable.PrimaryKey) > 0 {
+				fk.RefColumns = append([]string{}, refTable.PrimaryKey...)
+			}
+		}
+	}
+}
+
+func (s *Schema) createTable(qualifiedName string, body string) error {
+	schemaName, tableName := splitQualifiedName(qualifiedName)
+	t := &Table{Schema: schemaName, Name: tableName}
+	for _, elem := range splitTopLevel(body, ',') {
+		if err := t.addElement(elem); err != nil {
+			return fmt.Errorf("table %s: %w", tableName, err)
+		}
+	}
+	s.Tables[t.GraphqlName()] = t
+	return nil
+}
+
+func (s *Schema) alterTable(qualifiedName string, actions string) error {
+	schemaName, tableName := splitQualifiedName(qualifiedName)
+	t := s.Table(schemaName, tableName)
+	if t == nil {
+		return fmt.Errorf("alter table %s: table not found", tableName)
+	}
+	for _, action := range splitTopLevel(actions, ',') {
+		words := strings.Fields(action)
+		if len(words) < 2 {
+			continue
+		}
+		switch strings.ToUpper(words[0]) + " " + strings.ToUpper(words[1]) {
+		case "ADD COLUMN":
+			def := strings.TrimSpace(action[strings.Index(strings.ToUpper(action), "COLUMN")+len("COLUMN"):])
+			def = ifNotExistsRegexp.ReplaceAllString(def, "")
+			if err := t.addElement(def); err != nil {
+				return fmt.Errorf("table %s: %w", tableName, err)
+			}
+		case "DROP COLUMN":
+			name := unquote(strings.TrimSuffix(words[len(words)-1], ";"))
+			if strings.EqualFold(name, "cascade") || strings.EqualFold(name, "restrict") {
+				name = unquote(words[len(words)-2])
+			}
+			t.dropColumn(name)
+		case "DROP CONSTRAINT":
+			name := words[2]
+			if strings.EqualFold(name, "IF") && len(words) > 4 {
+				name = words[4]
+			}
+			t.dropConstraint(unquote(name))
+		case "ALTER COLUMN":
+			if len(words) >= 3 {
+				t.alterColumn(unquote(words[2]), strings.Join(words[3:], " "))
+			}
+		default:
+			if strings.EqualFold(words[0], "ADD") {
+				if err := t.addElement(strings.TrimSpace(action[len(words[0]):])); err != nil {
+					return fmt.Errorf("table %s: %w", tableName, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addElement adds a column definition or a table constraint
+func (t *Table) addElement(elem string) error {
+	elem = strings.TrimSpace(elem)
+	constraintName := ""
+	if m := constraintRegexp.FindStringSubmatch(elem); m != nil {
+		constraintName, elem = unquote(m[1]), m[2]
+	}
+	if m := primaryKeyRegexp.FindStringSubmatch(elem); m != nil {
+		t.PrimaryKey = splitNames(m[1])
+		return nil
+	}
+	if m := uniqueRegexp.FindStringSubmatch(elem); m != nil {
+		t.addUnique(constraintName, splitNames(m[1]))
+		return nil
+	}
+	if m := foreignKeyRegexp.FindStringSubmatch(elem); m != nil {
+		return t.addForeignKey(constraintName, splitNames(m[1]), m[2])
+	}
+	if constraintName != "" || otherConstraintRegexp.MatchString(elem) {
+		return nil // other constraints are not modeled
+	}
+
+	m := columnRegexp.FindStringSubmatch(elem)
+	if m == nil {
+		return fmt.Errorf("cannot understand %q", elem)
+	}
+	column := &Column{Name: unquote(m[1])}
+	typeAndConstraints := m[2]
+	typeEnd := len(typeAndConstraints)
+	if loc := columnConstraintRegexp.FindStringIndex(typeAndConstraints); loc != nil {
+		typeEnd = loc[0]
+	}
+	column.Type = strings.ToLower(strings.TrimSpace(typeAndConstraints[:typeEnd]))
+	constraints := typeAndConstraints[typeEnd:]
+	upper := strings.ToUpper(constraints)
+	column.NotNull = notNullRegexp.MatchString(upper) || inlinePrimaryKeyRegexp.MatchString(upper)
+	if dm := defaultRegexp.FindStringSubmatch(constraints); dm != nil {
+		column.Default = strings.TrimSpace(dm[1])
+	}
+	t.dropColumn(column.Name) // in case of re-definition
+	t.Columns = append(t.Columns, column)
+
+	if inlinePrimaryKeyRegexp.MatchString(upper) {
+		t.PrimaryKey = []string{column.Name}
+	}
+	if inlineUniqueRegexp.MatchString(upper) {
+		t.addUnique("", []string{column.Name})
+	}
+	if idx := strings.Index(upper, "REFERENCES"); idx >= 0 {
+		return t.addForeignKey("", []string{column.Name}, constraints[idx:])
+	}
+	return nil
+}
+
+func (t *Table) addUnique(name string, columns []string) {
+	if name == "" {
+		name = fmt.Sprintf("%s_%s_key", t.Name, strings.Join(columns, "_"))
+	}
+	t.Uniques = append(t.Uniques, &Unique{Name: name, Columns: columns})
+}
+
+func (t *Table) addForeignKey(name string, columns []string, references string) error {
+	m := referencesRegexp.FindStringSubmatch(strings.TrimSpace(references))
+	if m == nil {
+		return fmt.Errorf("cannot understand %q", references)
+	}
+	if name == "" {
+		name = fmt.Sprintf("%s_%s_fkey", t.Name, strings.Join(columns, "_"))
+	}
+	fk := &ForeignKey{Name: name, Columns: columns, OnDelete: "no action"}
+	fk.RefSchema, fk.RefTable = splitQualifiedName(m[1])
+	fk.RefColumns = splitNames(m[2])
+	// without the columns (`REFERENCES t`), the primary key of the referenced table: see resolveReferences
+	if dm := onDeleteRegexp.FindStringSubmatch(m[3]); dm != nil {
+		fk.OnDelete = strings.ToLower(strings.Join(strings.Fields(dm[1]), " "))
+	}
+	t.ForeignKeys = append(t.ForeignKeys, fk)
+	return nil
+}
+
+func (t *Table) dropColumn(name string) {
+	var columns []*Column
+	for _, c := range t.Columns {
+		if c.Name != name {
+			columns = append(columns, c)
+		}
+	}
+	t.Columns = columns
+}
+
+func (t *Table) dropConstraint(name string) {
+	if name == t.Name+"_pkey" {
+		t.PrimaryKey = nil
+	}
+	var uniques []*Unique
+	for _, u := range t.Uniques {
+		if u.Name != name {
+			uniques = append(uniques, u)
+		}
+	}
+	t.Uniques = uniques
+	var fks []*ForeignKey
+	for _, fk := range t.ForeignKeys {
+		if fk.Name != name {
+			fks = append(fks, fk)
+		}
+	}
+	t.ForeignKeys = fks
+}
+
+// alterColumn applies `SET NOT NULL`, `DROP NOT NULL`, `SET DEFAULT x` and `DROP DEFAULT`
+func (t *Table) alterColumn(name string, action string) {
+	column := t.Column(name)
+	if column == nil {
+		return
+	}
+	upper := strings.ToUpper(action)
+	switch {
+	case strings.HasPrefix(upper, "SET NOT NULL"):
+		column.NotNull = true
+	case strings.HasPrefix(upper, "DROP NOT NULL"):
+		column.NotNull = false
+	case strings.HasPrefix(upper, "SET DEFAULT"):
+		column.Default = strings.TrimSpace(action[len("SET DEFAULT"):])
+	case strings.HasPrefix(upper, "DROP DEFAULT"):
+		column.Default = ""
+	}
+}
+
+// splitStatements splits the sql by `;`, except in quotes, dollar-quoted bodies (e.g., of functions) and comments
+func splitStatements(sql string) []string {
+	var stmts []string
+	var current strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-': // line comment
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			current.WriteByte(' ')
+		case c == '\'' || c == '"': // quoted, up to the closing quote
+			j := len(sql) - 1
+			if k := strings.IndexByte(sql[i+1:], c); k >= 0 {
+				j = i + 1 + k
+			}
+			current.WriteString(sql[i : j+1])
+			i = j
+		case c == '$': // dollar-quoted, e.g., $$ .. $$, up to the closing tag
+			tag := dollarTagRegexp.FindString(sql[i:])
+			if tag == "" {
+				current.WriteByte(c)
+				continue
+			}
+			j := len(sql) - 1
+			if k := strings.Index(sql[i+len(tag):], tag); k >= 0 {
+				j = i + len(tag) + k + len(tag) - 1
+			}
+			current.WriteString(sql[i : j+1])
+			i = j
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// splitTopLevel splits s by sep, except in parentheses and quotes
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// splitQualifiedName splits `"public"."subjects"` into ("public", "subjects"); the schema defaults to "public"
+func splitQualifiedName(name string) (string, string) {
+	parts := splitTopLevel(name, '.')
+	if len(parts) == 2 {
+		return unquote(parts[0]), unquote(parts[1])
+	}
+	return "public", unquote(name)
+}
+
+// splitNames splits a list of column names, e.g., `"instructor_id", "subject_id"`
+func splitNames(s string) []string {
+	var names []string
+	for _, part := range splitTopLevel(s, ',') {
+		names = append(names, unquote(part))
+	}
+	return names
+}
+
+// unquote removes the double quotes of an identifier; unquoted identifiers are lowercased, as postgres does
+func unquote(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		return name[1 : len(name)-1]
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,107 @@
+package graphqlfixture
+
+import (
+	"context"
+	"fmt"
+)
+
+// Phases of the hooks; an Event of these phases is recorded when the hook aborts the run.
+const (
+	PhaseBeforeSetup    Phase = "before-setup"
+	PhaseAfterSetup     Phase = "after-setup"
+	PhaseBeforeTeardown Phase = "before-teardown"
+	PhaseAfterTeardown  Phase = "after-teardown"
+)
+
+// HookInfo tells a hook which fixture it's called for
+type HookInfo struct {
+	FixtureIdx  int
+	FixtureName string
+}
+
+// Hooks are optional go code to run around each fixture's setup and teardown,
+// e.g., to flush a cache or upload a file between fixtures, or to observe the progress.
+// Hooks can be given at the Fixtures level (run for every fixture) and per Fixture; the Fixtures-level
+// hook runs before the per-Fixture one.
+// A Before/After hook returning error aborts the run, as if the fixture step failed:
+//   - BeforeSetup: the fixture is not setup (not counted in SetupUntil())
+//   - AfterSetup: the fixture is already setup (counted in SetupUntil(), so it will be torn down), the rest are not
+//   - BeforeTeardown: the fixture is not torn down (not counted in TeardownUntil())
+//   - AfterTeardown: the fixture is already torn down (counted in TeardownUntil()), the rest are not
+//
+// With Fixtures.AtomicSetup, a fixture with its own BeforeSetup or AfterSetup is not merged with others, so its hooks
+// still run right around its setup. The Fixtures-level hooks of merged fixtures run all before (BeforeSetup) or all
+// after (AfterSetup) the merged request.
+type Hooks struct {
+	BeforeSetup    func(ctx context.Context, info HookInfo) error
+	AfterSetup     func(ctx context.Context, info HookInfo, captured map[string]interface{}) error // captured: the values captured by this fixture
+	BeforeTeardown func(ctx context.Context, info HookInfo) error
+	AfterTeardown  func(ctx context.Context, info HookInfo) error
+	OnError        func(ctx context.Context, info HookInfo, err error) // called when a step of the fixture fails (including a hook aborting); err is what Setup/Teardown returns
+}
+
+// aroundSetup returns whether there is a BeforeSetup or AfterSetup hook
+func (h Hooks) aroundSetup() bool {
+	return h.BeforeSetup != nil || h.AfterSetup != nil
+}
+
+func (fs *Fixtures) hookInfo(fIdx int) HookInfo {
+	return HookInfo{FixtureIdx: fIdx, FixtureName: fs.Fixtures[fIdx].Name}
+}
+
+// hooksOf returns the hooks that apply to the fixture, in the order to be called
+func (fs *Fixtures) hooksOf(fIdx int) []Hooks {
+	return []Hooks{fs.Hooks, fs.Fixtures[fIdx].Hooks}
+}
+
+// runHooks calls the Before/After hooks of the given hook phase for the fixture, stopping at the first error.
+// Returns the hook error (nil if all passed), already recorded as failure.
+func (fs *Fixtures) runHooks(ctx context.Context, fIdx int, phase Phase) error {
+	info := fs.hookInfo(fIdx)
+	for _, hooks := range fs.hooksOf(fIdx) {
+		var err error
+		switch phase {
+		case PhaseBeforeSetup:
+			if hooks.BeforeSetup != nil {
+				err = hooks.BeforeSetup(ctx, info)
+			}
+		case PhaseAfterSetup:
+			if hooks.AfterSetup != nil {
+				err = hooks.AfterSetup(ctx, info, fs.capturedBy(fIdx))
+			}
+		case PhaseBeforeTeardown:
+			if hooks.BeforeTeardown != nil {
+				err = hooks.BeforeTeardown(ctx, info)
+			}
+		case PhaseAfterTeardown:
+			if hooks.AfterTeardown != nil {
+				err = hooks.AfterTeardown(ctx, info)
+			}
+		}
+		if err != nil {
+			return fs.recordFailure(ctx, fs.newEvent(fIdx, phase, StatusFailed), fmt.Errorf("aborted by hook: %w", err))
+		}
+	}
+	return nil
+}
+
+// runOnErrorHooks calls the OnError hooks for the fixture
+func (fs *Fixtures) runOnErrorHooks(ctx context.Context, fIdx int, err error) {
+	info := fs.hookInfo(fIdx)
+	for _, hooks := range fs.hooksOf(fIdx) {
+		if hooks.OnError != nil {
+			hooks.OnError(ctx, info, err)
+		}
+	}
+}
+
+// capturedBy returns the values captured by the fixture's captors
+func (fs *Fixtures) capturedBy(fIdx int) map[string]interface{} {
+	captured := map[string]interface{}{}
+	for captorName := range fs.Fixtures[fIdx].Captors {
+		if val, found := fs.captured[captorName]; found {
+			captured[captorName] = val
+		}
+	}
+	return captured
+}
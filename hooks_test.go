@@ -0,0 +1,130 @@
+package graphqlfixture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	// executor responds the same for all requests
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = []byte(`{ "data": { "insert_abc": { "returning": [ { "id": 13 } ] } } }`)
+		return nil
+	})
+	newFixtures := func(calls *[]string) Fixtures {
+		// tracingHooks records the hook calls, prefixed by level
+		tracingHooks := func(level string) Hooks {
+			return Hooks{
+				BeforeSetup: func(ctx context.Context, info HookInfo) error {
+					*calls = append(*calls, fmt.Sprintf("%s.BeforeSetup(%d)", level, info.FixtureIdx))
+					return nil
+				},
+				AfterSetup: func(ctx context.Context, info HookInfo, captured map[string]interface{}) error {
+					*calls = append(*calls, fmt.Sprintf("%s.AfterSetup(%d, %v)", level, info.FixtureIdx, captured))
+					return nil
+				},
+				BeforeTeardown: func(ctx context.Context, info HookInfo) error {
+					*calls = append(*calls, fmt.Sprintf("%s.BeforeTeardown(%d)", level, info.FixtureIdx))
+					return nil
+				},
+				AfterTeardown: func(ctx context.Context, info HookInfo) error {
+					*calls = append(*calls, fmt.Sprintf("%s.AfterTeardown(%d)", level, info.FixtureIdx))
+					return nil
+				},
+				OnError: func(ctx context.Context, info HookInfo, err error) {
+					*calls = append(*calls, fmt.Sprintf("%s.OnError(%d, %v)", level, info.FixtureIdx, err))
+				},
+			}
+		}
+		return Fixtures{
+			Hooks: tracingHooks("all"),
+			Fixtures: []Fixture{
+				{
+					Setup:    `mutation { insert_abc(objects: {}) { returning { id } } }`,
+					Captors:  map[string]string{"abc_id": "/data/insert_abc/returning/0/id"},
+					Teardown: gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+					Hooks:    tracingHooks("f0"),
+				},
+				{
+					Setup:    `mutation { insert_abc(objects: {}) { affected_rows } }`,
+					Teardown: gopointer.OfString(`mutation { delete_abc(where: {}) { affected_rows } }`),
+				},
+			},
+		}
+	}
+
+	t.Run("all hooks called in order", func(t *testing.T) {
+		var calls []string
+		fixtures := newFixtures(&calls)
+
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+		assert.NoError(t, fixtures.Teardown(context.Background(), executor))
+
+		assert.Equal(t, []string{
+			"all.BeforeSetup(0)",
+			"f0.BeforeSetup(0)",
+			"all.AfterSetup(0, map[abc_id:13])",
+			"f0.AfterSetup(0, map[abc_id:13])",
+			"all.BeforeSetup(1)",
+			"all.AfterSetup(1, map[])",
+			"all.BeforeTeardown(1)",
+			"all.AfterTeardown(1)",
+			"all.BeforeTeardown(0)",
+			"f0.BeforeTeardown(0)",
+			"all.AfterTeardown(0)",
+			"f0.AfterTeardown(0)",
+		}, calls)
+	})
+
+	t.Run("BeforeSetup aborts", func(t *testing.T) {
+		var calls []string
+		fixtures := newFixtures(&calls)
+		fixtures.Fixtures[1].Hooks.BeforeSetup = func(ctx context.Context, info HookInfo) error {
+			return errors.New("cache not flushed")
+		}
+
+		err := fixtures.Setup(context.Background(), executor)
+
+		assert.EqualError(t, err, "fixture[1].before-setup failed: aborted by hook: cache not flushed")
+		assert.Equal(t, gopointer.OfInt(0), fixtures.SetupUntil()) // fixture[1] not setup
+		assert.Equal(t, "fixture[1].before-setup failed: aborted by hook: cache not flushed", fixtures.Logs()[len(fixtures.Logs())-1])
+		assert.Equal(t, "all.OnError(1, fixture[1].before-setup failed: aborted by hook: cache not flushed)", calls[len(calls)-1])
+	})
+
+	t.Run("AfterSetup aborts", func(t *testing.T) {
+		var calls []string
+		fixtures := newFixtures(&calls)
+		fixtures.Fixtures[0].Hooks.AfterSetup = func(ctx context.Context, info HookInfo, captured map[string]interface{}) error {
+			return errors.New("upload failed")
+		}
+
+		err := fixtures.Setup(context.Background(), executor)
+
+		assert.EqualError(t, err, "fixture[0].after-setup failed: aborted by hook: upload failed")
+		assert.Equal(t, gopointer.OfInt(0), fixtures.SetupUntil()) // fixture[0] is setup, and needs teardown
+		assert.Equal(t, []string{
+			"fixture[0].setup: completed",
+			"fixture[0].captors: completed with 1 capture(s)",
+			"fixture[0].after-setup failed: aborted by hook: upload failed",
+		}, fixtures.Logs())
+	})
+
+	t.Run("BeforeTeardown aborts", func(t *testing.T) {
+		var calls []string
+		fixtures := newFixtures(&calls)
+		fixtures.Fixtures[0].Hooks.BeforeTeardown = func(ctx context.Context, info HookInfo) error {
+			return errors.New("not now")
+		}
+
+		assert.NoError(t, fixtures.Setup(context.Background(), executor))
+		err := fixtures.Teardown(context.Background(), executor)
+
+		assert.EqualError(t, err, "fixture[0].before-teardown failed: aborted by hook: not now")
+		assert.Equal(t, gopointer.OfInt(1), fixtures.TeardownUntil()) // fixture[0] not torn down
+	})
+}
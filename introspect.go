@@ -0,0 +1,205 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// IntrospectionQuery is the standard introspection query, asking for what the fixtures are validated against
+const IntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  fields(includeDeprecated: true) {
+    name
+    args { ...InputValue }
+    type { ...TypeRef }
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } } }
+}`
+
+// ErrStaleSchema is returned by CheckSchemaCache when the cached schema differs from the live one
+var ErrStaleSchema = errors.New("cached schema is stale")
+
+// IntrospectSchema fetches the schema of the graphql server with the introspection query, through the executor
+// (i.e., the same client, headers and transport as the fixtures)
+func IntrospectSchema(ctx context.Context, executor Executor) (*Schema, error) {
+	introspectionBytes, err := fetchIntrospection(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIntrospection(introspectionBytes)
+}
+
+// CacheSchema fetches the schema of the graphql server (see IntrospectSchema) and writes it to the cache file at path
+// (as the introspection json, which LoadSchema reads)
+func CacheSchema(ctx context.Context, executor Executor, path string) (*Schema, error) {
+	introspectionBytes, err := fetchIntrospection(ctx, executor)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := ParseIntrospection(introspectionBytes)
+	if err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, introspectionBytes, "", "  "); err != nil {
+		return nil, fmt.Errorf("fail to encode schema: %w", err)
+	}
+	indented.WriteByte('\n')
+	if err := ioutil.WriteFile(path, indented.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("fail to write schema cache: %w", err)
+	}
+	return schema, nil
+}
+
+// CheckSchemaCache compares the cache file at path with the live schema of the graphql server.
+// Returns ErrStaleSchema (naming the types that changed) if the cached schema is outdated or missing;
+// only what matters to the validation is compared (e.g., not the descriptions, nor the order of the types).
+func CheckSchemaCache(ctx context.Context, executor Executor, path string) error {
+	cachedBytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s not found", ErrStaleSchema, path)
+	} else if err != nil {
+		return fmt.Errorf("fail to read schema cache: %w", err)
+	}
+	cached, err := introspectionTypes(cachedBytes)
+	if err != nil {
+		return fmt.Errorf("schema cache %s: %w", path, err)
+	}
+	liveBytes, err := fetchIntrospection(ctx, executor)
+	if err != nil {
+		return err
+	}
+	live, err := introspectionTypes(liveBytes)
+	if err != nil {
+		return err
+	}
+
+	var changed, added, removed []string
+	for name, fingerprint := range live {
+		if cachedFingerprint, found := cached[name]; !found {
+			added = append(added, name)
+		} else if cachedFingerprint != fingerprint {
+			changed = append(changed, name)
+		}
+	}
+	for name := range cached {
+		if _, found := live[name]; !found {
+			removed = append(removed, name)
+		}
+	}
+	var diffs []string
+	for _, diff := range []struct {
+		what  string
+		names []string
+	}{{"changed", changed}, {"added", added}, {"removed", removed}} {
+		if len(diff.names) > 0 {
+			sort.Strings(diff.names)
+			diffs = append(diffs, fmt.Sprintf("%s: %s", diff.what, strings.Join(diff.names, ", ")))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%w: %s differs from the live schema: %s", ErrStaleSchema, path, strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// fetchIntrospection runs the introspection query; returns the json result (with the `data` wrapper)
+func fetchIntrospection(ctx context.Context, executor Executor) ([]byte, error) {
+	resp, err := doGraphqlRequest(ctx, executor, IntrospectionQuery, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("introspection failed: %w", err)
+	}
+	if !resp.Exists("data", "__schema") {
+		return nil, fmt.Errorf("introspection failed: no __schema in the response")
+	}
+	return resp.Bytes(), nil
+}
+
+// introspectionTypes returns a fingerprint of each type of the introspection json, keyed by the type name.
+// The root types are included as pseudo types, e.g., "schema.mutationType".
+func introspectionTypes(introspectionBytes []byte) (map[string]string, error) {
+	schema, err := decodeIntrospection(introspectionBytes)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := func(v interface{}) string {
+		jsonBytes, _ := json.Marshal(v)
+		return fmt.Sprintf("%x", sha256.Sum256(jsonBytes))
+	}
+	types := map[string]string{
+		"schema.queryType":        fingerprint(schema.QueryType),
+		"schema.mutationType":     fingerprint(schema.MutationType),
+		"schema.subscriptionType": fingerprint(schema.SubscriptionType),
+	}
+	for _, t := range schema.Types {
+		types[t.Name] = fingerprint(sortedType(t))
+	}
+	return types, nil
+}
+
+// sortedType returns a copy of the type with its fields, arguments, values .. sorted by name,
+// as the order they are listed in is up to the server
+func sortedType(t introspectionType) introspectionType {
+	sortInputValues := func(values []introspectionInputValue) []introspectionInputValue {
+		values = append([]introspectionInputValue{}, values...)
+		sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+		return values
+	}
+	sortTypeRefs := func(refs []introspectionTypeRef) []introspectionTypeRef {
+		refs = append([]introspectionTypeRef{}, refs...)
+		name := func(ref introspectionTypeRef) string {
+			if ref.Name == nil {
+				return ""
+			}
+			return *ref.Name
+		}
+		sort.Slice(refs, func(i, j int) bool { return name(refs[i]) < name(refs[j]) })
+		return refs
+	}
+	sorted := t
+	sorted.Fields = nil
+	for _, field := range t.Fields {
+		field.Args = sortInputValues(field.Args)
+		sorted.Fields = append(sorted.Fields, field)
+	}
+	sort.Slice(sorted.Fields, func(i, j int) bool { return sorted.Fields[i].Name < sorted.Fields[j].Name })
+	sorted.InputFields = sortInputValues(t.InputFields)
+	sorted.Interfaces = sortTypeRefs(t.Interfaces)
+	sorted.PossibleTypes = sortTypeRefs(t.PossibleTypes)
+	sorted.EnumValues = append([]introspectionName{}, t.EnumValues...)
+	sort.Slice(sorted.EnumValues, func(i, j int) bool { return sorted.EnumValues[i].Name < sorted.EnumValues[j].Name })
+	return sorted
+}
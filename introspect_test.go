@@ -0,0 +1,78 @@
+package graphqlfixture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/graphql-go/graphql"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// schemaServer answers the graphql requests (e.g., the introspection query) per the schema
+func schemaServer(t *testing.T, sdl string) Executor {
+	schema, err := ParseSDL(sdl)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		result := graphql.Do(graphql.Params{Schema: schema.schema, RequestString: req.Query, Context: ctx})
+		respBytes, err := json.Marshal(result)
+		*resp.(*[]byte) = respBytes
+		return err
+	})
+}
+
+func TestCacheSchema(t *testing.T) {
+	ctx := context.Background()
+	cachePath := filepath.Join(t.TempDir(), "schema.json")
+	live := schemaServer(t, testSDL)
+
+	// no cache yet
+	err := CheckSchemaCache(ctx, live, cachePath)
+	assert.True(t, errors.Is(err, ErrStaleSchema))
+
+	// WHEN: cached
+	schema, err := CacheSchema(ctx, live, cachePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// THEN: the introspected schema validates the fixtures, and so does the cache file
+	cached, err := LoadSchema(cachePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, s := range []*Schema{schema, cached} {
+		fixtures := Fixtures{Fixtures: []Fixture{{Setup: `mutation { insert_instuctors(objects: []) { affected_rows } }`}}, Schema: s}
+		fixtures.Parse()
+		if assert.Error(t, fixtures.parseErr) {
+			assert.Contains(t, fixtures.parseErr.Error(), `Cannot query field "insert_instuctors" on type "mutation_root"`)
+		}
+	}
+	assert.NoError(t, CheckSchemaCache(ctx, live, cachePath))
+
+	// WHEN: the live schema has changed since
+	changedSDL := strings.Replace(testSDL, "input subjects_insert_input { name: String", "input subjects_insert_input { code: String name: String", 1)
+	changedSDL += "type instructors { id: Int! }"
+	err = CheckSchemaCache(ctx, schemaServer(t, changedSDL), cachePath)
+
+	// THEN
+	assert.True(t, errors.Is(err, ErrStaleSchema))
+	assert.EqualError(t, err, "cached schema is stale: "+cachePath+" differs from the live schema: changed: subjects_insert_input; added: instructors")
+}
+
+func TestIntrospectSchemaError(t *testing.T) {
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = []byte(`{"errors": [{"message": "introspection is disabled", "extensions": {"code": "validation-failed"}}]}`)
+		return nil
+	})
+	_, err := IntrospectSchema(context.Background(), executor)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "introspection failed")
+		assert.Contains(t, err.Error(), "introspection is disabled")
+	}
+}
@@ -0,0 +1,372 @@
+package graphqlfixture
+
+import (
+	"fmt"
+	gqlast "github.com/graphql-go/graphql/language/ast"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious a lint Diagnostic is
+type Severity string
+
+const (
+	SeverityOff     Severity = "off" // the rule is disabled
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Lint rule IDs, to configure the severity (LintOptions.Severities) or suppress a rule for a fixture (Fixture.LintSuppress)
+const (
+	RuleMissingTeardown   = "missing-teardown"    // a mutation setup without teardown
+	RuleUnusedCaptor      = "unused-captor"       // a captor not used by any later fixture (nor the fixture's teardown, nor the tests)
+	RuleTeardownFewerKeys = "teardown-fewer-keys" // the teardown deletes by fewer keys (ids ..) than the rows inserted by the setup
+	RuleUnusedVariable    = "unused-variable"     // a variable declared but not used in the graphql
+	RuleQueryWithTeardown = "query-with-teardown" // a query setup (which creates nothing) with a teardown
+	RuleTeardownCoverage  = "teardown-coverage"   // a table inserted into (e.g., by a nested insert) that no teardown deletes from
+	RuleCascadeTeardown   = "cascade-teardown"    // a table inserted into that only ON DELETE CASCADE deletes from
+	RuleTeardownOrder     = "teardown-order"      // a teardown deleting the rows referenced (by a foreign key) by those a later teardown deletes
+)
+
+// defaultSeverities are the severities of the rules unless configured otherwise
+var defaultSeverities = map[string]Severity{
+	RuleMissingTeardown:   SeverityWarning,
+	RuleUnusedCaptor:      SeverityWarning,
+	RuleTeardownFewerKeys: SeverityWarning,
+	RuleUnusedVariable:    SeverityError, // graphql servers reject it
+	RuleQueryWithTeardown: SeverityWarning,
+	RuleTeardownCoverage:  SeverityWarning,
+	RuleCascadeTeardown:   SeverityInfo,
+	RuleTeardownOrder:     SeverityWarning,
+}
+
+// LintOptions configures Lint
+type LintOptions struct {
+	Severities  map[string]Severity // optional: overrides the default severity of the rules, by rule ID; SeverityOff disables the rule
+	UsedCaptors []string            // optional: the captors used by the tests (e.g., with Get), not to be reported as unused
+}
+
+// Diagnostic is a problem found by Lint
+type Diagnostic struct {
+	Rule        string   `json:"rule"`
+	Severity    Severity `json:"severity"`
+	FixtureIdx  int      `json:"fixtureIdx"`
+	FixtureName string   `json:"fixtureName,omitempty"`
+	Phase       Phase    `json:"phase"`
+	Message     string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	step := fmt.Sprintf("fixture[%d].%s", d.FixtureIdx, d.Phase)
+	if d.FixtureName != "" {
+		step += fmt.Sprintf(" (%s)", d.FixtureName)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", step, d.Severity, d.Message, d.Rule)
+}
+
+// Lint checks the fixtures for the mistakes that Parse() doesn't catch (they are valid, but likely not what's
+// intended), e.g., a mutation without teardown; see the Rule* constants. The diagnostics are in the order of the
+// fixtures; the rules suppressed by the fixture (Fixture.LintSuppress) or disabled (SeverityOff) are not reported.
+// Returns error if the fixtures fail to parse, or the options refer to unknown rules.
+func (fs *Fixtures) Lint(opts LintOptions) ([]Diagnostic, error) {
+	if !fs.parsed {
+		fs.Parse()
+	}
+	if fs.parseErr != nil {
+		return nil, fmt.Errorf("parse error: %w", fs.parseErr)
+	}
+	severities := map[string]Severity{}
+	for rule, severity := range defaultSeverities {
+		severities[rule] = severity
+	}
+	for rule, severity := range opts.Severities {
+		if _, found := defaultSeverities[rule]; !found {
+			return nil, fmt.Errorf("unknown lint rule: %s", rule)
+		}
+		severities[rule] = severity
+	}
+	for fIdx, f := range fs.Fixtures {
+		for _, rule := range f.LintSuppress {
+			if _, found := defaultSeverities[rule]; !found {
+				return nil, fmt.Errorf("fixture[%d]: unknown lint rule: %s", fIdx, rule)
+			}
+		}
+	}
+
+	// the captors used by any fixture (Parse has checked they are captured before use) or the tests
+	usedCaptors := map[string]bool{}
+	for _, f := range fs.Fixtures {
+		for _, varName := range append(append([]string{}, f.setupVariables...), f.teardownVariables...) {
+			usedCaptors[varName] = true
+		}
+	}
+	for _, captorName := range opts.UsedCaptors {
+		usedCaptors[captorName] = true
+	}
+
+	var diagnostics []Diagnostic
+	for fIdx, f := range fs.Fixtures {
+		report := func(rule string, phase Phase, format string, args ...interface{}) {
+			diagnostics = fs.appendDiagnostic(diagnostics, severities, fIdx, rule, phase, format, args...)
+		}
+
+		setupDoc, _ := f.setupDoc() // no error, since the fixtures have passed parsing
+		if f.setupOperation == "mutation" && f.Teardown == nil {
+			report(RuleMissingTeardown, PhaseSetup, "mutation without teardown: what it creates is left behind")
+		}
+		for _, varName := range unusedVariables(setupDoc) {
+			report(RuleUnusedVariable, PhaseSetup, "variable $%s is declared but not used", varName)
+		}
+
+		captorNames := make([]string, 0, len(f.Captors))
+		for captorName := range f.Captors {
+			captorNames = append(captorNames, captorName)
+		}
+		sort.Strings(captorNames)
+		for _, captorName := range captorNames {
+			if !usedCaptors[captorName] {
+				report(RuleUnusedCaptor, PhaseCaptors, "captor %s is not used by its teardown, later fixtures or the tests", captorName)
+			}
+		}
+
+		if f.Teardown != nil {
+			if f.setupOperation == "query" {
+				report(RuleQueryWithTeardown, PhaseTeardown, "teardown of a query setup, which creates nothing")
+			}
+			teardownDoc, _ := f.teardownDoc()
+			for _, varName := range unusedVariables(teardownDoc) {
+				report(RuleUnusedVariable, PhaseTeardown, "variable $%s is declared but not used", varName)
+			}
+			inserted := insertedRows(setupDoc)
+			deleted := deletedKeys(teardownDoc)
+			for _, table := range sortedKeys(deleted) {
+				if rows, found := inserted[table]; found && deleted[table] < rows {
+					report(RuleTeardownFewerKeys, PhaseTeardown, "deletes %s by %d key(s), but the setup inserts %d row(s)", table, deleted[table], rows)
+				}
+			}
+		}
+	}
+	reportFixture := func(fIdx int, rule string, phase Phase, format string, args ...interface{}) {
+		diagnostics = fs.appendDiagnostic(diagnostics, severities, fIdx, rule, phase, format, args...)
+	}
+	fs.teardownCoverage(reportFixture)
+	fs.teardownOrderViolations(reportFixture)
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].FixtureIdx < diagnostics[j].FixtureIdx })
+	return diagnostics, nil
+}
+
+// appendDiagnostic appends the diagnostic of the rule on the fixture, unless the rule is disabled or suppressed
+func (fs *Fixtures) appendDiagnostic(diagnostics []Diagnostic, severities map[string]Severity,
+	fIdx int, rule string, phase Phase, format string, args ...interface{}) []Diagnostic {
+	if severities[rule] == SeverityOff {
+		return diagnostics
+	}
+	for _, suppressed := range fs.Fixtures[fIdx].LintSuppress {
+		if suppressed == rule {
+			return diagnostics
+		}
+	}
+	return append(diagnostics, Diagnostic{
+		Rule:        rule,
+		Severity:    severities[rule],
+		FixtureIdx:  fIdx,
+		FixtureName: fs.Fixtures[fIdx].Name,
+		Phase:       phase,
+		Message:     fmt.Sprintf(format, args...),
+	})
+}
+
+// unusedVariables returns the variables declared by the operations of the doc but not used in them
+func unusedVariables(doc *gqlast.Document) []string {
+	if doc == nil {
+		return nil
+	}
+	fragments := fragmentsOf(doc)
+
+	var unused []string
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*gqlast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		used := map[string]bool{}
+		visitedFragments := map[string]bool{}
+		var visitValue func(value gqlast.Value)
+		visitValue = func(value gqlast.Value) {
+			switch v := value.(type) {
+			case *gqlast.Variable:
+				used[v.Name.Value] = true
+			case *gqlast.ListValue:
+				for _, elem := range v.Values {
+					visitValue(elem)
+				}
+			case *gqlast.ObjectValue:
+				for _, field := range v.Fields {
+					visitValue(field.Value)
+				}
+			}
+		}
+		visitDirectives := func(directives []*gqlast.Directive) {
+			for _, directive := range directives {
+				for _, arg := range directive.Arguments {
+					visitValue(arg.Value)
+				}
+			}
+		}
+		var visitSelectionSet func(selectionSet *gqlast.SelectionSet)
+		visitSelectionSet = func(selectionSet *gqlast.SelectionSet) {
+			if selectionSet == nil {
+				return
+			}
+			for _, selection := range selectionSet.Selections {
+				switch node := selection.(type) {
+				case *gqlast.Field:
+					for _, arg := range node.Arguments {
+						visitValue(arg.Value)
+					}
+					visitDirectives(node.Directives)
+					visitSelectionSet(node.SelectionSet)
+				case *gqlast.InlineFragment:
+					visitDirectives(node.Directives)
+					visitSelectionSet(node.SelectionSet)
+				case *gqlast.FragmentSpread:
+					visitDirectives(node.Directives)
+					if fragment, found := fragments[node.Name.Value]; found && !visitedFragments[node.Name.Value] {
+						visitedFragments[node.Name.Value] = true
+						visitSelectionSet(fragment.SelectionSet)
+					}
+				}
+			}
+		}
+		visitDirectives(operation.Directives)
+		visitSelectionSet(operation.SelectionSet)
+
+		for _, vDef := range operation.VariableDefinitions {
+			if !used[vDef.Variable.Name.Value] {
+				unused = append(unused, vDef.Variable.Name.Value)
+			}
+		}
+	}
+	return unused
+}
+
+// insertedRows returns the number of rows inserted by the root fields of the doc (insert_X, insert_X_one), by table;
+// the rows of nested inserts, or objects given as a variable, are not counted
+func insertedRows(doc *gqlast.Document) map[string]int {
+	rows := map[string]int{}
+	for _, field := range rootFields(doc, "mutation") {
+		name := field.Name.Value
+		switch {
+		case strings.HasPrefix(name, "insert_") && strings.HasSuffix(name, "_one"):
+			rows[strings.TrimSuffix(strings.TrimPrefix(name, "insert_"), "_one")]++
+		case strings.HasPrefix(name, "insert_"):
+			for _, arg := range field.Arguments {
+				if arg.Name.Value != "objects" {
+					continue
+				}
+				switch objects := arg.Value.(type) {
+				case *gqlast.ListValue:
+					rows[strings.TrimPrefix(name, "insert_")] += len(objects.Values)
+				case *gqlast.ObjectValue: // a single object is accepted as a list of one
+					rows[strings.TrimPrefix(name, "insert_")]++
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// deletedKeys returns the number of keys the root fields of the doc delete by (delete_X_by_pk, and the values of
+// `_eq` and `_in` in the where of delete_X), by table. A table deleted by a where that can't be counted
+// (e.g., `_in: $ids`, `_like`) is not included.
+func deletedKeys(doc *gqlast.Document) map[string]int {
+	keys := map[string]int{}
+	uncountable := map[string]bool{}
+	for _, field := range rootFields(doc, "mutation") {
+		name := field.Name.Value
+		switch {
+		case strings.HasPrefix(name, "delete_") && strings.HasSuffix(name, "_by_pk"):
+			keys[strings.TrimSuffix(strings.TrimPrefix(name, "delete_"), "_by_pk")]++
+		case strings.HasPrefix(name, "delete_"):
+			table := strings.TrimPrefix(name, "delete_")
+			count := 0
+			for _, arg := range field.Arguments {
+				if arg.Name.Value == "where" {
+					count = whereKeys(arg.Value)
+				}
+			}
+			if count > 0 {
+				keys[table] += count
+			} else {
+				uncountable[table] = true
+			}
+		}
+	}
+	for table := range uncountable {
+		delete(keys, table)
+	}
+	return keys
+}
+
+// whereKeys counts the values of `_eq` and `_in` in the where; 0 if any `_in` can't be counted
+func whereKeys(where gqlast.Value) int {
+	count := 0
+	var visit func(value gqlast.Value) bool
+	visit = func(value gqlast.Value) bool {
+		switch v := value.(type) {
+		case *gqlast.ListValue:
+			for _, elem := range v.Values {
+				if !visit(elem) {
+					return false
+				}
+			}
+		case *gqlast.ObjectValue:
+			for _, field := range v.Fields {
+				switch field.Name.Value {
+				case "_eq":
+					count++
+				case "_in":
+					list, ok := field.Value.(*gqlast.ListValue)
+					if !ok {
+						return false
+					}
+					count += len(list.Values)
+				default:
+					if !visit(field.Value) {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+	if !visit(where) {
+		return 0
+	}
+	return count
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rootFields returns the root fields of the operations (of the operation type) in the doc
+func rootFields(doc *gqlast.Document, operationType string) []*gqlast.Field {
+	if doc == nil {
+		return nil
+	}
+	fragments := fragmentsOf(doc)
+	var fields []*gqlast.Field
+	for _, def := range doc.Definitions {
+		if operation, ok := def.(*gqlast.OperationDefinition); ok && operation.Operation == operationType {
+			fields = append(fields, flattenFields(operation.SelectionSet, fragments)...)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,120 @@
+package graphqlfixture
+
+import (
+	"github.com/gmm1900/gopointer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		name                string
+		givenFixtures       []Fixture
+		givenOpts           LintOptions
+		expectedDiagnostics []string
+		expectedErr         string
+	}{
+		{
+			name: "no problem",
+			givenFixtures: []Fixture{
+				{
+					Setup:    `mutation { insert_abc(objects: [{ name: "abc1" }, { name: "abc2" }]) { returning { id } } }`,
+					Captors:  map[string]string{"id1": "/data/insert_abc/returning/0/id", "id2": "/data/insert_abc/returning/1/id"},
+					Teardown: gopointer.OfString(`mutation ($id1: Int!, $id2: Int!) { delete_abc(where: { id: { _in: [$id1, $id2] } }) { affected_rows } }`),
+				},
+				{
+					Setup:   `query ($id1: Int!) { abc_by_pk(id: $id1) { ...abc } } fragment abc on abc { name }`,
+					Captors: map[string]string{"name": "/data/abc_by_pk/name"},
+				},
+			},
+			givenOpts: LintOptions{UsedCaptors: []string{"name"}},
+		},
+		{
+			name: "all the rules",
+			givenFixtures: []Fixture{
+				{
+					Name:    "abc",
+					Setup:   `mutation { insert_abc_one(object: { name: "abc1" }) { id } }`,
+					Captors: map[string]string{"abc_id": "/data/insert_abc_one/id"},
+				},
+				{
+					Setup:    `mutation ($abc_id: Int!) { insert_xyz(objects: [{ name: "xyz1" }, { name: "xyz2" }]) { returning { id } } }`,
+					Captors:  map[string]string{"xyz_id": "/data/insert_xyz/returning/0/id"},
+					Teardown: gopointer.OfString(`mutation ($xyz_id: Int!) { delete_xyz_by_pk(id: $xyz_id) { id } }`),
+				},
+				{
+					Setup:    `query { abc { id } }`,
+					Captors:  map[string]string{"abc_ids": "/data/abc"},
+					Teardown: gopointer.OfString(`mutation { delete_abc(where: {}) { affected_rows } }`),
+				},
+			},
+			expectedDiagnostics: []string{
+				"fixture[0].setup (abc): warning: mutation without teardown: what it creates is left behind [missing-teardown]",
+				"fixture[1].setup: error: variable $abc_id is declared but not used [unused-variable]",
+				"fixture[1].teardown: warning: deletes xyz by 1 key(s), but the setup inserts 2 row(s) [teardown-fewer-keys]",
+				"fixture[2].captors: warning: captor abc_ids is not used by its teardown, later fixtures or the tests [unused-captor]",
+				"fixture[2].teardown: warning: teardown of a query setup, which creates nothing [query-with-teardown]",
+			},
+		},
+		{
+			name: "configured severities and suppressed rules",
+			givenFixtures: []Fixture{
+				{
+					Setup:   `mutation { insert_abc_one(object: { name: "abc1" }) { id } }`,
+					Captors: map[string]string{"abc_id": "/data/insert_abc_one/id"},
+				},
+				{
+					Setup:        `mutation { insert_xyz_one(object: { name: "xyz1" }) { id } }`,
+					Captors:      map[string]string{"xyz_id": "/data/insert_xyz_one/id"},
+					LintSuppress: []string{RuleMissingTeardown},
+				},
+			},
+			givenOpts: LintOptions{Severities: map[string]Severity{
+				RuleMissingTeardown:  SeverityError,
+				RuleUnusedCaptor:     SeverityOff,
+				RuleTeardownCoverage: SeverityOff,
+			}},
+			expectedDiagnostics: []string{
+				"fixture[0].setup: error: mutation without teardown: what it creates is left behind [missing-teardown]",
+			},
+		},
+		{
+			name: "uncountable keys are not reported",
+			givenFixtures: []Fixture{
+				{
+					Setup:    `mutation { insert_abc(objects: [{ name: "abc1" }, { name: "abc2" }]) { affected_rows } }`,
+					Teardown: gopointer.OfString(`mutation { delete_abc(where: { name: { _like: "abc%" } }) { affected_rows } }`),
+				},
+			},
+		},
+		{
+			name:          "unknown rule",
+			givenFixtures: []Fixture{{Setup: `query { abc { id } }`, LintSuppress: []string{"no-such-rule"}}},
+			expectedErr:   "fixture[0]: unknown lint rule: no-such-rule",
+		},
+		{
+			name:          "parse error",
+			givenFixtures: []Fixture{{Setup: `query { abc { id }`}},
+			expectedErr:   "parse error: 1 error occurred:",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixtures := Fixtures{Fixtures: tc.givenFixtures}
+			diagnostics, err := fixtures.Lint(tc.givenOpts)
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			var actual []string
+			for _, diagnostic := range diagnostics {
+				actual = append(actual, diagnostic.String())
+			}
+			assert.Equal(t, tc.expectedDiagnostics, actual)
+		})
+	}
+}
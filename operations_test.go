@@ -0,0 +1,181 @@
+package graphqlfixture
+
+import (
+	"context"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOperationSteps(t *testing.T) {
+	testCases := []struct {
+		name          string
+		givenGraphql  string
+		givenNames    []string
+		expectedSteps []operationStep
+		expectedErr   string
+	}{
+		{
+			name:          "single anonymous operation",
+			givenGraphql:  `mutation ($abc_id: Int!) { delete_abc_by_pk(id: $abc_id) { id } }`,
+			expectedSteps: []operationStep{{variables: []string{"abc_id"}, operation: "mutation"}},
+		},
+		{
+			name:          "single named operation, not selected: sent whole",
+			givenGraphql:  `query lookup { abc { id } }`,
+			expectedSteps: []operationStep{{operation: "query"}},
+		},
+		{
+			name:         "named operations selected: variables of each only",
+			givenGraphql: `query lookup($name: String!) { abc(where: { name: { _eq: $name } }) { id } } mutation insert($abc_id: Int!) { insert_xyz_one(object: { abc_id: $abc_id }) { id } }`,
+			givenNames:   []string{"insert", "lookup"},
+			expectedSteps: []operationStep{
+				{name: "insert", variables: []string{"abc_id"}, operation: "mutation"},
+				{name: "lookup", variables: []string{"name"}, operation: "query"},
+			},
+		},
+		{
+			name:         "several named operations, none selected",
+			givenGraphql: `query lookup { abc { id } } mutation insert { insert_xyz_one(object: {}) { id } }`,
+			expectedErr:  "has operations lookup, insert, select the one(s) to run by name",
+		},
+		{
+			name:         "selected operation not found",
+			givenGraphql: `query lookup { abc { id } }`,
+			givenNames:   []string{"insert"},
+			expectedErr:  "operation insert not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := parseGraphql(tc.givenGraphql)
+			assert.NoError(t, err)
+
+			steps, err := operationSteps(doc, tc.givenNames)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedSteps, steps)
+		})
+	}
+}
+
+func TestNamedOperations(t *testing.T) {
+	fixtures := Fixtures{Fixtures: []Fixture{
+		{
+			Setup: `query lookup { abc(where: { name: { _eq: "abc1" } }) { id } }
+				mutation insert { insert_xyz_one(object: { name: "xyz1" }) { id } }
+				mutation other($unused: Int!) { insert_def_one(object: { id: $unused }) { id } }`, // not selected: its variables aren't needed
+			SetupOperations: []string{"lookup", "insert"},
+			Captors:         map[string]string{"abc_id": "/data/abc/0/id", "xyz_id": "/data/insert_xyz_one/id"},
+			Teardown: gopointer.OfString(`mutation xyz($xyz_id: Int!) { delete_xyz_by_pk(id: $xyz_id) { id } }
+				mutation abc($abc_id: Int!) { delete_abc_by_pk(id: $abc_id) { id } }`),
+			TeardownOperations: []string{"xyz"},
+		},
+	}}
+	fixtures.Parse()
+	assert.NoError(t, fixtures.parseErr)
+
+	responses := map[string]string{
+		"lookup": `{ "data": { "abc": [ { "id": 13 } ] } }`,
+		"insert": `{ "data": { "insert_xyz_one": { "id": 21 } } }`,
+		"xyz":    `{ "data": { "delete_xyz_by_pk": { "id": 21 } } }`,
+	}
+	var requests []graphqlclient.Request
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = []byte(responses[req.OperationName])
+		requests = append(requests, req)
+		return nil
+	})
+
+	assert.NoError(t, fixtures.Setup(context.Background(), executor))
+	assert.Equal(t, map[string]interface{}{"abc_id": 13.0, "xyz_id": 21.0}, fixtures.captured)
+	assert.NoError(t, fixtures.Teardown(context.Background(), executor))
+
+	var sent []string
+	for _, req := range requests {
+		sent = append(sent, req.OperationName)
+	}
+	assert.Equal(t, []string{"lookup", "insert", "xyz"}, sent)
+	assert.Equal(t, map[string]interface{}{"xyz_id": 21.0}, requests[2].Variables)
+}
+
+func TestNamedOperationsPartialSetup(t *testing.T) {
+	fixtures := Fixtures{Fixtures: []Fixture{
+		{
+			Setup: `mutation insert { insert_xyz_one(object: { name: "xyz1" }) { id } }
+				query lookup { abc(where: { name: { _eq: "abc1" } }) { id } }
+				query other { def { id } }`,
+			SetupOperations: []string{"insert", "lookup", "other"},
+			Captors:         map[string]string{"xyz_id": "/data/insert_xyz_one/id", "abc_id": "/data/abc/0/id"},
+			Teardown:        gopointer.OfString(`mutation ($xyz_id: Int!) { delete_xyz_by_pk(id: $xyz_id) { id } }`),
+		},
+	}}
+	responses := map[string]string{
+		"insert": `{ "data": { "insert_xyz_one": { "id": 21 } } }`,
+		"lookup": `{ "errors": [ { "message": "boom" } ] }`,
+		"":       `{ "data": { "delete_xyz_by_pk": { "id": 21 } } }`,
+	}
+	var requests []graphqlclient.Request
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = []byte(responses[req.OperationName])
+		requests = append(requests, req)
+		return nil
+	})
+
+	err := fixtures.Setup(context.Background(), executor)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "fixture[0].setup failed: operation lookup: ")
+	}
+	// the insert is persisted: the fixture is to be torn down
+	if assert.NotNil(t, fixtures.SetupUntil()) {
+		assert.Equal(t, 0, *fixtures.SetupUntil())
+	}
+	// the operations after the failed one are not sent
+	steps, err := fixtures.Steps(true)
+	assert.NoError(t, err)
+	var executed []bool
+	for _, step := range steps {
+		executed = append(executed, step.Executed)
+	}
+	assert.Equal(t, []bool{true, true, false, false}, executed)
+	remediation := fixtures.Remediation()
+	if assert.Len(t, remediation.Steps, 1) {
+		assert.Equal(t, map[string]interface{}{"xyz_id": 21.0}, remediation.Steps[0].Request.Variables)
+	}
+
+	assert.NoError(t, fixtures.Teardown(context.Background(), executor))
+	if assert.Len(t, requests, 3) {
+		assert.Equal(t, map[string]interface{}{"xyz_id": 21.0}, requests[2].Variables)
+	}
+	assert.Empty(t, fixtures.Remediation().Steps)
+}
+
+func TestNamedOperationsChecks(t *testing.T) {
+	// the unselected operation `other` inserts into def, and declares an unused variable: neither is to be reported
+	fixtures := Fixtures{
+		Fixtures: []Fixture{
+			{
+				Setup: `mutation insert { insert_xyz_one(object: { name: "xyz1" }) { id } }
+					mutation other($unused: Int) { insert_def_one(object: {}) { id } }`,
+				SetupOperations: []string{"insert"},
+			},
+		},
+		AutoTeardown: &AutoTeardown{},
+	}
+	fixtures.Parse()
+	assert.NoError(t, fixtures.parseErr)
+	assert.Empty(t, fixtures.ParseDiagnostics())
+	if assert.NotNil(t, fixtures.Fixtures[0].Teardown) {
+		assert.NotContains(t, *fixtures.Fixtures[0].Teardown, "def")
+	}
+	assert.Equal(t, map[string]string{"teardown_0_insert_xyz_one": "/data/insert_xyz_one/id"}, fixtures.Fixtures[0].Captors)
+
+	diagnostics, err := fixtures.Lint(LintOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, diagnostics)
+}
@@ -0,0 +1,73 @@
+// Package otelfixture adapts an OpenTelemetry tracer to graphqlfixture.Tracer.
+//
+//	fixtures.Tracer = otelfixture.New(otel.Tracer("my-tests"))
+//
+// It lives in its own module, so that graphqlfixture itself doesn't depend on OpenTelemetry.
+package otelfixture
+
+import (
+	"context"
+	"fmt"
+	"github.com/gmm1900/graphqlfixture"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is a graphqlfixture.Tracer backed by an OpenTelemetry tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New creates the adapter of the given OpenTelemetry tracer
+func New(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// Start starts an OpenTelemetry span (a child of the span in ctx, if any)
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...graphqlfixture.Attribute) (context.Context, graphqlfixture.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(toKeyValues(attrs)...))
+	return ctx, &Span{span: span}
+}
+
+// Span is a graphqlfixture.Span backed by an OpenTelemetry span
+type Span struct {
+	span trace.Span
+}
+
+// SetAttributes sets the attributes on the OpenTelemetry span
+func (s *Span) SetAttributes(attrs ...graphqlfixture.Attribute) {
+	s.span.SetAttributes(toKeyValues(attrs)...)
+}
+
+// RecordError records the error as an event, and sets the span status to error
+func (s *Span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End ends the OpenTelemetry span
+func (s *Span) End() {
+	s.span.End()
+}
+
+func toKeyValues(attrs []graphqlfixture.Attribute) []attribute.KeyValue {
+	keyValues := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		switch v := attr.Value.(type) {
+		case string:
+			keyValues = append(keyValues, attribute.String(attr.Key, v))
+		case bool:
+			keyValues = append(keyValues, attribute.Bool(attr.Key, v))
+		case int:
+			keyValues = append(keyValues, attribute.Int(attr.Key, v))
+		case int64:
+			keyValues = append(keyValues, attribute.Int64(attr.Key, v))
+		case float64:
+			keyValues = append(keyValues, attribute.Float64(attr.Key, v))
+		default:
+			keyValues = append(keyValues, attribute.String(attr.Key, fmt.Sprint(v)))
+		}
+	}
+	return keyValues
+}
@@ -0,0 +1,44 @@
+package otelfixture
+
+import (
+	"context"
+	"errors"
+	"github.com/gmm1900/graphqlfixture"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	// GIVEN
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := New(provider.Tracer("test"))
+
+	// WHEN
+	ctx, parent := tracer.Start(context.Background(), graphqlfixture.SpanSetup, graphqlfixture.Attr(graphqlfixture.AttrFixtureCount, 2))
+	_, child := tracer.Start(ctx, graphqlfixture.SpanFixtureSetup, graphqlfixture.Attr(graphqlfixture.AttrFixtureName, "abc"))
+	child.SetAttributes(graphqlfixture.Attr("custom", struct{ X int }{1}))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	parent.End()
+
+	// THEN
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, graphqlfixture.SpanFixtureSetup, spans[0].Name())
+		assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+		assert.Equal(t, []attribute.KeyValue{
+			attribute.String(graphqlfixture.AttrFixtureName, "abc"),
+			attribute.String("custom", "{1}"),
+		}, spans[0].Attributes())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, "boom", spans[0].Status().Description)
+
+		assert.Equal(t, graphqlfixture.SpanSetup, spans[1].Name())
+		assert.Equal(t, []attribute.KeyValue{attribute.Int(graphqlfixture.AttrFixtureCount, 2)}, spans[1].Attributes())
+	}
+}
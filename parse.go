@@ -15,7 +15,13 @@ import (
 // - no duplicates in captor names across all fixtures
 // - captor name used in a fixture's setup must already be "captured" in previous fixture's captors
 // - captor name used in a fixture's teardown must already be "captured" in previous + current fixture's captors
-// The result of parsing is in fs.parsed and fs.parseErr
+// - if fs.Schema is given, setup and teardown graphql are valid per the schema (fields, arguments, variable types ..)
+// - if fs.AutoTeardown is given, the teardown of a fixture without one is generated from the inserts of its setup
+//     (and examined like the others)
+// The result of parsing is in fs.parsed and fs.parseErr.
+// Besides, the tables inserted into (including nested inserts) that no teardown deletes from are reported as
+// diagnostics, which don't prevent the setup; see ParseDiagnostics(). So are the teardowns that, in the reverse
+// sequence, would delete rows still referenced by a foreign key (known from fs.Hasura or fs.Schema).
 func (fs *Fixtures) Parse() {
 	if fs.parsed {
 		return // no need to parse again
@@ -32,15 +38,33 @@ func (fs *Fixtures) Parse() {
 
 		// examine the setup graphql BEFORE gathering the corresponding captors
 		// as those captors are meant for extracting from setup results, they cannot be used in setup query itself.
-		variables, err := parseGraphqlForVariables(f.Setup)
+		doc, err := parseGraphql(f.Setup)
+		var steps []operationStep
+		if err == nil {
+			steps, err = operationSteps(doc, f.SetupOperations)
+		}
 		if err != nil {
 			multierr = multierror.Append(multierr,
 				fmt.Errorf("%s.setup: is invalid. %w", fixtureName, err))
-		} else if containsAll, missed := captorsContainsAllKeys(captors, variables); !containsAll {
+		} else if containsAll, missed := captorsContainsAllKeys(captors, stepsVariables(steps)); !containsAll {
 			multierr = multierror.Append(multierr,
 				fmt.Errorf("%s.setup: captors not available: %s", fixtureName, strings.Join(missed, ", ")))
 		} else {
-			fs.Fixtures[fIdx].setupVariables = variables
+			fs.Fixtures[fIdx].setupSteps = steps
+			fs.Fixtures[fIdx].setupVariables = stepsVariables(steps)
+			fs.Fixtures[fIdx].setupOperation = stepsOperation(steps)
+		}
+		if err == nil && fs.Schema != nil {
+			for _, validationErr := range fs.Schema.validate(fIdx, f.Name, PhaseSetup, f.Setup) {
+				multierr = multierror.Append(multierr, validationErr)
+			}
+		}
+		if err == nil && f.Teardown == nil && fs.AutoTeardown != nil {
+			if generateErr := fs.generateTeardown(fIdx, selectOperations(doc, f.SetupOperations)); generateErr != nil {
+				multierr = multierror.Append(multierr,
+					fmt.Errorf("%s.setup: %w", fixtureName, generateErr))
+			}
+			f = fs.Fixtures[fIdx]
 		}
 
 		// gather the fixture's captors
@@ -59,27 +83,91 @@ func (fs *Fixtures) Parse() {
 
 		// examine the teardown template AFTER gathering the corresponding captors.
 		if f.Teardown != nil {
-			variables, err := parseGraphqlForVariables(*f.Teardown)
+			doc, err := parseGraphql(*f.Teardown)
+			var steps []operationStep
+			if err == nil {
+				steps, err = operationSteps(doc, f.TeardownOperations)
+			}
 			if err != nil {
 				multierr = multierror.Append(multierr,
 					fmt.Errorf("%s.teardown: is invalid. %w", fixtureName, err))
-			} else if containsAll, missed := captorsContainsAllKeys(captors, variables); !containsAll {
+			} else if containsAll, missed := captorsContainsAllKeys(captors, stepsVariables(steps)); !containsAll {
 				multierr = multierror.Append(multierr,
 					fmt.Errorf("%s.teardown: captors not available: %s", fixtureName, strings.Join(missed, ", ")))
 			} else {
-				fs.Fixtures[fIdx].teardownVariables = variables
+				fs.Fixtures[fIdx].teardownSteps = steps
+				fs.Fixtures[fIdx].teardownVariables = stepsVariables(steps)
+				fs.Fixtures[fIdx].teardownOperation = stepsOperation(steps)
+			}
+			if err == nil && fs.Schema != nil {
+				for _, validationErr := range fs.Schema.validate(fIdx, f.Name, PhaseTeardown, *f.Teardown) {
+					multierr = multierror.Append(multierr, validationErr)
+				}
 			}
 		}
 	}
 
+	fs.parseDiagnostics = nil
+	if multierr == nil {
+		reportFixture := func(fIdx int, rule string, phase Phase, format string, args ...interface{}) {
+			fs.parseDiagnostics = fs.appendDiagnostic(fs.parseDiagnostics, defaultSeverities, fIdx, rule, phase, format, args...)
+		}
+		fs.teardownCoverage(reportFixture)
+		fs.teardownOrderViolations(reportFixture)
+	}
+
 	fs.parsed = true
 	fs.parseErr = multierr.ErrorOrNil()
 }
 
+// generateTeardown sets the teardown generated from the setup doc, and the captors it needs, into fixture[fIdx];
+// nothing if the setup inserts nothing
+func (fs *Fixtures) generateTeardown(fIdx int, setupDoc *gqlast.Document) error {
+	teardown, generatedCaptors, err := newTeardownGenerator(*fs.AutoTeardown, fs.Hasura).generate(fIdx, setupDoc)
+	if err != nil || teardown == "" {
+		return err
+	}
+	captors := map[string]string{}
+	for captorName, captorPath := range fs.Fixtures[fIdx].Captors {
+		captors[captorName] = captorPath
+	}
+	for captorName, captorPath := range generatedCaptors {
+		if _, found := captors[captorName]; found {
+			return fmt.Errorf("captor %s of the generated teardown is already declared", captorName)
+		}
+		captors[captorName] = captorPath
+	}
+	fs.Fixtures[fIdx].Captors = captors
+	fs.Fixtures[fIdx].Teardown = &teardown
+	return nil
+}
+
+// ParseDiagnostics returns the problems found by Parse() that don't prevent the setup (with the default severities;
+// see Lint for configuring them), e.g., a nested insert into a table that no teardown deletes from
+func (fs *Fixtures) ParseDiagnostics() []Diagnostic {
+	if !fs.parsed {
+		fs.Parse()
+	}
+	return fs.parseDiagnostics
+}
+
 
 // parseGraphqlForVariables parses the graphql str (hence validate its syntax) and
 // extract out the variables used in the query
 func parseGraphqlForVariables(graphqlStr string) ([]string, error) {
+	doc, err := parseGraphql(graphqlStr)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := operationSteps(doc, nil)
+	if err != nil {
+		return nil, err
+	}
+	return stepsVariables(steps), nil
+}
+
+// parseGraphql parses the graphql str into AST (hence validate its syntax)
+func parseGraphql(graphqlStr string) (*gqlast.Document, error) {
 	strippedStr := strings.ReplaceAll(strings.ReplaceAll(graphqlStr, "\n", " "), "\t", " ")
 	doc, err := gqlparser.Parse(gqlparser.ParseParams{
 		Source: &gqlsource.Source{
@@ -89,20 +177,185 @@ func parseGraphqlForVariables(graphqlStr string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse graphql error: %w", err)
 	}
+	return doc, nil
+}
 
-	var variables []string
+// operationStep is an operation of a setup or teardown graphql, sent as one request
+type operationStep struct {
+	name      string   // the operation name, sent as operationName; empty if anonymous
+	variables []string // declared by the operation
+	operation string   // "query", "mutation" or "subscription"
+}
+
+// operationSteps returns the operations of the doc to run, in sequence: the named ones, or if no names are given, the
+// whole doc as one step (with the variables of all its operations, as before operation names were supported).
+// Error if a name is not found, or no names are given for a doc of several named operations (ambiguous).
+func operationSteps(doc *gqlast.Document, names []string) ([]operationStep, error) {
+	var operations []*gqlast.OperationDefinition
 	for _, def := range doc.Definitions {
-		switch node := def.(type) {
-		case *gqlast.OperationDefinition:
-			if len(node.VariableDefinitions) > 0 {
-				for _, vDef := range node.VariableDefinitions {
-					variables = append(variables, vDef.Variable.Name.Value)
-				}
+		if node, ok := def.(*gqlast.OperationDefinition); ok {
+			operations = append(operations, node)
+		}
+	}
+	if len(names) == 0 {
+		var named []string
+		whole := operationStep{}
+		for _, operation := range operations {
+			if operation.Name != nil {
+				named = append(named, operation.Name.Value)
+			}
+			opStep := newOperationStep(operation)
+			whole.variables = append(whole.variables, opStep.variables...)
+			if whole.operation == "" || opStep.operation == "mutation" {
+				whole.operation = opStep.operation
 			}
 		}
+		if len(named) > 1 {
+			return nil, fmt.Errorf("has operations %s, select the one(s) to run by name", strings.Join(named, ", "))
+		}
+		return []operationStep{whole}, nil
 	}
 
-	return variables, nil
+	var steps []operationStep
+	for _, name := range names {
+		var found *gqlast.OperationDefinition
+		for _, operation := range operations {
+			if operation.Name != nil && operation.Name.Value == name {
+				found = operation
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("operation %s not found", name)
+		}
+		steps = append(steps, newOperationStep(found))
+	}
+	return steps, nil
+}
+
+// selectOperations returns the doc with only the named operations (and all the fragments); the doc as is if no names.
+// The checks of what the fixtures insert and delete look at the operations run only.
+func selectOperations(doc *gqlast.Document, names []string) *gqlast.Document {
+	if len(names) == 0 {
+		return doc
+	}
+	selected := map[string]bool{}
+	for _, name := range names {
+		selected[name] = true
+	}
+	selectedDoc := &gqlast.Document{Kind: doc.Kind, Loc: doc.Loc}
+	for _, def := range doc.Definitions {
+		if operation, ok := def.(*gqlast.OperationDefinition); ok && (operation.Name == nil || !selected[operation.Name.Value]) {
+			continue
+		}
+		selectedDoc.Definitions = append(selectedDoc.Definitions, def)
+	}
+	return selectedDoc
+}
+
+// fragmentsOf returns the fragment definitions of the doc, by name
+func fragmentsOf(doc *gqlast.Document) map[string]*gqlast.FragmentDefinition {
+	fragments := map[string]*gqlast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*gqlast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	return fragments
+}
+
+// flattenFields flattens the selection set into fields, expanding the inline fragments and the fragment spreads
+func flattenFields(selectionSet *gqlast.SelectionSet, fragments map[string]*gqlast.FragmentDefinition) []*gqlast.Field {
+	if selectionSet == nil {
+		return nil
+	}
+	var fields []*gqlast.Field
+	for _, selection := range selectionSet.Selections {
+		switch node := selection.(type) {
+		case *gqlast.Field:
+			fields = append(fields, node)
+		case *gqlast.InlineFragment:
+			fields = append(fields, flattenFields(node.SelectionSet, fragments)...)
+		case *gqlast.FragmentSpread:
+			if fragment, found := fragments[node.Name.Value]; found {
+				fields = append(fields, flattenFields(fragment.SelectionSet, fragments)...)
+			}
+		}
+	}
+	return fields
+}
+
+// setupDoc parses the setup, with only the operations selected by SetupOperations
+func (f Fixture) setupDoc() (*gqlast.Document, error) {
+	doc, err := parseGraphql(f.Setup)
+	if err != nil {
+		return nil, err
+	}
+	return selectOperations(doc, f.SetupOperations), nil
+}
+
+// teardownDoc parses the teardown (not nil), with only the operations selected by TeardownOperations
+func (f Fixture) teardownDoc() (*gqlast.Document, error) {
+	doc, err := parseGraphql(*f.Teardown)
+	if err != nil {
+		return nil, err
+	}
+	return selectOperations(doc, f.TeardownOperations), nil
+}
+
+// newOperationStep returns the step of the operation
+func newOperationStep(operation *gqlast.OperationDefinition) operationStep {
+	step := operationStep{operation: operation.Operation}
+	if operation.Name != nil {
+		step.name = operation.Name.Value
+	}
+	for _, vDef := range operation.VariableDefinitions {
+		step.variables = append(step.variables, vDef.Variable.Name.Value)
+	}
+	return step
+}
+
+// setupOperationSteps returns the setup steps; the whole setup as one step if not parsed into steps
+func (f Fixture) setupOperationSteps() []operationStep {
+	if f.setupSteps == nil {
+		return []operationStep{{variables: f.setupVariables, operation: f.setupOperation}}
+	}
+	return f.setupSteps
+}
+
+// teardownOperationSteps returns the teardown steps; the whole teardown as one step if not parsed into steps
+func (f Fixture) teardownOperationSteps() []operationStep {
+	if f.teardownSteps == nil {
+		return []operationStep{{variables: f.teardownVariables, operation: f.teardownOperation}}
+	}
+	return f.teardownSteps
+}
+
+// stepsVariables returns the variables of all the steps, without duplicates
+func stepsVariables(steps []operationStep) []string {
+	var variables []string
+	seen := map[string]bool{}
+	for _, step := range steps {
+		for _, varName := range step.variables {
+			if !seen[varName] {
+				seen[varName] = true
+				variables = append(variables, varName)
+			}
+		}
+	}
+	return variables
+}
+
+// stepsOperation returns the operation type of the steps: "mutation" if any is, otherwise that of the first
+func stepsOperation(steps []operationStep) string {
+	for _, step := range steps {
+		if step.operation == "mutation" {
+			return step.operation
+		}
+	}
+	if len(steps) == 0 {
+		return ""
+	}
+	return steps[0].operation
 }
 
 func captorsContainsAllKeys(captors map[string]int, keys []string) (bool, []string) {
@@ -0,0 +1,69 @@
+package graphqlfixture
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReadyOptions configures WaitReady
+type ReadyOptions struct {
+	Query     string                       // the health query to probe with. Default `query { __typename }`, which any graphql server can answer.
+	Interval  time.Duration                // wait between probes. Default 1s.
+	Timeout   time.Duration                // optional: give up after this long (in addition to the ctx deadline). 0 means rely on ctx only.
+	Retryable func(err error) bool         // decides if the probe error means "not ready yet". Default IsTransientError (connection refused ..) or any http status 5xx.
+	Progress  func(attempt int, err error) // optional: called after each failed probe that will be retried, e.g., for logging
+}
+
+const (
+	defaultReadyQuery    = `query { __typename }`
+	defaultReadyInterval = time.Second
+)
+
+// WaitReady probes the graphql server until it answers the health query, e.g., to wait for the graphql server
+// started by docker-compose before setting up the fixtures.
+// Returns nil once the server is ready; returns error if the probe fails with an error that's not retryable
+// (e.g., the health query is invalid), or the ctx is done (or opts.Timeout reached) before the server is ready.
+func WaitReady(ctx context.Context, executor Executor, opts ReadyOptions) error {
+	query := opts.Query
+	if query == "" {
+		query = defaultReadyQuery
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultReadyInterval
+	}
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = isNotReady
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, err := doGraphqlRequest(ctx, executor, query, "", nil, nil)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("graphql server not ready after %d attempt(s): %w", attempt, err)
+		}
+		if !retryable(err) {
+			return fmt.Errorf("graphql server readiness probe failed: %w", err)
+		}
+		if opts.Progress != nil {
+			opts.Progress(attempt, err)
+		}
+		if sleepErr := sleepCtx(ctx, interval); sleepErr != nil {
+			return fmt.Errorf("graphql server not ready after %d attempt(s): %v: %w", attempt, err, sleepErr)
+		}
+	}
+}
+
+// isNotReady is the default ReadyOptions.Retryable: a transient error, or any 5xx (e.g., 500 while the server boots)
+func isNotReady(err error) bool {
+	return IsTransientError(err) || HTTPStatusCode(err) >= 500
+}
@@ -0,0 +1,86 @@
+package graphqlfixture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWaitReady(t *testing.T) {
+	connRefused := fmt.Errorf("error sending request: %w", syscall.ECONNREFUSED)
+	badGateway := errors.New(`bad response status code: 502 Bad Gateway body: ""`)
+
+	testCases := []struct {
+		name             string
+		givenResponses   []error // nil = respond successfully
+		givenOpts        ReadyOptions
+		expectedErr      string
+		expectedProgress []int
+	}{
+		{
+			name:             "ready after connection refused and bad gateway",
+			givenResponses:   []error{connRefused, badGateway, nil},
+			givenOpts:        ReadyOptions{Interval: time.Millisecond},
+			expectedProgress: []int{1, 2},
+		},
+		{
+			name:             "ready after internal server error",
+			givenResponses:   []error{errors.New(`bad response status code: 500 Internal Server Error body: ""`), nil},
+			givenOpts:        ReadyOptions{Interval: time.Millisecond},
+			expectedProgress: []int{1},
+		},
+		{
+			name:             "non retryable error",
+			givenResponses:   []error{connRefused, errors.New(`bad response status code: 401 Unauthorized body: ""`)},
+			givenOpts:        ReadyOptions{Interval: time.Millisecond},
+			expectedErr:      `graphql server readiness probe failed: graphql request failed: bad response status code: 401 Unauthorized body: ""`,
+			expectedProgress: []int{1},
+		},
+		{
+			name:             "timeout",
+			givenResponses:   nil, // always refused
+			givenOpts:        ReadyOptions{Interval: time.Hour, Timeout: 20 * time.Millisecond},
+			expectedErr:      "graphql server not ready after 1 attempt(s): graphql request failed: error sending request: connection refused: context deadline exceeded",
+			expectedProgress: []int{1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			calls := 0
+			executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+				assert.Equal(t, `query { __typename }`, req.Query)
+				calls++
+				if tc.givenResponses == nil {
+					return connRefused
+				}
+				if err := tc.givenResponses[calls-1]; err != nil {
+					return err
+				}
+				*resp.(*[]byte) = []byte(`{ "data": { "__typename": "query_root" } }`)
+				return nil
+			})
+			var progress []int
+			tc.givenOpts.Progress = func(attempt int, err error) {
+				progress = append(progress, attempt)
+			}
+
+			// WHEN
+			err := WaitReady(context.Background(), executor, tc.givenOpts)
+
+			// THEN
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+			assert.Equal(t, tc.expectedProgress, progress)
+		})
+	}
+}
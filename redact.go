@@ -0,0 +1,201 @@
+package graphqlfixture
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces the sensitive values in logs, errors, reports and exports
+const Redacted = "***"
+
+// DefaultSecretHeaders are the headers masked when Redaction.Headers is not given
+var DefaultSecretHeaders = []string{"x-hasura-admin-secret", "authorization", "cookie"}
+
+// Redaction marks what's sensitive, to be masked consistently in Logs()/Events(), the errors returned by
+// Setup/Teardown (and recorded in the spans), Report() and the exports (ExportCurl, ExportHTTP).
+// Remediation() is not redacted: it needs the real values to finish the cleanup.
+type Redaction struct {
+	Headers   []string // names of the request headers (case-insensitive) whose values are masked. Nil means DefaultSecretHeaders.
+	Variables []string // names of the graphql variables whose values are masked
+	Captors   []string // names of the captors whose captured values are masked (wherever they show up in text too, if string)
+	Values    []string // literal secret values, e.g., the admin secret, masked wherever they show up in text
+}
+
+// isSecretHeader returns whether the header is marked sensitive
+func (r *Redaction) isSecretHeader(name string) bool {
+	headers := DefaultSecretHeaders
+	if r != nil && r.Headers != nil {
+		headers = r.Headers
+	}
+	for _, header := range headers {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecretVariable returns whether the variable (or captor, since variables are named after captors) is marked sensitive
+func (r *Redaction) isSecretVariable(name string) bool {
+	if r == nil {
+		return false
+	}
+	for _, names := range [][]string{r.Variables, r.Captors} {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RedactHeader returns a copy of the header with the sensitive values masked
+func (r *Redaction) RedactHeader(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+	redacted := http.Header{}
+	for name, values := range header {
+		if r.isSecretHeader(name) {
+			masked := make([]string, len(values))
+			for i := range masked {
+				masked[i] = Redacted
+			}
+			values = masked
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+// RedactVariables returns a copy of the graphql variables (or captured values) with the sensitive values masked
+func (r *Redaction) RedactVariables(variables map[string]interface{}) map[string]interface{} {
+	if variables == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(variables))
+	for name, value := range variables {
+		if r.isSecretVariable(name) && value != nil {
+			value = Redacted
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// redactText masks the secret values (Values, and the string leaves of the sensitive captured values) in s.
+// Number values are not masked in text, as they would match too much.
+func (fs *Fixtures) redactText(s string) string {
+	r := fs.Redaction
+	if r == nil {
+		return s
+	}
+	secrets := append([]string{}, r.Values...)
+	for name, value := range fs.captured {
+		if r.isSecretVariable(name) {
+			secrets = appendStringLeaves(secrets, value)
+		}
+	}
+	// longer first, in case a secret contains another
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	return s
+}
+
+// appendStringLeaves appends the strings found in the (json-decoded) value
+func appendStringLeaves(leaves []string, value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		leaves = append(leaves, v)
+	case map[string]interface{}:
+		for _, elem := range v {
+			leaves = appendStringLeaves(leaves, elem)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			leaves = appendStringLeaves(leaves, elem)
+		}
+	}
+	return leaves
+}
+
+// redactValue returns the (json-decoded) value with the secret values masked in its strings
+func (fs *Fixtures) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fs.redactText(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			redacted[key] = fs.redactValue(elem)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, elem := range v {
+			redacted[i] = fs.redactValue(elem)
+		}
+		return redacted
+	}
+	return value
+}
+
+// redactedError has the message redacted, and unwraps to the cause (redacted too) for errors.Is / errors.As
+type redactedError struct {
+	msg   string
+	cause error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.cause }
+
+// redactError masks the secret values in the error message (if any), and in the errors it wraps: a *ResponseError
+// or *GraphQLError in the chain is replaced by a redacted copy, so errors.As doesn't give the secrets back
+func (fs *Fixtures) redactError(err error) error {
+	redacted, _ := fs.redactErrorChain(err)
+	return redacted
+}
+
+// redactErrorChain returns the redacted err, and whether anything has been masked (otherwise err as is)
+func (fs *Fixtures) redactErrorChain(err error) (error, bool) {
+	if err == nil || fs.Redaction == nil {
+		return err, false
+	}
+	switch e := err.(type) {
+	case *redactedError:
+		return e, false
+	case *GraphQLError:
+		redacted := &GraphQLError{Message: fs.redactText(e.Message), Path: e.Path, Locations: e.Locations}
+		if e.Extensions != nil {
+			redacted.Extensions = fs.redactValue(e.Extensions).(map[string]interface{})
+		}
+		if reflect.DeepEqual(redacted, e) {
+			return e, false
+		}
+		return redacted, true
+	case *ResponseError:
+		redacted := &ResponseError{Raw: fs.redactValue(e.Raw)}
+		for _, gqlErr := range e.Errors {
+			redactedGqlErr, _ := fs.redactErrorChain(gqlErr)
+			redacted.Errors = append(redacted.Errors, redactedGqlErr.(*GraphQLError))
+		}
+		if reflect.DeepEqual(redacted, e) {
+			return e, false
+		}
+		return redacted, true
+	}
+	cause, causeRedacted := fs.redactErrorChain(errors.Unwrap(err))
+	msg := fs.redactText(err.Error())
+	if !causeRedacted && msg == err.Error() {
+		return err, false
+	}
+	return &redactedError{msg: msg, cause: cause}, true
+}
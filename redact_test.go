@@ -0,0 +1,105 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	// GIVEN: fixture[0] captures a sensitive api key, fixture[1] fails with an error echoing the api key
+	responses := [][]byte{
+		[]byte(`{ "data": { "insert_api_keys_one": { "id": 13, "key": "s3cr3t-key" } } }`),
+		[]byte(`{ "errors": [ { "message": "invalid key s3cr3t-key for adminsecret", "extensions": { "code": "validation-failed" } } ] }`),
+	}
+	calls := 0
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = responses[calls]
+		calls++
+		return nil
+	})
+	tracer := &recordingTracer{}
+	fixtures := Fixtures{
+		Tracer: tracer,
+		Redaction: &Redaction{
+			Captors: []string{"api_key"},
+			Values:  []string{"adminsecret"},
+		},
+		Fixtures: []Fixture{
+			{
+				Setup: `mutation { insert_api_keys_one(object: {}) { id key } }`,
+				Captors: map[string]string{
+					"api_key_id": "/data/insert_api_keys_one/id",
+					"api_key":    "/data/insert_api_keys_one/key",
+				},
+				Teardown: gopointer.OfString(`mutation ($api_key_id: Int!, $api_key: String!) { delete_api_keys(where: { id: { _eq: $api_key_id }, key: { _eq: $api_key } }) { affected_rows } }`),
+			},
+			{
+				Setup: `mutation ($api_key: String!) { insert_abc(objects: { key: $api_key }) { affected_rows } }`,
+			},
+		},
+	}
+
+	// WHEN
+	err := fixtures.Setup(context.Background(), executor)
+
+	// THEN
+	// errors and logs
+	assert.EqualError(t, err, "fixture[1].setup failed: graphql response contains error: [map[extensions:map[code:validation-failed] message:invalid key *** for ***]]")
+	assert.True(t, IsValidationFailed(err)) // still unwraps to the (redacted) original error
+	var respErr *ResponseError
+	if assert.True(t, errors.As(err, &respErr)) {
+		assert.Equal(t, "invalid key *** for ***", respErr.Errors[0].Message)
+		assert.NotContains(t, respErr.Error(), "s3cr3t-key")
+	}
+	var gqlErr *GraphQLError
+	if assert.True(t, errors.As(err, &gqlErr)) {
+		assert.Equal(t, "invalid key *** for ***", gqlErr.Message)
+	}
+	// spans
+	assert.NotEmpty(t, tracer.errors)
+	for _, spanErr := range tracer.errors {
+		assert.NotContains(t, spanErr, "s3cr3t-key")
+		assert.NotContains(t, spanErr, "adminsecret")
+	}
+	assert.Equal(t, "fixture[1].setup failed: graphql response contains error: [map[extensions:map[code:validation-failed] message:invalid key *** for ***]]",
+		fixtures.Logs()[len(fixtures.Logs())-1])
+	assert.Equal(t, "graphql response contains error: [map[extensions:map[code:validation-failed] message:invalid key *** for ***]]",
+		fixtures.Events()[len(fixtures.Events())-1].Error)
+
+	// report
+	assert.Equal(t, map[string]interface{}{"api_key_id": 13.0, "api_key": Redacted}, fixtures.Report().Leftovers[0].Captured)
+
+	// export
+	var buf bytes.Buffer
+	assert.NoError(t, fixtures.ExportHTTP(&buf, ExportOptions{
+		URL:     "http://localhost:8080/v1/graphql",
+		Headers: http.Header{"X-Hasura-Admin-Secret": []string{"adminsecret"}, "X-Hasura-Role": []string{"admin"}},
+	}))
+	assert.Equal(t, `### fixture[0].setup (executed)
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+X-Hasura-Admin-Secret: ***
+X-Hasura-Role: admin
+
+{"query":"mutation { insert_api_keys_one(object: {}) { id key } }"}
+
+### fixture[1].setup (executed)
+POST http://localhost:8080/v1/graphql
+Content-Type: application/json
+X-Hasura-Admin-Secret: ***
+X-Hasura-Role: admin
+
+{"query":"mutation ($api_key: String!) { insert_abc(objects: { key: $api_key }) { affected_rows } }","variables":{"api_key":"***"}}
+
+`, buf.String())
+
+	// remediation keeps the real values, to be able to finish the cleanup
+	assert.Equal(t, map[string]interface{}{"api_key_id": 13.0, "api_key": "s3cr3t-key"},
+		fixtures.Remediation().Steps[0].Request.Variables)
+}
@@ -0,0 +1,114 @@
+package graphqlfixture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/hashicorp/go-multierror"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// Remediation lists the outstanding teardowns of a fixture run that didn't (completely) tear down, with the
+// captured variables already bound, so that the cleanup can be finished later with Run (or the CLI `cleanup` command).
+type Remediation struct {
+	Steps []RemediationStep `json:"steps"` // in the order to run
+}
+
+// RemediationStep is an outstanding teardown request
+type RemediationStep struct {
+	FixtureIdx  int                   `json:"fixtureIdx"`
+	FixtureName string                `json:"fixtureName,omitempty"`
+	Request     graphqlclient.Request `json:"request"`
+	Unresolved  []string              `json:"unresolved,omitempty"` // variables not captured; the request may not work as is
+}
+
+// Remediation returns the teardowns not done yet for the fixtures that have been setup, in the order of Teardown
+// (reverse sequence, unless OrderTeardownByForeignKeys). Empty if everything setup has been torn down.
+func (fs *Fixtures) Remediation() *Remediation {
+	remediation := &Remediation{Steps: []RemediationStep{}}
+	for _, fIdx := range fs.leftovers() {
+		f := fs.Fixtures[fIdx]
+		for _, opStep := range f.teardownOperationSteps() { // one request per operation
+			step := RemediationStep{
+				FixtureIdx:  fIdx,
+				FixtureName: f.Name,
+				Request:     graphqlclient.Request{Query: *f.Teardown, OperationName: opStep.name},
+			}
+			if len(opStep.variables) > 0 {
+				step.Request.Variables = map[string]interface{}{}
+				for _, varName := range opStep.variables {
+					varVal, found := fs.captured[varName]
+					if !found {
+						step.Unresolved = append(step.Unresolved, varName)
+					}
+					step.Request.Variables[varName] = varVal
+				}
+			}
+			remediation.Steps = append(remediation.Steps, step)
+		}
+	}
+	return remediation
+}
+
+// WriteJSON writes the remediation as indented JSON
+func (r *Remediation) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// ReadRemediation reads the remediation written by WriteJSON
+func ReadRemediation(reader io.Reader) (*Remediation, error) {
+	var remediation Remediation
+	if err := json.NewDecoder(reader).Decode(&remediation); err != nil {
+		return nil, fmt.Errorf("fail to decode remediation: %w", err)
+	}
+	return &remediation, nil
+}
+
+// Run sends each outstanding teardown request. Unlike Teardown, it doesn't stop at the first failure,
+// to clean up as much as possible. Returns the steps that still failed (empty if all done), and their errors.
+func (r *Remediation) Run(ctx context.Context, executor Executor) (*Remediation, error) {
+	remaining := &Remediation{Steps: []RemediationStep{}}
+	var multierr *multierror.Error
+	for _, step := range r.Steps {
+		var varNames []string
+		for varName := range step.Request.Variables {
+			varNames = append(varNames, varName)
+		}
+		sort.Strings(varNames)
+		_, err := doGraphqlRequest(ctx, executor, step.Request.Query, step.Request.OperationName, varNames, step.Request.Variables)
+		if err != nil {
+			remaining.Steps = append(remaining.Steps, step)
+			multierr = multierror.Append(multierr, fmt.Errorf("fixture[%d].teardown failed: %w", step.FixtureIdx, err))
+		}
+	}
+	return remaining, multierr.ErrorOrNil()
+}
+
+// writeRemediationFile keeps fs.RemediationFile in sync with the outstanding teardowns:
+// written while there are any, removed once there are none.
+func (fs *Fixtures) writeRemediationFile() error {
+	if fs.RemediationFile == "" {
+		return nil
+	}
+	remediation := fs.Remediation()
+	if len(remediation.Steps) == 0 {
+		if err := os.Remove(fs.RemediationFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("fail to remove remediation file: %w", err)
+		}
+		return nil
+	}
+	jsonBytes, err := json.MarshalIndent(remediation, "", "  ")
+	if err != nil {
+		return fmt.Errorf("fail to encode remediation: %w", err)
+	}
+	if err := ioutil.WriteFile(fs.RemediationFile, jsonBytes, 0644); err != nil {
+		return fmt.Errorf("fail to write remediation file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"context"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemediation(t *testing.T) {
+	// GIVEN: both fixtures setup; teardown of fixture[1] succeeds, fixture[0] fails
+	responses := [][]byte{
+		[]byte(`{ "data": { "insert_abc": { "returning": [ { "id": 13 } ] } } }`),
+		[]byte(`{ "data": { "insert_xyz": { "returning": [ { "id": 21 } ] } } }`),
+		[]byte(`{ "data": { "delete_xyz": { "affected_rows": 1 } } }`),
+		[]byte(`{ "errors": [ { "message": "boom" } ] }`),
+	}
+	var requests []graphqlclient.Request
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = responses[len(requests)]
+		requests = append(requests, req)
+		return nil
+	})
+	dir, err := ioutil.TempDir("", "remediation")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	remediationFile := filepath.Join(dir, "remediation.json")
+	fixtures := Fixtures{
+		RemediationFile: remediationFile,
+		Fixtures: []Fixture{
+			{
+				Name:     "abc",
+				Setup:    `mutation { insert_abc(objects: {}) { returning { id } } }`,
+				Captors:  map[string]string{"abc_id": "/data/insert_abc/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+			},
+			{
+				Setup:    `mutation ($abc_id: Int!) { insert_xyz(objects: { abc_id: $abc_id }) { returning { id } } }`,
+				Captors:  map[string]string{"xyz_id": "/data/insert_xyz/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($xyz_id: Int!) { delete_xyz(where: { id: { _eq: $xyz_id } }) { affected_rows } }`),
+			},
+		},
+	}
+
+	// after setup: both teardowns outstanding
+	assert.NoError(t, fixtures.Setup(context.Background(), executor))
+	expectedAfterSetup := &Remediation{Steps: []RemediationStep{
+		{FixtureIdx: 1, Request: graphqlclient.Request{Query: *fixtures.Fixtures[1].Teardown, Variables: map[string]interface{}{"xyz_id": 21.0}}},
+		{FixtureIdx: 0, FixtureName: "abc", Request: graphqlclient.Request{Query: *fixtures.Fixtures[0].Teardown, Variables: map[string]interface{}{"abc_id": 13.0}}},
+	}}
+	assert.Equal(t, expectedAfterSetup, fixtures.Remediation())
+	assert.Equal(t, expectedAfterSetup, readRemediationFile(t, remediationFile))
+
+	// after failed teardown: only fixture[0] outstanding
+	assert.Error(t, fixtures.Teardown(context.Background(), executor))
+	expectedAfterTeardown := &Remediation{Steps: expectedAfterSetup.Steps[1:]}
+	assert.Equal(t, expectedAfterTeardown, fixtures.Remediation())
+	assert.Equal(t, expectedAfterTeardown, readRemediationFile(t, remediationFile))
+
+	// WHEN: run the remediation later
+	responses = append(responses, []byte(`{ "data": { "delete_abc": { "affected_rows": 1 } } }`))
+	remaining, err := readRemediationFile(t, remediationFile).Run(context.Background(), executor)
+
+	// THEN
+	assert.NoError(t, err)
+	assert.Empty(t, remaining.Steps)
+	assert.Equal(t, graphqlclient.Request{
+		Query:     *fixtures.Fixtures[0].Teardown,
+		Variables: map[string]interface{}{"abc_id": 13.0},
+	}, requests[len(requests)-1])
+}
+
+func TestRemediationRunKeepsFailedSteps(t *testing.T) {
+	remediation := &Remediation{Steps: []RemediationStep{
+		{FixtureIdx: 1, Request: graphqlclient.Request{Query: `mutation { delete_xyz(where: {}) { affected_rows } }`}},
+		{FixtureIdx: 0, Request: graphqlclient.Request{Query: `mutation { delete_abc(where: {}) { affected_rows } }`}},
+	}}
+	calls := 0
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		calls++
+		if calls == 1 {
+			*resp.(*[]byte) = []byte(`{ "errors": [ { "message": "boom" } ] }`)
+		} else {
+			*resp.(*[]byte) = []byte(`{ "data": {} }`)
+		}
+		return nil
+	})
+
+	remaining, err := remediation.Run(context.Background(), executor)
+
+	assert.EqualError(t, err, "1 error occurred:\n\t* fixture[1].teardown failed: graphql response contains error: [map[message:boom]]\n\n")
+	assert.Equal(t, 2, calls) // not stopped at the first failure
+	assert.Equal(t, remediation.Steps[:1], remaining.Steps)
+}
+
+func readRemediationFile(t *testing.T, path string) *Remediation {
+	jsonBytes, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	remediation, err := ReadRemediation(bytes.NewReader(jsonBytes))
+	assert.NoError(t, err)
+	return remediation
+}
@@ -0,0 +1,217 @@
+package graphqlfixture
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// StatusNotAttempted is used in reports for the fixture phases that never ran, e.g., setup aborted before reaching the fixture
+const StatusNotAttempted Status = "not-attempted"
+
+// Report is a machine-readable summary of a fixture run (setup and teardown), for CI dashboards.
+// It can be written as JSON (and read back with ReadReport), or as JUnit XML.
+type Report struct {
+	SetupUntil    *int            `json:"setupUntil"`    // see Fixtures.SetupUntil()
+	TeardownUntil *int            `json:"teardownUntil"` // see Fixtures.TeardownUntil()
+	Fixtures      []FixtureReport `json:"fixtures"`
+	Leftovers     []Leftover      `json:"leftovers,omitempty"` // data that was setup but not torn down
+	Events        []Event         `json:"events"`              // the full structured log
+}
+
+// FixtureReport is the outcome of each phase of a fixture
+type FixtureReport struct {
+	FixtureIdx  int           `json:"fixtureIdx"`
+	FixtureName string        `json:"fixtureName,omitempty"`
+	Phases      []PhaseReport `json:"phases"`
+}
+
+// PhaseReport is the outcome of a phase of a fixture. Retry attempts are folded into the phase.
+type PhaseReport struct {
+	Phase    Phase         `json:"phase"`
+	Status   Status        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Attempts int           `json:"attempts,omitempty"` // number of attempts, if retried
+	Error    string        `json:"error,omitempty"`
+}
+
+// Leftover is a fixture that was setup but not torn down: its data is likely still in the db
+type Leftover struct {
+	FixtureIdx  int                    `json:"fixtureIdx"`
+	FixtureName string                 `json:"fixtureName,omitempty"`
+	Teardown    *string                `json:"teardown"`           // the teardown graphql not (successfully) run
+	Captured    map[string]interface{} `json:"captured,omitempty"` // values captured by the fixture, e.g., IDs of the data left (sensitive ones redacted)
+}
+
+// Report summarizes the fixture run so far
+func (fs *Fixtures) Report() *Report {
+	report := &Report{
+		SetupUntil:    fs.setupUntilIdx,
+		TeardownUntil: fs.teardownUntilIdx,
+		Events:        fs.events,
+	}
+
+	// collect the phases from the events, in the order they happened
+	phases := make([][]PhaseReport, len(fs.Fixtures))
+	for _, e := range fs.events {
+		if e.FixtureIdx < 0 || e.FixtureIdx >= len(fs.Fixtures) {
+			continue
+		}
+		fixturePhases := phases[e.FixtureIdx]
+		idx := -1
+		for i := range fixturePhases {
+			if fixturePhases[i].Phase == e.Phase {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			fixturePhases = append(fixturePhases, PhaseReport{Phase: e.Phase})
+			idx = len(fixturePhases) - 1
+		}
+		phase := &fixturePhases[idx]
+		switch e.Status {
+		case StatusAttemptFailed, StatusAttemptSucceeded:
+			phase.Attempts = e.Attempt
+			phase.Status, phase.Error = e.Status, e.Error // overwritten by the final event of the phase, if any
+		default:
+			phase.Status, phase.Duration, phase.Error = e.Status, e.Duration, e.Error
+		}
+		phases[e.FixtureIdx] = fixturePhases
+	}
+
+	leftovers := map[int]bool{}
+	for _, fIdx := range fs.leftovers() {
+		leftovers[fIdx] = true
+	}
+	for fIdx, f := range fs.Fixtures {
+		fixtureReport := FixtureReport{FixtureIdx: fIdx, FixtureName: f.Name, Phases: phases[fIdx]}
+		// the main phases are always reported, even if they never ran
+		for _, phase := range []Phase{PhaseSetup, PhaseCaptors, PhaseTeardown} {
+			found := false
+			for _, p := range fixtureReport.Phases {
+				found = found || p.Phase == phase
+			}
+			if !found {
+				fixtureReport.Phases = append(fixtureReport.Phases, PhaseReport{Phase: phase, Status: StatusNotAttempted})
+			}
+		}
+		report.Fixtures = append(report.Fixtures, fixtureReport)
+
+		if leftovers[fIdx] {
+			report.Leftovers = append(report.Leftovers, Leftover{
+				FixtureIdx:  fIdx,
+				FixtureName: f.Name,
+				Teardown:    f.Teardown,
+				Captured:    fs.Redaction.RedactVariables(fs.capturedBy(fIdx)),
+			})
+		}
+	}
+	return report
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// ReadReport reads the report written by WriteJSON
+func ReadReport(reader io.Reader) (*Report, error) {
+	var report Report
+	if err := json.NewDecoder(reader).Decode(&report); err != nil {
+		return nil, fmt.Errorf("fail to decode report: %w", err)
+	}
+	return &report, nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML: one testcase per fixture phase (classname = fixture, name = phase),
+// plus a "leftovers" testcase that fails if any data was setup but not torn down.
+func (r *Report) WriteJUnit(w io.Writer, suiteName string) error {
+	suite := junitTestSuite{Name: suiteName}
+	var total time.Duration
+	for _, f := range r.Fixtures {
+		className := fmt.Sprintf("fixture[%d]", f.FixtureIdx)
+		if f.FixtureName != "" {
+			className += " " + f.FixtureName
+		}
+		for _, p := range f.Phases {
+			testCase := junitTestCase{ClassName: className, Name: string(p.Phase), Time: junitSeconds(p.Duration)}
+			switch p.Status {
+			case StatusFailed, StatusAttemptFailed:
+				testCase.Failure = &junitMessage{Message: p.Error, Content: p.Error}
+				suite.Failures++
+			case StatusSkipped:
+				testCase.Skipped = &junitMessage{Message: "not exist"}
+				suite.Skipped++
+			case StatusNotAttempted:
+				testCase.Skipped = &junitMessage{Message: "not attempted"}
+				suite.Skipped++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+			total += p.Duration
+		}
+	}
+
+	leftoversCase := junitTestCase{ClassName: suiteName, Name: "leftovers", Time: junitSeconds(0)}
+	if len(r.Leftovers) > 0 {
+		var lines []string
+		for _, leftover := range r.Leftovers {
+			capturedJSON, _ := json.Marshal(leftover.Captured)
+			lines = append(lines, fmt.Sprintf("fixture[%d]: captured %s", leftover.FixtureIdx, capturedJSON))
+		}
+		leftoversCase.Failure = &junitMessage{
+			Message: fmt.Sprintf("%d fixture(s) not torn down", len(r.Leftovers)),
+			Content: strings.Join(lines, "\n"),
+		}
+		suite.Failures++
+	}
+	suite.TestCases = append(suite.TestCases, leftoversCase)
+	suite.Tests = len(suite.TestCases)
+	suite.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return fmt.Errorf("fail to encode junit xml: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,128 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"github.com/gmm1900/gopointer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// newReportFixtures returns fixtures as if fixture[0..1] were setup, and teardown failed at fixture[0]
+func newReportFixtures() Fixtures {
+	at := time.Date(2021, 2, 14, 16, 40, 28, 0, time.UTC)
+	return Fixtures{
+		Fixtures: []Fixture{
+			{
+				Name:     "abc",
+				Setup:    `mutation { insert_abc(objects: {}) { returning { id } } }`,
+				Captors:  map[string]string{"abc_id": "/data/insert_abc/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+			},
+			{
+				Setup:    `query { abc { id } }`,
+				Teardown: nil,
+			},
+			{
+				Setup: `mutation { insert_xyz(objects: {}) { affected_rows } }`,
+			},
+		},
+		parsed:           true,
+		captured:         map[string]interface{}{"abc_id": 13.0},
+		setupUntilIdx:    gopointer.OfInt(1),
+		teardownUntilIdx: nil,
+		events: []Event{
+			{Time: at, FixtureIdx: 0, FixtureName: "abc", Phase: PhaseSetup, Status: StatusCompleted, Duration: 20 * time.Millisecond, Operation: "mutation"},
+			{Time: at, FixtureIdx: 0, FixtureName: "abc", Phase: PhaseCaptors, Status: StatusCompleted, Captures: 1},
+			{Time: at, FixtureIdx: 1, Phase: PhaseSetup, Status: StatusAttemptFailed, Attempt: 1, MaxAttempts: 2, WillRetry: true, Error: "connection refused"},
+			{Time: at, FixtureIdx: 1, Phase: PhaseSetup, Status: StatusAttemptSucceeded, Attempt: 2, MaxAttempts: 2},
+			{Time: at, FixtureIdx: 1, Phase: PhaseSetup, Status: StatusCompleted, Duration: 1500 * time.Millisecond, Operation: "query"},
+			{Time: at, FixtureIdx: 1, Phase: PhaseCaptors, Status: StatusSkipped},
+			{Time: at, FixtureIdx: 2, Phase: PhaseBeforeSetup, Status: StatusFailed, Error: "aborted by hook: boom"},
+			{Time: at, FixtureIdx: 1, Phase: PhaseTeardown, Status: StatusSkipped},
+			{Time: at, FixtureIdx: 0, FixtureName: "abc", Phase: PhaseTeardown, Status: StatusFailed, Duration: 5 * time.Millisecond, Error: "graphql request failed: timeout"},
+		},
+	}
+}
+
+func TestReport(t *testing.T) {
+	fixtures := newReportFixtures()
+
+	report := fixtures.Report()
+
+	assert.Equal(t, []FixtureReport{
+		{FixtureIdx: 0, FixtureName: "abc", Phases: []PhaseReport{
+			{Phase: PhaseSetup, Status: StatusCompleted, Duration: 20 * time.Millisecond},
+			{Phase: PhaseCaptors, Status: StatusCompleted},
+			{Phase: PhaseTeardown, Status: StatusFailed, Duration: 5 * time.Millisecond, Error: "graphql request failed: timeout"},
+		}},
+		{FixtureIdx: 1, Phases: []PhaseReport{
+			{Phase: PhaseSetup, Status: StatusCompleted, Duration: 1500 * time.Millisecond, Attempts: 2},
+			{Phase: PhaseCaptors, Status: StatusSkipped},
+			{Phase: PhaseTeardown, Status: StatusSkipped},
+		}},
+		{FixtureIdx: 2, Phases: []PhaseReport{
+			{Phase: PhaseBeforeSetup, Status: StatusFailed, Error: "aborted by hook: boom"},
+			{Phase: PhaseSetup, Status: StatusNotAttempted},
+			{Phase: PhaseCaptors, Status: StatusNotAttempted},
+			{Phase: PhaseTeardown, Status: StatusNotAttempted},
+		}},
+	}, report.Fixtures)
+	assert.Equal(t, []Leftover{
+		{
+			FixtureIdx:  0,
+			FixtureName: "abc",
+			Teardown:    gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+			Captured:    map[string]interface{}{"abc_id": 13.0},
+		},
+	}, report.Leftovers)
+
+	// JSON round trip
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteJSON(&buf))
+	readReport, err := ReadReport(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, report, readReport)
+}
+
+func TestReportWriteJUnit(t *testing.T) {
+	fixtures := newReportFixtures()
+
+	var buf bytes.Buffer
+	err := fixtures.Report().WriteJUnit(&buf, "fixtures")
+
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="fixtures" tests="11" failures="3" skipped="5" time="1.525">
+    <testcase classname="fixture[0] abc" name="setup" time="0.020"></testcase>
+    <testcase classname="fixture[0] abc" name="captors" time="0.000"></testcase>
+    <testcase classname="fixture[0] abc" name="teardown" time="0.005">
+      <failure message="graphql request failed: timeout">graphql request failed: timeout</failure>
+    </testcase>
+    <testcase classname="fixture[1]" name="setup" time="1.500"></testcase>
+    <testcase classname="fixture[1]" name="captors" time="0.000">
+      <skipped message="not exist"></skipped>
+    </testcase>
+    <testcase classname="fixture[1]" name="teardown" time="0.000">
+      <skipped message="not exist"></skipped>
+    </testcase>
+    <testcase classname="fixture[2]" name="before-setup" time="0.000">
+      <failure message="aborted by hook: boom">aborted by hook: boom</failure>
+    </testcase>
+    <testcase classname="fixture[2]" name="setup" time="0.000">
+      <skipped message="not attempted"></skipped>
+    </testcase>
+    <testcase classname="fixture[2]" name="captors" time="0.000">
+      <skipped message="not attempted"></skipped>
+    </testcase>
+    <testcase classname="fixture[2]" name="teardown" time="0.000">
+      <skipped message="not attempted"></skipped>
+    </testcase>
+    <testcase classname="fixtures" name="leftovers" time="0.000">
+      <failure message="1 fixture(s) not torn down">fixture[0]: captured {&#34;abc_id&#34;:13}</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
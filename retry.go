@@ -0,0 +1,150 @@
+package graphqlfixture
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how a failed graphql request of a fixture is retried.
+// By default only queries are retried (they are idempotent); a mutation could have been applied
+// even though the response is lost, so retrying mutations needs to be opted in with RetryMutations.
+type RetryPolicy struct {
+	MaxAttempts    int                  // total attempts including the first one. <= 1 means no retry.
+	InitialBackoff time.Duration        // backoff before the 1st retry. Default 100ms.
+	MaxBackoff     time.Duration        // upper bound of the backoff. Default 5s.
+	Multiplier     float64              // backoff growth per retry. Default 2.
+	Jitter         float64              // fraction (0..1) of the backoff to be randomly taken off, to avoid retrying in lockstep. 0 means no jitter.
+	Retryable      func(err error) bool // decides if the error is worth a retry. Default DefaultRetryable.
+	RetryMutations bool                 // also retry mutations (not only queries)
+}
+
+const (
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+	defaultRetryMultiplier     = 2.0
+)
+
+// appliesTo returns whether the policy applies to the graphql operation type ("query", "mutation" ..)
+func (p *RetryPolicy) appliesTo(operation string) bool {
+	if p == nil || p.MaxAttempts <= 1 {
+		return false
+	}
+	return operation == "query" || p.RetryMutations
+}
+
+// maxAttempts returns the max attempts for the given graphql operation type; at least 1.
+func (p *RetryPolicy) maxAttempts(operation string) int {
+	if !p.appliesTo(operation) {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) isRetryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// backoff returns the duration to wait before the given retry (1 = the first retry)
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	initial, max, multiplier := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if initial <= 0 {
+		initial = defaultRetryInitialBackoff
+	}
+	if max <= 0 {
+		max = defaultRetryMaxBackoff
+	}
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	backoff := math.Min(float64(initial)*math.Pow(multiplier, float64(retry-1)), float64(max))
+	if p.Jitter > 0 {
+		backoff -= backoff * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// sleepCtx waits for d, or returns the context's error if the context is done first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// DefaultRetryable retries the transient transport errors (see IsTransientError).
+// GraphQL errors are not retried; combine with RetryOnCodes to retry some of them.
+func DefaultRetryable(err error) bool {
+	return IsTransientError(err)
+}
+
+// RetryOnCodes returns a Retryable predicate that retries transient transport errors, and graphql errors with
+// any of the given `extensions.code`.
+func RetryOnCodes(codes ...string) func(err error) bool {
+	return func(err error) bool {
+		if IsTransientError(err) {
+			return true
+		}
+		for _, code := range codes {
+			if HasErrorCode(err, code) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IsTransientError reports whether err is a transport failure that is likely to go away on its own,
+// e.g., the graphql server is starting up or restarting:
+// connection refused/reset, unexpected EOF, network timeout, or http status 502, 503, 504.
+// Context cancellation and deadline are not transient.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	switch HTTPStatusCode(err) {
+	case 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+// graphqlclient reports non-200 response as: bad response status code: 502 Bad Gateway body: "..."
+var httpStatusCodeRegexp = regexp.MustCompile(`bad response status code: (\d{3})`)
+
+// HTTPStatusCode extracts the http status code from the error returned by graphqlclient on a non-200 response.
+// Returns 0 if err is not caused by a non-200 response.
+func HTTPStatusCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	matches := httpStatusCodeRegexp.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(matches[1])
+	return code
+}
@@ -0,0 +1,155 @@
+package graphqlfixture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		givenErr error
+		expected bool
+	}{
+		{name: "nil", givenErr: nil, expected: false},
+		{name: "connection refused", givenErr: fmt.Errorf("error sending request: %w", syscall.ECONNREFUSED), expected: true},
+		{name: "connection reset", givenErr: fmt.Errorf("error sending request: %w", syscall.ECONNRESET), expected: true},
+		{name: "bad gateway", givenErr: errors.New(`bad response status code: 502 Bad Gateway body: ""`), expected: true},
+		{name: "bad request", givenErr: errors.New(`bad response status code: 400 Bad Request body: ""`), expected: false},
+		{name: "context cancelled", givenErr: fmt.Errorf("error sending request: %w", context.Canceled), expected: false},
+		{name: "graphql error", givenErr: newResponseError([]interface{}{map[string]interface{}{"message": "x"}}), expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsTransientError(tc.givenErr))
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 3}
+	assert.Equal(t, 10*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 30*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(3)) // capped
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		backoff := policy.backoff(2)
+		assert.True(t, backoff > 15*time.Millisecond && backoff <= 30*time.Millisecond, "backoff %v out of range", backoff)
+	}
+}
+
+func TestSetupRetry(t *testing.T) {
+	// executor that fails with the given errors first, then succeeds
+	newFlakyExecutor := func(errs ...error) (Executor, *int) {
+		calls := 0
+		return ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+			calls++
+			if calls <= len(errs) {
+				return errs[calls-1]
+			}
+			*resp.(*[]byte) = []byte(`{ "data": { "abc": [ { "id": 1 } ] } }`)
+			return nil
+		}), &calls
+	}
+	connRefused := fmt.Errorf("error sending request: %w", syscall.ECONNREFUSED)
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	testCases := []struct {
+		name          string
+		givenSetup    string
+		givenRetry    *RetryPolicy
+		givenErrs     []error
+		expectedCalls int
+		expectedErr   bool
+		expectedLogs  []string
+	}{
+		{
+			name:          "query is retried until success",
+			givenSetup:    `query { abc { id } }`,
+			givenRetry:    policy,
+			givenErrs:     []error{connRefused, connRefused},
+			expectedCalls: 3,
+			expectedLogs: []string{
+				"fixture[0].setup: attempt 1/3 failed, retry in 1ms: graphql request failed: error sending request: connection refused",
+				"fixture[0].setup: attempt 2/3 failed, retry in 2ms: graphql request failed: error sending request: connection refused",
+				"fixture[0].setup: attempt 3/3 succeeded",
+				"fixture[0].setup: completed",
+				"fixture[0].captors: not exist",
+			},
+		},
+		{
+			name:          "query gives up after max attempts",
+			givenSetup:    `query { abc { id } }`,
+			givenRetry:    policy,
+			givenErrs:     []error{connRefused, connRefused, connRefused},
+			expectedCalls: 3,
+			expectedErr:   true,
+			expectedLogs: []string{
+				"fixture[0].setup: attempt 1/3 failed, retry in 1ms: graphql request failed: error sending request: connection refused",
+				"fixture[0].setup: attempt 2/3 failed, retry in 2ms: graphql request failed: error sending request: connection refused",
+				"fixture[0].setup: attempt 3/3 failed, giving up: graphql request failed: error sending request: connection refused",
+				"fixture[0].setup failed: graphql request failed: error sending request: connection refused",
+			},
+		},
+		{
+			name:          "non-retryable error is not retried",
+			givenSetup:    `query { abc { id } }`,
+			givenRetry:    policy,
+			givenErrs:     []error{errors.New(`bad response status code: 400 Bad Request body: ""`)},
+			expectedCalls: 1,
+			expectedErr:   true,
+			expectedLogs: []string{
+				`fixture[0].setup: attempt 1/3 failed, giving up: graphql request failed: bad response status code: 400 Bad Request body: ""`,
+				`fixture[0].setup failed: graphql request failed: bad response status code: 400 Bad Request body: ""`,
+			},
+		},
+		{
+			name:          "mutation is not retried by default",
+			givenSetup:    `mutation { insert_abc(objects: {}) { affected_rows } }`,
+			givenRetry:    policy,
+			givenErrs:     []error{connRefused},
+			expectedCalls: 1,
+			expectedErr:   true,
+			expectedLogs: []string{
+				"fixture[0].setup failed: graphql request failed: error sending request: connection refused",
+			},
+		},
+		{
+			name:          "mutation is retried if opted in",
+			givenSetup:    `mutation { insert_abc(objects: {}) { affected_rows } }`,
+			givenRetry:    &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, RetryMutations: true},
+			givenErrs:     []error{connRefused},
+			expectedCalls: 2,
+			expectedLogs: []string{
+				"fixture[0].setup: attempt 1/2 failed, retry in 1ms: graphql request failed: error sending request: connection refused",
+				"fixture[0].setup: attempt 2/2 succeeded",
+				"fixture[0].setup: completed",
+				"fixture[0].captors: not exist",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			executor, calls := newFlakyExecutor(tc.givenErrs...)
+			fixtures := Fixtures{
+				Fixtures: []Fixture{{Setup: tc.givenSetup}},
+				Retry:    tc.givenRetry,
+			}
+			// WHEN
+			err := fixtures.Setup(context.Background(), executor)
+			// THEN
+			assert.Equal(t, tc.expectedErr, err != nil)
+			assert.Equal(t, tc.expectedCalls, *calls)
+			assert.Equal(t, tc.expectedLogs, fixtures.Logs())
+		})
+	}
+}
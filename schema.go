@@ -0,0 +1,437 @@
+package graphqlfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	gqlast "github.com/graphql-go/graphql/language/ast"
+	gqlparser "github.com/graphql-go/graphql/language/parser"
+	gqlprinter "github.com/graphql-go/graphql/language/printer"
+	gqlsource "github.com/graphql-go/graphql/language/source"
+	"io/ioutil"
+	"strings"
+)
+
+// Schema is a graphql schema the fixtures are validated against during Parse() (see Fixtures.Schema):
+// fields, arguments, variable types, required arguments .. as the server would validate the requests.
+type Schema struct {
+	schema graphql.Schema
+}
+
+// LoadSchema reads the schema file: SDL (e.g., `schema.graphql`), or the json result of the introspection query
+// (with or without the `data` wrapper)
+func LoadSchema(path string) (*Schema, error) {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read schema: %w", err)
+	}
+	var schema *Schema
+	if trimmed := bytes.TrimSpace(fileBytes); len(trimmed) > 0 && trimmed[0] == '{' {
+		schema, err = ParseIntrospection(fileBytes)
+	} else {
+		schema, err = ParseSDL(string(fileBytes))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// ParseSDL builds the schema from the SDL (schema definition language)
+func ParseSDL(sdl string) (*Schema, error) {
+	doc, err := gqlparser.Parse(gqlparser.ParseParams{Source: &gqlsource.Source{Body: []byte(sdl)}})
+	if err != nil {
+		return nil, fmt.Errorf("parse schema error: %w", err)
+	}
+	return newSchema(sdlToIntrospection(doc))
+}
+
+// ParseIntrospection builds the schema from the json result of the introspection query
+func ParseIntrospection(jsonBytes []byte) (*Schema, error) {
+	types, err := decodeIntrospection(jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	return newSchema(types)
+}
+
+// decodeIntrospection decodes the `__schema` of the introspection json, with or without the `data` wrapper
+func decodeIntrospection(jsonBytes []byte) (*introspectionSchema, error) {
+	var result struct {
+		Data *struct {
+			Schema *introspectionSchema `json:"__schema"`
+		} `json:"data"`
+		Schema *introspectionSchema `json:"__schema"`
+	}
+	if err := json.Unmarshal(jsonBytes, &result); err != nil {
+		return nil, fmt.Errorf("fail to decode introspection: %w", err)
+	}
+	types := result.Schema
+	if result.Data != nil && result.Data.Schema != nil {
+		types = result.Data.Schema
+	}
+	if types == nil {
+		return nil, fmt.Errorf("no __schema in the introspection")
+	}
+	return types, nil
+}
+
+// SchemaValidationError is a fixture's graphql not valid per the schema
+type SchemaValidationError struct {
+	FixtureIdx  int
+	FixtureName string
+	Phase       Phase // PhaseSetup or PhaseTeardown
+	Message     string
+	Locations   []GraphQLErrorLocation // in the fixture's graphql
+}
+
+func (e *SchemaValidationError) Error() string {
+	step := fmt.Sprintf("fixture[%d].%s", e.FixtureIdx, e.Phase)
+	if e.FixtureName != "" {
+		step += fmt.Sprintf(" (%s)", e.FixtureName)
+	}
+	var locations []string
+	for _, loc := range e.Locations {
+		locations = append(locations, fmt.Sprintf("line %d, column %d", loc.Line, loc.Column))
+	}
+	if len(locations) > 0 {
+		return fmt.Sprintf("%s: does not match the schema: %s (at %s)", step, e.Message, strings.Join(locations, "; "))
+	}
+	return fmt.Sprintf("%s: does not match the schema: %s", step, e.Message)
+}
+
+// validate validates the graphql against the schema; the errors are located in graphqlStr as given
+func (s *Schema) validate(fIdx int, fixtureName string, phase Phase, graphqlStr string) []error {
+	// parsed again without stripping the newlines (as parseGraphql does), for the locations to be accurate
+	doc, err := gqlparser.Parse(gqlparser.ParseParams{Source: &gqlsource.Source{Body: []byte(graphqlStr)}})
+	if err != nil {
+		return nil // reach here: syntax errors are already reported by parseGraphql
+	}
+	var errs []error
+	for _, formattedErr := range graphql.ValidateDocument(&s.schema, doc, nil).Errors {
+		validationErr := &SchemaValidationError{FixtureIdx: fIdx, FixtureName: fixtureName, Phase: phase, Message: formattedErr.Message}
+		for _, loc := range formattedErr.Locations {
+			validationErr.Locations = append(validationErr.Locations, GraphQLErrorLocation{Line: loc.Line, Column: loc.Column})
+		}
+		errs = append(errs, validationErr)
+	}
+	return errs
+}
+
+// introspectionSchema is the `__schema` of the introspection query result; SDL is converted into it too
+type introspectionSchema struct {
+	QueryType        *introspectionName  `json:"queryType"`
+	MutationType     *introspectionName  `json:"mutationType"`
+	SubscriptionType *introspectionName  `json:"subscriptionType"`
+	Types            []introspectionType `json:"types"`
+}
+
+type introspectionName struct {
+	Name string `json:"name"`
+}
+
+type introspectionType struct {
+	Kind          string                    `json:"kind"`
+	Name          string                    `json:"name"`
+	Fields        []introspectionField      `json:"fields"`
+	InputFields   []introspectionInputValue `json:"inputFields"`
+	Interfaces    []introspectionTypeRef    `json:"interfaces"`
+	EnumValues    []introspectionName       `json:"enumValues"`
+	PossibleTypes []introspectionTypeRef    `json:"possibleTypes"`
+}
+
+type introspectionField struct {
+	Name string                    `json:"name"`
+	Args []introspectionInputValue `json:"args"`
+	Type introspectionTypeRef      `json:"type"`
+}
+
+type introspectionInputValue struct {
+	Name         string               `json:"name"`
+	Type         introspectionTypeRef `json:"type"`
+	DefaultValue *string              `json:"defaultValue"`
+}
+
+type introspectionTypeRef struct {
+	Kind   string                `json:"kind"`
+	Name   *string               `json:"name"`
+	OfType *introspectionTypeRef `json:"ofType"`
+}
+
+// sdlToIntrospection converts the SDL document into the introspection form
+func sdlToIntrospection(doc *gqlast.Document) *introspectionSchema {
+	schema := &introspectionSchema{}
+	typeIdx := map[string]int{}
+	addType := func(t introspectionType) {
+		if idx, found := typeIdx[t.Name]; found { // e.g., `extend type`: merged
+			existing := &schema.Types[idx]
+			existing.Fields = append(existing.Fields, t.Fields...)
+			existing.InputFields = append(existing.InputFields, t.InputFields...)
+			existing.Interfaces = append(existing.Interfaces, t.Interfaces...)
+			existing.EnumValues = append(existing.EnumValues, t.EnumValues...)
+			existing.PossibleTypes = append(existing.PossibleTypes, t.PossibleTypes...)
+			return
+		}
+		typeIdx[t.Name] = len(schema.Types)
+		schema.Types = append(schema.Types, t)
+	}
+	var addDefinition func(def gqlast.Node)
+	addDefinition = func(def gqlast.Node) {
+		switch node := def.(type) {
+		case *gqlast.SchemaDefinition:
+			for _, opType := range node.OperationTypes {
+				name := &introspectionName{Name: opType.Type.Name.Value}
+				switch opType.Operation {
+				case gqlast.OperationTypeQuery:
+					schema.QueryType = name
+				case gqlast.OperationTypeMutation:
+					schema.MutationType = name
+				case gqlast.OperationTypeSubscription:
+					schema.SubscriptionType = name
+				}
+			}
+		case *gqlast.ScalarDefinition:
+			addType(introspectionType{Kind: "SCALAR", Name: node.Name.Value})
+		case *gqlast.ObjectDefinition:
+			addType(introspectionType{Kind: "OBJECT", Name: node.Name.Value, Fields: sdlFields(node.Fields), Interfaces: sdlNamedRefs(node.Interfaces)})
+		case *gqlast.InterfaceDefinition:
+			addType(introspectionType{Kind: "INTERFACE", Name: node.Name.Value, Fields: sdlFields(node.Fields)})
+		case *gqlast.UnionDefinition:
+			addType(introspectionType{Kind: "UNION", Name: node.Name.Value, PossibleTypes: sdlNamedRefs(node.Types)})
+		case *gqlast.EnumDefinition:
+			t := introspectionType{Kind: "ENUM", Name: node.Name.Value}
+			for _, value := range node.Values {
+				t.EnumValues = append(t.EnumValues, introspectionName{Name: value.Name.Value})
+			}
+			addType(t)
+		case *gqlast.InputObjectDefinition:
+			addType(introspectionType{Kind: "INPUT_OBJECT", Name: node.Name.Value, InputFields: sdlInputValues(node.Fields)})
+		case *gqlast.TypeExtensionDefinition:
+			addDefinition(node.Definition)
+		}
+	}
+	for _, def := range doc.Definitions {
+		addDefinition(def)
+	}
+
+	// the default root types, if no `schema { .. }`
+	defaultRoot := func(name string) *introspectionName {
+		if _, found := typeIdx[name]; found {
+			return &introspectionName{Name: name}
+		}
+		return nil
+	}
+	if schema.QueryType == nil {
+		schema.QueryType = defaultRoot("Query")
+	}
+	if schema.MutationType == nil {
+		schema.MutationType = defaultRoot("Mutation")
+	}
+	if schema.SubscriptionType == nil {
+		schema.SubscriptionType = defaultRoot("Subscription")
+	}
+	return schema
+}
+
+func sdlFields(fieldDefs []*gqlast.FieldDefinition) []introspectionField {
+	var fields []introspectionField
+	for _, fieldDef := range fieldDefs {
+		fields = append(fields, introspectionField{
+			Name: fieldDef.Name.Value,
+			Args: sdlInputValues(fieldDef.Arguments),
+			Type: sdlTypeRef(fieldDef.Type),
+		})
+	}
+	return fields
+}
+
+func sdlInputValues(valueDefs []*gqlast.InputValueDefinition) []introspectionInputValue {
+	var values []introspectionInputValue
+	for _, valueDef := range valueDefs {
+		value := introspectionInputValue{Name: valueDef.Name.Value, Type: sdlTypeRef(valueDef.Type)}
+		if valueDef.DefaultValue != nil {
+			if printed, ok := gqlprinter.Print(valueDef.DefaultValue).(string); ok {
+				value.DefaultValue = &printed
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func sdlNamedRefs(named []*gqlast.Named) []introspectionTypeRef {
+	var refs []introspectionTypeRef
+	for _, n := range named {
+		refs = append(refs, sdlTypeRef(n))
+	}
+	return refs
+}
+
+func sdlTypeRef(t gqlast.Type) introspectionTypeRef {
+	switch node := t.(type) {
+	case *gqlast.NonNull:
+		ofType := sdlTypeRef(node.Type)
+		return introspectionTypeRef{Kind: "NON_NULL", OfType: &ofType}
+	case *gqlast.List:
+		ofType := sdlTypeRef(node.Type)
+		return introspectionTypeRef{Kind: "LIST", OfType: &ofType}
+	case *gqlast.Named:
+		name := node.Name.Value
+		return introspectionTypeRef{Name: &name}
+	}
+	return introspectionTypeRef{}
+}
+
+// builtinScalars are the types defined by graphql-go itself
+var builtinScalars = map[string]graphql.Type{
+	"String":  graphql.String,
+	"Int":     graphql.Int,
+	"Float":   graphql.Float,
+	"Boolean": graphql.Boolean,
+	"ID":      graphql.ID,
+}
+
+// newSchema builds the graphql schema (for validation only: nothing resolves) from the introspection
+func newSchema(types *introspectionSchema) (*Schema, error) {
+	if types.QueryType == nil {
+		return nil, fmt.Errorf("no query type in the schema")
+	}
+	typeDefs := map[string]introspectionType{}
+	for _, t := range types.Types {
+		typeDefs[t.Name] = t
+	}
+
+	built := map[string]graphql.Type{}
+	var buildErr error
+	var namedType func(name string) graphql.Type
+	var typeRef func(ref introspectionTypeRef) graphql.Type
+	typeRef = func(ref introspectionTypeRef) graphql.Type {
+		switch {
+		case ref.Kind == "NON_NULL" && ref.OfType != nil:
+			return graphql.NewNonNull(typeRef(*ref.OfType))
+		case ref.Kind == "LIST" && ref.OfType != nil:
+			return graphql.NewList(typeRef(*ref.OfType))
+		case ref.Name != nil:
+			return namedType(*ref.Name)
+		}
+		buildErr = fmt.Errorf("invalid type reference")
+		return graphql.String
+	}
+	args := func(values []introspectionInputValue) graphql.FieldConfigArgument {
+		configs := graphql.FieldConfigArgument{}
+		for _, value := range values {
+			config := &graphql.ArgumentConfig{Type: typeRef(value.Type)}
+			if value.DefaultValue != nil {
+				config.DefaultValue = *value.DefaultValue
+			}
+			configs[value.Name] = config
+		}
+		return configs
+	}
+	fields := func(fieldDefs []introspectionField) graphql.Fields {
+		fields := graphql.Fields{}
+		for _, fieldDef := range fieldDefs {
+			fields[fieldDef.Name] = &graphql.Field{Type: typeRef(fieldDef.Type), Args: args(fieldDef.Args)}
+		}
+		return fields
+	}
+	namedType = func(name string) graphql.Type {
+		if t, found := builtinScalars[name]; found {
+			return t
+		}
+		if t, found := built[name]; found {
+			return t
+		}
+		def, found := typeDefs[name]
+		if !found {
+			buildErr = fmt.Errorf("unknown type %s", name)
+			return graphql.String
+		}
+		switch def.Kind {
+		case "SCALAR": // custom scalars (e.g., timestamptz) accept any literal
+			built[name] = graphql.NewScalar(graphql.ScalarConfig{
+				Name:         name,
+				Serialize:    func(value interface{}) interface{} { return value },
+				ParseValue:   func(value interface{}) interface{} { return value },
+				ParseLiteral: func(valueAST gqlast.Value) interface{} { return valueAST },
+			})
+		case "ENUM":
+			values := graphql.EnumValueConfigMap{}
+			for _, value := range def.EnumValues {
+				values[value.Name] = &graphql.EnumValueConfig{Value: value.Name}
+			}
+			built[name] = graphql.NewEnum(graphql.EnumConfig{Name: name, Values: values})
+		case "INPUT_OBJECT":
+			built[name] = graphql.NewInputObject(graphql.InputObjectConfig{
+				Name: name,
+				Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+					fieldMap := graphql.InputObjectConfigFieldMap{}
+					for _, value := range def.InputFields {
+						field := &graphql.InputObjectFieldConfig{Type: typeRef(value.Type)}
+						if value.DefaultValue != nil {
+							field.DefaultValue = *value.DefaultValue
+						}
+						fieldMap[value.Name] = field
+					}
+					return fieldMap
+				}),
+			})
+		case "INTERFACE":
+			built[name] = graphql.NewInterface(graphql.InterfaceConfig{
+				Name:        name,
+				Fields:      graphql.FieldsThunk(func() graphql.Fields { return fields(def.Fields) }),
+				ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object { return nil },
+			})
+		case "UNION":
+			var objects []*graphql.Object // the objects' fields are thunks: no cycle building them first
+			for _, ref := range def.PossibleTypes {
+				if object, ok := typeRef(ref).(*graphql.Object); ok {
+					objects = append(objects, object)
+				}
+			}
+			built[name] = graphql.NewUnion(graphql.UnionConfig{
+				Name:        name,
+				Types:       objects,
+				ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object { return nil },
+			})
+		default: // OBJECT
+			built[name] = graphql.NewObject(graphql.ObjectConfig{
+				Name:   name,
+				Fields: graphql.FieldsThunk(func() graphql.Fields { return fields(def.Fields) }),
+				Interfaces: graphql.InterfacesThunk(func() []*graphql.Interface {
+					var interfaces []*graphql.Interface
+					for _, ref := range def.Interfaces {
+						if iface, ok := typeRef(ref).(*graphql.Interface); ok {
+							interfaces = append(interfaces, iface)
+						}
+					}
+					return interfaces
+				}),
+			})
+		}
+		return built[name]
+	}
+
+	config := graphql.SchemaConfig{}
+	config.Query, _ = namedType(types.QueryType.Name).(*graphql.Object)
+	if types.MutationType != nil {
+		config.Mutation, _ = namedType(types.MutationType.Name).(*graphql.Object)
+	}
+	if types.SubscriptionType != nil {
+		config.Subscription, _ = namedType(types.SubscriptionType.Name).(*graphql.Object)
+	}
+	for _, t := range types.Types { // also the types not reachable from the roots
+		if !strings.HasPrefix(t.Name, "__") {
+			config.Types = append(config.Types, namedType(t.Name))
+		}
+	}
+	if buildErr != nil {
+		return nil, buildErr
+	}
+	schema, err := graphql.NewSchema(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Schema{schema: schema}, nil
+}
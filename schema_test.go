@@ -0,0 +1,155 @@
+package graphqlfixture
+
+import (
+	"encoding/json"
+	"github.com/gmm1900/gopointer"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// a (much reduced) hasura schema of the example's tables
+const testSDL = `
+schema { query: query_root mutation: mutation_root }
+
+scalar timestamptz
+
+input Int_comparison_exp { _eq: Int _in: [Int!] }
+input String_comparison_exp { _eq: String _in: [String!] }
+
+type subjects { id: Int! name: String! created_at: timestamptz! }
+input subjects_insert_input { name: String created_at: timestamptz }
+input subjects_bool_exp { id: Int_comparison_exp name: String_comparison_exp }
+type subjects_mutation_response { affected_rows: Int! returning: [subjects!]! }
+
+type query_root { subjects(where: subjects_bool_exp, limit: Int): [subjects!]! }
+type mutation_root {
+  insert_subjects(objects: [subjects_insert_input!]!): subjects_mutation_response
+  delete_subjects(where: subjects_bool_exp!): subjects_mutation_response
+}
+`
+
+func TestSchemaValidation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		givenFixture   Fixture
+		expectedErrMsg []string
+	}{
+		{
+			name: "valid",
+			givenFixture: Fixture{
+				Setup:    `mutation { insert_subjects(objects: [{ name: "CS101", created_at: "2020-01-01" }]) { returning { id } } }`,
+				Captors:  map[string]string{"id": "/data/insert_subjects/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($id: Int!) { delete_subjects(where: { id: { _eq: $id } }) { affected_rows } }`),
+			},
+		},
+		{
+			name: "typo in field, with the location",
+			givenFixture: Fixture{
+				Name: "subjects",
+				Setup: `mutation {
+  insert_subject(objects: [{ name: "CS101" }]) { affected_rows }
+}`,
+			},
+			expectedErrMsg: []string{
+				`fixture[0].setup (subjects): does not match the schema: Cannot query field "insert_subject" on type "mutation_root". Did you mean "insert_subjects" or "delete_subjects"? (at line 2, column 3)`,
+			},
+		},
+		{
+			name: "wrong argument type and missing required argument",
+			givenFixture: Fixture{
+				Setup:    `mutation { insert_subjects(objects: [{ name: 101 }]) { affected_rows } }`,
+				Teardown: gopointer.OfString(`mutation { delete_subjects { affected_rows } }`),
+			},
+			expectedErrMsg: []string{
+				"fixture[0].setup: does not match the schema: Argument \"objects\" has invalid value [{name: 101}].\nIn element #1: In field \"name\": Expected type \"String\", found 101. (at line 1, column 37)",
+				`fixture[0].teardown: does not match the schema: Field "delete_subjects" argument "where" of type "subjects_bool_exp!" is required but not provided. (at line 1, column 12)`,
+			},
+		},
+		{
+			name: "variable type mismatch",
+			givenFixture: Fixture{
+				Setup:    `mutation { insert_subjects(objects: [{ name: "CS101" }]) { returning { id } } }`,
+				Captors:  map[string]string{"id": "/data/insert_subjects/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($id: String) { delete_subjects(where: { id: { _eq: $id } }) { affected_rows } }`),
+			},
+			expectedErrMsg: []string{
+				`fixture[0].teardown: does not match the schema: Variable "$id" of type "String" used in position expecting type "Int". (at line 1, column 11; line 1, column 62)`,
+			},
+		},
+	}
+
+	schema, err := ParseSDL(testSDL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixtures := Fixtures{Fixtures: []Fixture{tc.givenFixture}, Schema: schema}
+			fixtures.Parse()
+			if len(tc.expectedErrMsg) == 0 {
+				assert.NoError(t, fixtures.parseErr)
+				return
+			}
+			var multierr *multierror.Error
+			if assert.ErrorAs(t, fixtures.parseErr, &multierr) {
+				var errMsgs []string
+				for _, err := range multierr.Errors {
+					errMsgs = append(errMsgs, err.Error())
+				}
+				assert.Equal(t, tc.expectedErrMsg, errMsgs)
+			}
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	sdlSchema, err := ParseSDL(testSDL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the introspection json, as a server would respond to the introspection query
+	result := graphql.Do(graphql.Params{Schema: sdlSchema.schema, RequestString: testutil.IntrospectionQuery})
+	if !assert.Empty(t, result.Errors) {
+		return
+	}
+	introspectionBytes, err := json.Marshal(result)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	sdlPath := filepath.Join(dir, "schema.graphql")
+	introspectionPath := filepath.Join(dir, "schema.json")
+	assert.NoError(t, ioutil.WriteFile(sdlPath, []byte(testSDL), 0644))
+	assert.NoError(t, ioutil.WriteFile(introspectionPath, introspectionBytes, 0644))
+
+	for _, path := range []string{sdlPath, introspectionPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			schema, err := LoadSchema(path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			fixtures := Fixtures{
+				Fixtures: []Fixture{
+					{Setup: `mutation { insert_subjects(objects: [{ name: "CS101" }]) { affected_rows } }`},
+					{Setup: `query { subjects(where: { title: { _eq: "CS101" } }) { id } }`},
+				},
+				Schema: schema,
+			}
+			fixtures.Parse()
+			if assert.Error(t, fixtures.parseErr) {
+				assert.Contains(t, fixtures.parseErr.Error(),
+					"fixture[1].setup: does not match the schema: Argument \"where\" has invalid value {title: {_eq: \"CS101\"}}.\nIn field \"title\": Unknown field. (at line 1, column 25)")
+				assert.NotContains(t, fixtures.parseErr.Error(), "fixture[0]")
+			}
+		})
+	}
+
+	_, err = LoadSchema(filepath.Join(dir, "missing.graphql"))
+	assert.Error(t, err)
+	_, err = ParseIntrospection([]byte(`{"data": {}}`))
+	assert.EqualError(t, err, "no __schema in the introspection")
+}
@@ -0,0 +1,183 @@
+package graphqlfixture
+
+import (
+	"github.com/graphql-go/graphql"
+	"sort"
+	"strings"
+)
+
+// tableReference is a table whose rows reference the rows of another (by a foreign key), so the referencing rows
+// have to be deleted first, unless ON DELETE CASCADE deletes them with the referenced ones
+type tableReference struct {
+	table    string // the graphql name of the referencing table
+	refTable string // the graphql name of the referenced table
+	cascade  bool
+}
+
+// tableReferences returns the references between the tables: by the foreign keys of fs.Hasura if given; otherwise by
+// the object relationships of fs.Schema (a field of table T of the type of table P is taken as T referencing P, with
+// ON DELETE unknown; unless P has such a field of T too, e.g., a one-to-one relationship). Nil if neither is given.
+func (fs *Fixtures) tableReferences() []tableReference {
+	var refs []tableReference
+	if fs.Hasura != nil {
+		for _, t := range fs.Hasura.SortedTables() {
+			for _, fk := range t.ForeignKeys {
+				refTable := fs.Hasura.Table(fk.RefSchema, fk.RefTable)
+				if refTable == nil || refTable == t {
+					continue
+				}
+				refs = append(refs, tableReference{table: t.GraphqlName(), refTable: refTable.GraphqlName(), cascade: fk.OnDelete == "cascade"})
+			}
+		}
+		return refs
+	}
+	if fs.Schema == nil || fs.Schema.schema.MutationType() == nil {
+		return nil
+	}
+
+	// the tables are the types deleted by a delete_X root field
+	objectRefs := map[string]map[string]bool{} // by table: the tables of its object fields
+	for name := range fs.Schema.schema.MutationType().Fields() {
+		if !strings.HasPrefix(name, "delete_") || strings.HasSuffix(name, "_by_pk") {
+			continue
+		}
+		if _, ok := fs.Schema.schema.Type(strings.TrimPrefix(name, "delete_")).(*graphql.Object); ok {
+			objectRefs[strings.TrimPrefix(name, "delete_")] = map[string]bool{}
+		}
+	}
+	for table := range objectRefs {
+		for _, field := range fs.Schema.schema.Type(table).(*graphql.Object).Fields() {
+			fieldType := field.Type
+			if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+				fieldType = nonNull.OfType
+			}
+			if object, ok := fieldType.(*graphql.Object); ok && object.Name() != table {
+				if _, found := objectRefs[object.Name()]; found {
+					objectRefs[table][object.Name()] = true
+				}
+			}
+		}
+	}
+	for table, refTables := range objectRefs {
+		for refTable := range refTables {
+			if !objectRefs[refTable][table] {
+				refs = append(refs, tableReference{table: table, refTable: refTable})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].table < refs[j].table || refs[i].table == refs[j].table && refs[i].refTable < refs[j].refTable
+	})
+	return refs
+}
+
+// teardownDependencies returns the references that make a fixture's teardown go before another's: by fixture index,
+// the references (non cascading) from the tables it deletes from to those the other deletes from
+func (fs *Fixtures) teardownDependencies(untilIdx int) map[int]map[int]tableReference {
+	refs := fs.tableReferences()
+	if len(refs) == 0 {
+		return nil
+	}
+	deleted := make([]map[string]bool, untilIdx+1)
+	for fIdx := 0; fIdx <= untilIdx; fIdx++ {
+		if f := fs.Fixtures[fIdx]; f.Teardown != nil {
+			if teardownDoc, err := f.teardownDoc(); err == nil {
+				deleted[fIdx] = deletedTables(teardownDoc)
+			}
+		}
+	}
+	dependencies := map[int]map[int]tableReference{}
+	for child := 0; child <= untilIdx; child++ {
+		for parent := 0; parent <= untilIdx; parent++ {
+			if child == parent {
+				continue
+			}
+			for _, ref := range refs {
+				if !ref.cascade && deleted[child][ref.table] && deleted[parent][ref.refTable] {
+					if dependencies[child] == nil {
+						dependencies[child] = map[int]tableReference{}
+					}
+					dependencies[child][parent] = ref
+					break
+				}
+			}
+		}
+	}
+	return dependencies
+}
+
+// teardownOrder returns the fixtures [0, untilIdx] in the order of their teardowns: the reverse sequence; or with
+// fs.OrderTeardownByForeignKeys, the children before their parents (per tableReferences), otherwise in the reverse
+// sequence as far as possible. The fixtures in a cycle of references are in the reverse sequence.
+func (fs *Fixtures) teardownOrder(untilIdx int) []int {
+	var dependencies map[int]map[int]tableReference
+	if fs.OrderTeardownByForeignKeys {
+		dependencies = fs.teardownDependencies(untilIdx)
+	}
+	order := make([]int, 0, untilIdx+1)
+	done := map[int]bool{}
+	for len(order) <= untilIdx {
+		next := -1
+		for fIdx := untilIdx; fIdx >= 0 && next < 0; fIdx-- {
+			if done[fIdx] {
+				continue
+			}
+			ready := true
+			for child, parents := range dependencies {
+				if _, found := parents[fIdx]; found && !done[child] {
+					ready = false
+				}
+			}
+			if ready {
+				next = fIdx
+			}
+		}
+		if next < 0 { // a cycle: the last of the rest goes first
+			for fIdx := untilIdx; fIdx >= 0 && next < 0; fIdx-- {
+				if !done[fIdx] {
+					next = fIdx
+				}
+			}
+		}
+		order = append(order, next)
+		done[next] = true
+	}
+	return order
+}
+
+// teardownOrderViolations reports (with RuleTeardownOrder) the teardowns that, in the reverse sequence, delete from a
+// table before another fixture's teardown deletes the rows referencing it. Nothing with fs.OrderTeardownByForeignKeys.
+func (fs *Fixtures) teardownOrderViolations(report func(fIdx int, rule string, phase Phase, format string, args ...interface{})) {
+	if fs.OrderTeardownByForeignKeys || len(fs.Fixtures) == 0 {
+		return
+	}
+	dependencies := fs.teardownDependencies(len(fs.Fixtures) - 1)
+	for parent := range fs.Fixtures {
+		for child := 0; child < parent; child++ {
+			if ref, found := dependencies[child][parent]; found {
+				report(parent, RuleTeardownOrder, PhaseTeardown,
+					"deletes from %s before fixture[%d] deletes from %s, which references it; reorder the fixtures or set OrderTeardownByForeignKeys",
+					ref.refTable, child, ref.table)
+			}
+		}
+	}
+}
+
+// leftovers returns the fixtures that have been setup, have a teardown, but are not (successfully) torn down, in the
+// order of Teardown: those after the one Teardown got to (teardownUntilIdx), in that order
+func (fs *Fixtures) leftovers() []int {
+	if fs.setupUntilIdx == nil {
+		return nil
+	}
+	var leftovers []int
+	tornDown := fs.teardownUntilIdx != nil // until past teardownUntilIdx
+	for _, fIdx := range fs.teardownOrder(*fs.setupUntilIdx) {
+		if !tornDown && fs.Fixtures[fIdx].Teardown != nil {
+			leftovers = append(leftovers, fIdx)
+		}
+		if tornDown && fIdx == *fs.teardownUntilIdx {
+			tornDown = false
+		}
+	}
+	return leftovers
+}
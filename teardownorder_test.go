@@ -0,0 +1,158 @@
+package graphqlfixture
+
+import (
+	"context"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/gmm1900/graphqlfixture/hasurameta"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fixture[0] deletes from teaches, which references the instructors fixture[1] deletes from
+func teardownOrderFixtures() []Fixture {
+	return []Fixture{
+		{
+			Setup:    `mutation { insert_teaches_one(object: { instructor_id: 1 }) { id } }`,
+			Teardown: gopointer.OfString(`mutation { delete_teaches(where: { instructor_id: { _eq: 1 } }) { affected_rows } }`),
+		},
+		{
+			Setup:    `mutation { insert_instructors_one(object: { id: 1 }) { id } }`,
+			Teardown: gopointer.OfString(`mutation { delete_instructors(where: { id: { _eq: 1 } }) { affected_rows } }`),
+		},
+		{
+			Setup: `query { instructors { id } }`,
+		},
+	}
+}
+
+func TestTeardownOrder(t *testing.T) {
+	hasuraSchema := func(onDelete string) *hasurameta.Schema {
+		schema := &hasurameta.Schema{Tables: map[string]*hasurameta.Table{}}
+		assert.NoError(t, schema.ApplySQL(`
+			CREATE TABLE "public"."instructors"("id" serial NOT NULL, PRIMARY KEY ("id"));
+			CREATE TABLE "public"."teaches"("id" serial NOT NULL, "instructor_id" integer NOT NULL, PRIMARY KEY ("id"),
+				FOREIGN KEY ("instructor_id") REFERENCES "public"."instructors"("id") ON DELETE `+onDelete+`);`))
+		return schema
+	}
+	sdlSchema, err := ParseSDL(`
+		type instructors { id: Int! teaches: [teaches!]! }
+		type teaches { id: Int! instructor_id: Int! instructor: instructors! }
+		input Int_comparison_exp { _eq: Int }
+		input instructors_bool_exp { id: Int_comparison_exp }
+		input teaches_bool_exp { instructor_id: Int_comparison_exp }
+		input instructors_insert_input { id: Int }
+		input teaches_insert_input { instructor_id: Int }
+		type mutation_response { affected_rows: Int! }
+		type Query { instructors: [instructors!]! }
+		type Mutation {
+			insert_instructors_one(object: instructors_insert_input!): instructors
+			insert_teaches_one(object: teaches_insert_input!): teaches
+			delete_instructors(where: instructors_bool_exp!): mutation_response
+			delete_teaches(where: teaches_bool_exp!): mutation_response
+		}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testCases := []struct {
+		name                string
+		givenHasura         *hasurameta.Schema
+		givenSchema         *Schema
+		givenByForeignKeys  bool
+		expectedOrder       []int
+		expectedDiagnostics []string
+	}{
+		{
+			name:          "no foreign keys known: reverse sequence",
+			expectedOrder: []int{2, 1, 0},
+		},
+		{
+			name:          "reverse sequence violating a foreign key",
+			givenHasura:   hasuraSchema("restrict"),
+			expectedOrder: []int{2, 1, 0},
+			expectedDiagnostics: []string{
+				"fixture[1].teardown: warning: deletes from instructors before fixture[0] deletes from teaches, which references it; reorder the fixtures or set OrderTeardownByForeignKeys [teardown-order]",
+			},
+		},
+		{
+			name:               "ordered by the foreign keys of hasura",
+			givenHasura:        hasuraSchema("restrict"),
+			givenByForeignKeys: true,
+			expectedOrder:      []int{2, 0, 1},
+		},
+		{
+			name:               "ON DELETE CASCADE: no need to order",
+			givenHasura:        hasuraSchema("cascade"),
+			givenByForeignKeys: true,
+			expectedOrder:      []int{2, 1, 0},
+		},
+		{
+			name:               "ordered by the object relationships of the schema",
+			givenSchema:        sdlSchema,
+			givenByForeignKeys: true,
+			expectedOrder:      []int{2, 0, 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixtures := Fixtures{
+				Fixtures:                   teardownOrderFixtures(),
+				Hasura:                     tc.givenHasura,
+				Schema:                     tc.givenSchema,
+				OrderTeardownByForeignKeys: tc.givenByForeignKeys,
+			}
+			var actual []string
+			for _, diagnostic := range fixtures.ParseDiagnostics() {
+				actual = append(actual, diagnostic.String())
+			}
+			assert.NoError(t, fixtures.parseErr)
+			assert.Equal(t, tc.expectedDiagnostics, actual)
+			assert.Equal(t, tc.expectedOrder, fixtures.teardownOrder(2))
+		})
+	}
+}
+
+func TestTeardownByForeignKeys(t *testing.T) {
+	// GIVEN: all setup; the teardown of fixture[0] (going first) succeeds, fixture[1] fails
+	responses := [][]byte{
+		[]byte(`{ "data": { "insert_teaches_one": { "id": 1 } } }`),
+		[]byte(`{ "data": { "insert_instructors_one": { "id": 1 } } }`),
+		[]byte(`{ "data": { "instructors": [ { "id": 1 } ] } }`),
+		[]byte(`{ "data": { "delete_teaches": { "affected_rows": 1 } } }`),
+		[]byte(`{ "errors": [ { "message": "boom" } ] }`),
+	}
+	var requests []graphqlclient.Request
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = responses[len(requests)]
+		requests = append(requests, req)
+		return nil
+	})
+	hasura := &hasurameta.Schema{Tables: map[string]*hasurameta.Table{}}
+	assert.NoError(t, hasura.ApplySQL(`
+		CREATE TABLE "public"."instructors"("id" serial NOT NULL, PRIMARY KEY ("id"));
+		CREATE TABLE "public"."teaches"("id" serial NOT NULL, "instructor_id" integer NOT NULL, PRIMARY KEY ("id"),
+			FOREIGN KEY ("instructor_id") REFERENCES "public"."instructors"("id"));`))
+	fixtures := Fixtures{Fixtures: teardownOrderFixtures(), Hasura: hasura, OrderTeardownByForeignKeys: true}
+
+	assert.NoError(t, fixtures.Setup(context.Background(), executor))
+	assert.Error(t, fixtures.Teardown(context.Background(), executor))
+
+	// THEN: teaches deleted before instructors; only fixture[1] left
+	if assert.Len(t, requests, 5) {
+		assert.Equal(t, *fixtures.Fixtures[0].Teardown, requests[3].Query)
+		assert.Equal(t, *fixtures.Fixtures[1].Teardown, requests[4].Query)
+	}
+	assert.Equal(t, gopointer.OfInt(0), fixtures.TeardownUntil())
+	steps, err := fixtures.Steps(false)
+	assert.NoError(t, err)
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Name())
+	}
+	assert.Equal(t, []string{"fixture[0].setup", "fixture[1].setup", "fixture[2].setup", "fixture[0].teardown", "fixture[1].teardown"}, names)
+	assert.Equal(t, &Remediation{Steps: []RemediationStep{
+		{FixtureIdx: 1, Request: graphqlclient.Request{Query: *fixtures.Fixtures[1].Teardown}},
+	}}, fixtures.Remediation())
+}
@@ -0,0 +1,90 @@
+package graphqlfixture
+
+import (
+	"context"
+)
+
+// Span names used by Setup and Teardown
+const (
+	SpanSetup           = "graphqlfixture.Setup"    // the whole setup run
+	SpanTeardown        = "graphqlfixture.Teardown" // the whole teardown run
+	SpanFixtureSetup    = "graphqlfixture.fixture.setup"
+	SpanFixtureCaptors  = "graphqlfixture.fixture.captors"
+	SpanFixtureTeardown = "graphqlfixture.fixture.teardown"
+	SpanRequest         = "graphqlfixture.request" // each graphql (http) request, including each retry attempt
+)
+
+// Attribute keys set on the spans
+const (
+	AttrFixtureCount  = "graphqlfixture.fixture.count"
+	AttrFixtureIdx    = "graphqlfixture.fixture.index"
+	AttrFixtureName   = "graphqlfixture.fixture.name"
+	AttrCaptureCount  = "graphqlfixture.capture.count"
+	AttrOperationType = "graphql.operation.type"
+	AttrAttempt       = "graphqlfixture.request.attempt"
+	AttrBatchSize     = "graphqlfixture.request.batch.size" // number of requests in a batched request
+)
+
+// Attribute is a key-value pair annotating a span
+type Attribute struct {
+	Key   string
+	Value interface{} // string, bool, int, int64, float64 (other types are rendered with fmt by the adapters)
+}
+
+// Attr creates an Attribute
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Tracer starts spans. Setup and Teardown start a span for the whole run, each fixture step, and each graphql request,
+// to tell which step is the bottleneck. See the otelfixture module for an OpenTelemetry adapter.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx (if any), and returns the ctx carrying the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a unit of work started by Tracer
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error) // also marks the span as failed
+	End()
+}
+
+// NoopTracer is the default Tracer: does nothing
+type NoopTracer struct{}
+
+// Start returns ctx as-is and a span that does nothing
+func (NoopTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...Attribute) {}
+func (noopSpan) RecordError(err error)            {}
+func (noopSpan) End()                             {}
+
+// tracer returns fs.Tracer, or NoopTracer if not given
+func (fs *Fixtures) tracer() Tracer {
+	if fs.Tracer == nil {
+		return NoopTracer{}
+	}
+	return fs.Tracer
+}
+
+// startFixtureSpan starts the span of the fixture's step
+func (fs *Fixtures) startFixtureSpan(ctx context.Context, name string, fIdx int) (context.Context, Span) {
+	attrs := []Attribute{Attr(AttrFixtureIdx, fIdx)}
+	if fixtureName := fs.Fixtures[fIdx].Name; fixtureName != "" {
+		attrs = append(attrs, Attr(AttrFixtureName, fixtureName))
+	}
+	return fs.tracer().Start(ctx, name, attrs...)
+}
+
+// endSpan records the err (if any, redacted) and ends the span
+func (fs *Fixtures) endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(fs.redactError(err))
+	}
+	span.End()
+}
@@ -0,0 +1,92 @@
+package graphqlfixture
+
+import (
+	"context"
+	"fmt"
+	"github.com/gmm1900/gopointer"
+	"github.com/gmm1900/graphqlclient"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// recordingTracer records the spans as "<parent> > <name> <attrs> [error]" lines, in the order they end,
+// and the messages of the errors recorded
+type recordingTracer struct {
+	spans  []string
+	errors []string
+}
+
+type recordingSpanKey struct{}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	path   string
+	attrs  []Attribute
+	err    error
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	path := name
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*recordingSpan); ok {
+		path = parent.path + " > " + name
+	}
+	span := &recordingSpan{tracer: t, path: path, attrs: attrs}
+	return context.WithValue(ctx, recordingSpanKey{}, span), span
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...Attribute) { s.attrs = append(s.attrs, attrs...) }
+func (s *recordingSpan) RecordError(err error)            { s.err = err }
+func (s *recordingSpan) End() {
+	var attrs []string
+	for _, attr := range s.attrs {
+		attrs = append(attrs, fmt.Sprintf("%s=%v", attr.Key, attr.Value))
+	}
+	line := fmt.Sprintf("%s {%s}", s.path, strings.Join(attrs, " "))
+	if s.err != nil {
+		line += " error"
+		s.tracer.errors = append(s.tracer.errors, s.err.Error())
+	}
+	s.tracer.spans = append(s.tracer.spans, line)
+}
+
+func TestTracer(t *testing.T) {
+	// GIVEN
+	responses := [][]byte{
+		[]byte(`{ "data": { "insert_abc": { "returning": [ { "id": 13 } ] } } }`),
+		[]byte(`{ "errors": [ { "message": "boom" } ] }`),
+	}
+	calls := 0
+	executor := ExecutorFunc(func(ctx context.Context, req graphqlclient.Request, resp interface{}) error {
+		*resp.(*[]byte) = responses[calls]
+		calls++
+		return nil
+	})
+	tracer := &recordingTracer{}
+	fixtures := Fixtures{
+		Tracer: tracer,
+		Fixtures: []Fixture{
+			{
+				Name:     "abc",
+				Setup:    `mutation { insert_abc(objects: { name: "abc1"}) { returning { id } } }`,
+				Captors:  map[string]string{"abc_id": "/data/insert_abc/returning/0/id"},
+				Teardown: gopointer.OfString(`mutation ($abc_id: Int!) { delete_abc(where: { id: { _eq: $abc_id } }) { affected_rows } }`),
+			},
+		},
+	}
+
+	// WHEN
+	assert.NoError(t, fixtures.Setup(context.Background(), executor))
+	assert.Error(t, fixtures.Teardown(context.Background(), executor))
+
+	// THEN
+	assert.Equal(t, []string{
+		"graphqlfixture.Setup > graphqlfixture.fixture.setup > graphqlfixture.request {graphql.operation.type=mutation graphqlfixture.request.attempt=1}",
+		"graphqlfixture.Setup > graphqlfixture.fixture.setup {graphqlfixture.fixture.index=0 graphqlfixture.fixture.name=abc}",
+		"graphqlfixture.Setup > graphqlfixture.fixture.captors {graphqlfixture.fixture.index=0 graphqlfixture.fixture.name=abc graphqlfixture.capture.count=1}",
+		"graphqlfixture.Setup {graphqlfixture.fixture.count=1}",
+		"graphqlfixture.Teardown > graphqlfixture.fixture.teardown > graphqlfixture.request {graphql.operation.type=mutation graphqlfixture.request.attempt=1} error",
+		"graphqlfixture.Teardown > graphqlfixture.fixture.teardown {graphqlfixture.fixture.index=0 graphqlfixture.fixture.name=abc} error",
+		"graphqlfixture.Teardown {graphqlfixture.fixture.count=1} error",
+	}, tracer.spans)
+}